@@ -0,0 +1,246 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"io"
+	"time"
+)
+
+// Archives are gzipped JSON lines: a header line, one line per item with its activities, and a footer line
+const (
+	Format  = "event-hub-archive"
+	Version = 1
+)
+
+type header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+}
+
+type record struct {
+	Repo       string                  `json:"repo,omitempty"`
+	Item       *scanItem.ScanItem      `json:"item,omitempty"`
+	Activities []scanItem.ItemActivity `json:"activities,omitempty"`
+	End        bool                    `json:"end,omitempty"`
+	Items      int                     `json:"items,omitempty"`
+}
+
+// Stats counts what went through an archive
+type Stats struct {
+	Repositories int
+	Items        int
+	Activities   int
+}
+
+// OpenRepository returns the repository an archived repository is loaded into
+type OpenRepository func(ctx context.Context, repoName string) (scanItem.RepositoryInterfaceV2, error)
+
+// Export streams every item and activity of repos into w, it stops at the first error of a repository.
+// Activities recorded after the export started are left out, so the archive is consistent as of its creation time.
+func Export(ctx context.Context, w io.Writer, repos []scanItem.RepositoryInterfaceV2) (Stats, error) {
+	stats := Stats{}
+	started := time.Now()
+
+	zw := gzip.NewWriter(w)
+	buffered := bufio.NewWriter(zw)
+	enc := json.NewEncoder(buffered)
+
+	if err := enc.Encode(header{Format: Format, Version: Version, Created: started}); err != nil {
+		return stats, err
+	}
+
+	for _, repo := range repos {
+		stats.Repositories += 1
+
+		items, err := repo.Items(ctx)
+
+		if err != nil {
+			return stats, err
+		}
+
+		for _, item := range items {
+			rec := record{Repo: repo.GetRepoName(), Item: item}
+			activities, err := repo.GetItemActivities(ctx, item.Key)
+
+			if err != nil {
+				return stats, err
+			}
+
+			if nil != activities {
+				for _, activity := range activities.Activities {
+					if !activity.Created.After(started) {
+						rec.Activities = append(rec.Activities, activity)
+					}
+				}
+			}
+
+			if err := enc.Encode(rec); err != nil {
+				return stats, err
+			}
+
+			stats.Items += 1
+			stats.Activities += len(rec.Activities)
+		}
+	}
+
+	if err := enc.Encode(record{End: true, Items: stats.Items}); err != nil {
+		return stats, err
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return stats, err
+	}
+
+	return stats, zw.Close()
+}
+
+// Import loads an archive into the repositories given by open.
+// Activities already present in a repository, by Id, are not added twice.
+func Import(ctx context.Context, r io.Reader, open OpenRepository) (Stats, error) {
+	stats := Stats{}
+
+	zr, err := gzip.NewReader(r)
+
+	if err != nil {
+		return stats, err
+	}
+
+	defer func() { _ = zr.Close() }()
+
+	dec := json.NewDecoder(bufio.NewReader(zr))
+
+	var head header
+	if err := dec.Decode(&head); err != nil {
+		return stats, fmt.Errorf("archive header: %s", err.Error())
+	}
+
+	if head.Format != Format || head.Version < 1 || head.Version > Version {
+		return stats, fmt.Errorf("unsupported archive %s version %d", head.Format, head.Version)
+	}
+
+	repos := make(map[string]scanItem.RepositoryInterfaceV2)
+
+	for {
+		var rec record
+
+		if err := dec.Decode(&rec); err == io.EOF {
+			return stats, errors.New("archive is truncated, end marker is missing")
+		} else if err != nil {
+			return stats, err
+		}
+
+		if rec.End {
+			if rec.Items != stats.Items {
+				return stats, fmt.Errorf("archive announces %d items, %d were read", rec.Items, stats.Items)
+			}
+
+			return stats, nil
+		}
+
+		repo, found := repos[rec.Repo]
+
+		if !found {
+			if repo, err = open(ctx, rec.Repo); err != nil {
+				return stats, err
+			}
+
+			repos[rec.Repo] = repo
+			stats.Repositories += 1
+		}
+
+		added, err := Copy(ctx, repo, rec.Item, rec.Activities)
+		stats.Activities += added
+
+		if err != nil {
+			return stats, err
+		}
+
+		stats.Items += 1
+	}
+}
+
+// Migrate copies every item and activity of src into dst, returning what was copied until the first error
+func Migrate(ctx context.Context, src scanItem.RepositoryInterfaceV2, dst scanItem.RepositoryInterfaceV2) (Stats, error) {
+	stats := Stats{Repositories: 1}
+
+	items, err := src.Items(ctx)
+
+	if err != nil {
+		return stats, err
+	}
+
+	for _, item := range items {
+		var activities []scanItem.ItemActivity
+
+		stored, err := src.GetItemActivities(ctx, item.Key)
+
+		if err != nil {
+			return stats, err
+		}
+
+		if nil != stored {
+			activities = stored.Activities
+		}
+
+		added, err := Copy(ctx, dst, item, activities)
+		stats.Activities += added
+
+		if err != nil {
+			return stats, err
+		}
+
+		stats.Items += 1
+	}
+
+	return stats, nil
+}
+
+// Copy stores an item and its activities (latest on top) into repo, and returns the number of activities added
+func Copy(ctx context.Context, repo scanItem.RepositoryInterfaceV2, item *scanItem.ScanItem, activities []scanItem.ItemActivity) (int, error) {
+	if nil == item {
+		return 0, nil
+	}
+
+	if err := repo.SetItem(ctx, item); err != nil {
+		return 0, err
+	}
+
+	stored, err := repo.GetItemActivities(ctx, item.Key)
+
+	if err != nil {
+		return 0, err
+	}
+
+	existing := make(map[string]bool)
+	if nil != stored {
+		for _, activity := range stored.Activities {
+			existing[activity.Id] = true
+		}
+	}
+
+	added := 0
+
+	// oldest first, as AddItemActivity puts the latest on top
+	for idx := len(activities) - 1; idx >= 0; idx-- {
+		activity := activities[idx]
+
+		if "" != activity.Id && existing[activity.Id] {
+			continue
+		}
+
+		if _, err := repo.AddItemActivity(ctx, item.Key, activity); err != nil {
+			return added, err
+		}
+
+		added += 1
+	}
+
+	return added, nil
+}
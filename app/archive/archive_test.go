@@ -0,0 +1,118 @@
+package archive_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"git.anphabe.net/event/anphabe-event-hub/app/archive"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/boltDb"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+)
+
+var errBroken = errors.New("broken storage")
+
+// brokenRepository fails to read activities and to store items
+type brokenRepository struct {
+	scanItem.RepositoryInterfaceV2
+}
+
+func (r brokenRepository) GetItemActivities(ctx context.Context, itemKey string) (*scanItem.ItemActivities, error) {
+	return nil, errBroken
+}
+
+func (r brokenRepository) SetItem(ctx context.Context, item *scanItem.ScanItem) error {
+	return errBroken
+}
+
+var _ = Describe("Archive\n", func() {
+	var folder string
+	var ctx context.Context
+	var source scanItem.RepositoryInterfaceV2
+	var first, second scanItem.ItemActivity
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		folder, _ = ioutil.TempDir("", "archive")
+		source, _ = memDb.NewMemDbConnection(folder+"/mem").OpenRepository(ctx, "guests")
+
+		_, _ = source.NewItem(ctx, "101", map[string]string{"name": "Nguyễn Thị Hồng"})
+		_, _ = source.NewItem(ctx, "102", map[string]string{"name": "Tran Van An"})
+
+		first = scanItem.NewActivity("checkin", map[string]string{"gateway": "gate1"})
+		second = scanItem.NewActivity("lunch", nil)
+		_, _ = source.AddItemActivity(ctx, "101", first)
+		_, _ = source.AddItemActivity(ctx, "101", second)
+	})
+
+	AfterEach(func() {
+		_ = source.CloseDb(ctx)
+		_ = os.RemoveAll(folder)
+	})
+
+	Context("Given an archive of a mem repository loaded into bolt\n", func() {
+		It("items and activities keep their order, a second restore adds nothing\n", func() {
+			var buffer bytes.Buffer
+			stats, err := archive.Export(ctx, &buffer, []scanItem.RepositoryInterfaceV2{source})
+
+			Expect(err).To(BeNil())
+			Expect(stats).To(Equal(archive.Stats{Repositories: 1, Items: 2, Activities: 2}))
+
+			connection, _ := boltDb.NewBoltDbConnection(folder + "/bolt")
+			registry := service.NewRepositoryRegistry(connection)
+			defer registry.Shutdown()
+
+			restored, err := archive.Import(ctx, bytes.NewReader(buffer.Bytes()), registry.GetRepositoryV2)
+			Expect(err).To(BeNil())
+			Expect(restored).To(Equal(stats))
+
+			target, _ := registry.GetRepositoryV2(ctx, "guests")
+			got, found, err := target.GetItemDetail(ctx, "101")
+			Expect(err).To(BeNil())
+			Expect(found).To(BeTrue())
+			Expect(got.Data["name"]).To(Equal("Nguyễn Thị Hồng"))
+			Expect(got.Activities).To(HaveLen(2))
+			Expect(got.Activities[0].Id).To(Equal(second.Id))
+			Expect(got.Activities[1].Id).To(Equal(first.Id))
+
+			again, err := archive.Import(ctx, bytes.NewReader(buffer.Bytes()), registry.GetRepositoryV2)
+			Expect(err).To(BeNil())
+			Expect(again.Activities).To(Equal(0))
+		})
+	})
+
+	Context("Given a truncated archive\n", func() {
+		It("the import fails\n", func() {
+			var buffer bytes.Buffer
+			_, _ = archive.Export(ctx, &buffer, []scanItem.RepositoryInterfaceV2{source})
+
+			truncated := buffer.Bytes()[:buffer.Len()-30]
+			_, err := archive.Import(ctx, bytes.NewReader(truncated), memDb.NewMemDbConnection(folder+"/other").OpenRepository)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Given a storage which fails\n", func() {
+		It("export, import and migrate return its error\n", func() {
+			var buffer bytes.Buffer
+			_, err := archive.Export(ctx, &bytes.Buffer{}, []scanItem.RepositoryInterfaceV2{brokenRepository{source}})
+			Expect(err).To(Equal(errBroken))
+
+			_, _ = archive.Export(ctx, &buffer, []scanItem.RepositoryInterfaceV2{source})
+			_, err = archive.Import(ctx, &buffer, func(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+				return brokenRepository{source}, nil
+			})
+			Expect(err).To(Equal(errBroken))
+
+			stats, err := archive.Migrate(ctx, source, brokenRepository{source})
+			Expect(err).To(Equal(errBroken))
+			Expect(stats.Items).To(Equal(0))
+		})
+	})
+})
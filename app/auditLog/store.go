@@ -0,0 +1,136 @@
+package auditLog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry records an administrative action on the data of a source
+type Entry struct {
+	Seq     uint64            `json:"seq"`
+	Time    time.Time         `json:"time"`
+	Source  string            `json:"source"`
+	Action  string            `json:"action"`
+	Trigger string            `json:"trigger"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Store is an append-only audit log, one JSON line per entry
+type Store struct {
+	mu      sync.RWMutex
+	file    *os.File
+	entries []Entry
+	lastSeq uint64
+}
+
+// OpenStore loads the entries of fileName, a line torn by a crash at the end of the file is dropped
+func OpenStore(fileName string) (*Store, error) {
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR, 0600)
+
+	if err != nil {
+		return nil, err
+	}
+
+	store := &Store{file: file}
+	valid, err := store.load()
+
+	if err == nil {
+		err = file.Truncate(valid)
+	}
+
+	if err == nil {
+		_, err = file.Seek(valid, io.SeekStart)
+	}
+
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// load reads every complete entry and returns the size of the valid part of the file
+func (s *Store) load() (int64, error) {
+	reader := bufio.NewReader(s.file)
+	var valid int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if err == io.EOF {
+			return valid, nil
+		} else if err != nil {
+			return valid, err
+		}
+
+		var entry Entry
+
+		if err := json.Unmarshal(bytes.TrimSpace(line), &entry); err != nil || entry.Seq <= s.lastSeq {
+			return valid, nil
+		}
+
+		s.entries = append(s.entries, entry)
+		s.lastSeq = entry.Seq
+		valid += int64(len(line))
+	}
+}
+
+// Append records an entry and makes it durable before returning, Seq and a missing Time are set
+func (s *Store) Append(entry Entry) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.Seq = s.lastSeq + 1
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	line, err := json.Marshal(entry)
+
+	if err != nil {
+		return entry, err
+	}
+
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return entry, err
+	}
+
+	if err := s.file.Sync(); err != nil {
+		return entry, err
+	}
+
+	s.entries = append(s.entries, entry)
+	s.lastSeq = entry.Seq
+
+	return entry, nil
+}
+
+// Entries returns the entries of source in sequence order, every entry when source is empty
+func (s *Store) Entries(source string) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []Entry{}
+
+	for _, entry := range s.entries {
+		if "" == source || entry.Source == source {
+			result = append(result, entry)
+		}
+	}
+
+	return result
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
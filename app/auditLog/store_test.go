@@ -0,0 +1,55 @@
+package auditLog_test
+
+import (
+	"git.anphabe.net/event/anphabe-event-hub/app/auditLog"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audit log store", func() {
+	var folder string
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "auditLog")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	Context("Given entries of two sources", func() {
+		It("they are kept across reopening and listed per source", func() {
+			fileName := filepath.Join(folder, "audit.log")
+			store, err := auditLog.OpenStore(fileName)
+			Expect(err).To(BeNil())
+
+			_, _ = store.Append(auditLog.Entry{Source: "guests", Action: "retention", Trigger: "schedule"})
+			_, _ = store.Append(auditLog.Entry{Source: "staff", Action: "retention", Trigger: "admin"})
+			_ = store.Close()
+
+			By("a torn line at the end of the file is dropped")
+			fp, _ := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0600)
+			_, _ = fp.WriteString(`{"seq":3,"sou`)
+			_ = fp.Close()
+
+			reopened, err := auditLog.OpenStore(fileName)
+			Expect(err).To(BeNil())
+			defer reopened.Close()
+
+			entry, err := reopened.Append(auditLog.Entry{Source: "guests", Action: "retention", Trigger: "admin"})
+			Expect(err).To(BeNil())
+			Expect(entry.Seq).To(Equal(uint64(3)))
+			Expect(entry.Time.IsZero()).To(BeFalse())
+
+			guests := reopened.Entries("guests")
+			Expect(guests).To(HaveLen(2))
+			Expect(guests[0].Trigger).To(Equal("schedule"))
+			Expect(guests[1].Seq).To(Equal(uint64(3)))
+			Expect(reopened.Entries("")).To(HaveLen(3))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package eventLog_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestEventLog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Event Log Suite")
+}
@@ -0,0 +1,165 @@
+package eventLog
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Logged is implemented by repositories which record their activities in an event log
+type Logged interface {
+	Events(query Query) []Event
+	LastSeq() uint64
+}
+
+// LoggedConnection opens repositories which append every added activity to an event log kept in folder,
+// sealed with cipher when the storage encrypts at rest. A log which can not be appended to is reported to logger.
+type LoggedConnection struct {
+	service.DbConnectionInterface
+	folder string
+	cipher *atRest.Cipher
+	logger *zap.Logger
+}
+
+func NewLoggedConnection(connection service.DbConnectionInterface, folder string, cipher *atRest.Cipher, logger *zap.Logger) *LoggedConnection {
+	return &LoggedConnection{
+		DbConnectionInterface: connection,
+		folder:                folder,
+		cipher:                cipher,
+		logger:                logger,
+	}
+}
+
+func (c *LoggedConnection) InitRepository(name string) (scanItem.RepositoryInterface, error) {
+	return scanItem.LegacyOpen(c.OpenRepository(context.Background(), name))
+}
+
+// OpenRepository opens the repository with its event log,
+// a new log starts with the activities the repository already holds, oldest first
+func (c *LoggedConnection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	repo, err := c.DbConnectionInterface.OpenRepository(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := c.openStore(ctx, name, repo)
+
+	if err != nil {
+		_ = repo.CloseDb(ctx)
+		return nil, err
+	}
+
+	return &LoggedRepository{
+		RepositoryInterfaceV2: repo,
+		store:                 store,
+		logger:                c.logger,
+	}, nil
+}
+
+func (c *LoggedConnection) openStore(ctx context.Context, name string, repo scanItem.RepositoryInterfaceV2) (*Store, error) {
+	if err := os.MkdirAll(c.folder, 0755); err != nil {
+		return nil, err
+	}
+
+	store, err := OpenStore(filepath.Join(c.folder, name+FileExtension), c.cipher)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if 0 == store.LastSeq() {
+		if err := backfill(ctx, store, repo); err != nil {
+			_ = store.Close()
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+func backfill(ctx context.Context, store *Store, repo scanItem.RepositoryInterfaceV2) error {
+	type itemActivity struct {
+		itemKey  string
+		activity scanItem.ItemActivity
+	}
+
+	var existing []itemActivity
+
+	items, err := repo.Items(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		activities, err := repo.GetItemActivities(ctx, item.Key)
+
+		if err != nil {
+			return err
+		}
+
+		if nil != activities {
+			for _, activity := range activities.Activities {
+				existing = append(existing, itemActivity{item.Key, activity})
+			}
+		}
+	}
+
+	sort.SliceStable(existing, func(a, b int) bool {
+		return existing[a].activity.Created.Before(existing[b].activity.Created)
+	})
+
+	for _, entry := range existing {
+		if _, err := store.Append(entry.itemKey, entry.activity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoggedRepository is a repository whose added activities are also appended to its event log
+type LoggedRepository struct {
+	scanItem.RepositoryInterfaceV2
+	store  *Store
+	logger *zap.Logger
+}
+
+// AddItemActivity logs the activity once the repository stored it,
+// a log which can not be appended to is reported but does not undo the stored activity
+func (r *LoggedRepository) AddItemActivity(ctx context.Context, itemKey string, activity scanItem.ItemActivity) (*scanItem.ItemActivities, error) {
+	activities, err := r.RepositoryInterfaceV2.AddItemActivity(ctx, itemKey, activity)
+
+	if nil != activities && nil == err {
+		if _, err := r.store.Append(itemKey, activity); err != nil {
+			r.logger.Error("eventLog: could not append", zap.String("dbName", r.GetRepoName()), zap.Error(err))
+		}
+	}
+
+	return activities, err
+}
+
+func (r *LoggedRepository) Events(query Query) []Event {
+	return r.store.Query(query)
+}
+
+func (r *LoggedRepository) LastSeq() uint64 {
+	return r.store.LastSeq()
+}
+
+func (r *LoggedRepository) CloseDb(ctx context.Context) error {
+	err := r.RepositoryInterfaceV2.CloseDb(ctx)
+	_ = r.store.Close()
+
+	return err
+}
+
+func (r *LoggedRepository) Unwrap() scanItem.RepositoryInterfaceV2 {
+	return r.RepositoryInterfaceV2
+}
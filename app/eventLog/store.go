@@ -0,0 +1,227 @@
+package eventLog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Event is an activity as recorded in the log, Seq grows by one with every append
+type Event struct {
+	Seq     uint64            `json:"seq"`
+	ItemKey string            `json:"item"`
+	Id      string            `json:"id"`
+	Action  string            `json:"action"`
+	Data    map[string]string `json:"data,omitempty"`
+	Created time.Time         `json:"created"`
+}
+
+// Query selects events, zero values are not filtered on.
+// After is a sequence number, only later events are returned: it is the cursor of feeds and replication.
+type Query struct {
+	After  uint64
+	From   time.Time
+	To     time.Time
+	Action string
+	Data   map[string]string
+	Limit  int
+}
+
+// FileExtension ends the name of the event log of a repository
+const FileExtension = ".events"
+
+// Store is the append-only event log of one repository, one JSON line per event.
+// Lines are sealed with the cipher of the repository's storage, a nil cipher keeps them in the clear.
+type Store struct {
+	mu      sync.RWMutex
+	file    *os.File
+	cipher  *atRest.Cipher
+	events  []Event
+	lastSeq uint64
+}
+
+// OpenStore loads the events of fileName, a line torn by a crash at the end of the file is dropped.
+// A complete line cipher can not open is an error, the log is not truncated.
+func OpenStore(fileName string, cipher *atRest.Cipher) (*Store, error) {
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR, 0600)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// logs written before they were sealed were readable by everyone
+	if err := file.Chmod(0600); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	store := &Store{file: file, cipher: cipher}
+	valid, err := store.load()
+
+	if err == nil {
+		err = file.Truncate(valid)
+	}
+
+	if err == nil {
+		_, err = file.Seek(valid, io.SeekStart)
+	}
+
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// load reads every complete event and returns the size of the valid part of the file
+func (s *Store) load() (int64, error) {
+	reader := bufio.NewReader(s.file)
+	var valid int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if err == io.EOF {
+			return valid, nil
+		} else if err != nil {
+			return valid, err
+		}
+
+		plain, err := s.cipher.OpenLine(bytes.TrimSpace(line))
+
+		if err != nil {
+			return valid, err
+		}
+
+		var event Event
+
+		if err := json.Unmarshal(plain, &event); err != nil || event.Seq <= s.lastSeq {
+			return valid, nil
+		}
+
+		s.events = append(s.events, event)
+		s.lastSeq = event.Seq
+		valid += int64(len(line))
+	}
+}
+
+// Append records an activity of an item and makes it durable before returning
+func (s *Store) Append(itemKey string, activity scanItem.ItemActivity) (Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := Event{
+		Seq:     s.lastSeq + 1,
+		ItemKey: itemKey,
+		Id:      activity.Id,
+		Action:  activity.Action,
+		Data:    activity.Data,
+		Created: activity.Created,
+	}
+
+	line, err := json.Marshal(event)
+
+	if err != nil {
+		return event, err
+	}
+
+	if _, err := s.file.Write(append(s.cipher.SealLine(line), '\n')); err != nil {
+		return event, err
+	}
+
+	if err := s.file.Sync(); err != nil {
+		return event, err
+	}
+
+	s.events = append(s.events, event)
+	s.lastSeq = event.Seq
+
+	return event, nil
+}
+
+// Query returns the matching events in sequence order
+func (s *Store) Query(query Query) []Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []Event{}
+
+	// sequence numbers are dense from 1, the events after the cursor start at index After
+	start := 0
+	if query.After > 0 && query.After <= uint64(len(s.events)) && s.events[query.After-1].Seq == query.After {
+		start = int(query.After)
+	}
+
+	for _, event := range s.events[start:] {
+		if event.Seq <= query.After || !query.matches(event) {
+			continue
+		}
+
+		result = append(result, event)
+
+		if query.Limit > 0 && len(result) >= query.Limit {
+			break
+		}
+	}
+
+	return result
+}
+
+func (q Query) matches(event Event) bool {
+	if "" != q.Action && event.Action != q.Action {
+		return false
+	}
+
+	if !q.From.IsZero() && event.Created.Before(q.From) {
+		return false
+	}
+
+	if !q.To.IsZero() && !event.Created.Before(q.To) {
+		return false
+	}
+
+	for field, value := range q.Data {
+		if event.Data[field] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Rekey seals the event logs in folder with to, no key leaves them in the clear.
+// The logs must be sealed with from, or with to when an interrupted rekey is run again.
+func Rekey(folder string, from *atRest.Cipher, to *atRest.Cipher) error {
+	files, err := filepath.Glob(filepath.Join(folder, "*"+FileExtension))
+
+	for _, fileName := range files {
+		if nil == err {
+			err = atRest.RekeyLines(fileName, from, to)
+		}
+	}
+
+	return err
+}
+
+// LastSeq is the sequence number of the latest event, 0 when the log is empty
+func (s *Store) LastSeq() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastSeq
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
@@ -0,0 +1,168 @@
+package eventLog_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/app/eventLog"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+func activityAt(action string, gateway string, created time.Time) scanItem.ItemActivity {
+	activity := scanItem.NewActivity(action, map[string]string{"gateway": gateway})
+	activity.Created = created
+
+	return activity
+}
+
+func newTestCipher() *atRest.Cipher {
+	text, _ := atRest.GenerateKey()
+	key, _ := atRest.ParseKey(text)
+	cipher, _ := atRest.NewCipher(key)
+
+	return cipher
+}
+
+func eventSeqs(events []eventLog.Event) []uint64 {
+	seqs := []uint64{}
+
+	for _, event := range events {
+		seqs = append(seqs, event.Seq)
+	}
+
+	return seqs
+}
+
+var _ = Describe("Event log\n", func() {
+	var folder string
+	nine := time.Date(2019, 9, 10, 9, 0, 0, 0, time.Local)
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "eventLog")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	Context("Given a store with check-ins at two gates\n", func() {
+		var store *eventLog.Store
+
+		BeforeEach(func() {
+			store, _ = eventLog.OpenStore(folder+"/guests.events", nil)
+
+			_, _ = store.Append("1", activityAt("checkin", "gate1", nine))
+			_, _ = store.Append("2", activityAt("checkin", "gate2", nine.Add(10*time.Minute)))
+			_, _ = store.Append("2", activityAt("lunch", "gate2", nine.Add(3*time.Hour)))
+			_, _ = store.Append("3", activityAt("checkin", "gate2", nine.Add(40*time.Minute)))
+		})
+
+		AfterEach(func() {
+			_ = store.Close()
+		})
+
+		It("events are numbered in the order they were appended\n", func() {
+			Expect(eventSeqs(store.Query(eventLog.Query{}))).To(Equal([]uint64{1, 2, 3, 4}))
+			Expect(store.LastSeq()).To(Equal(uint64(4)))
+		})
+
+		It("events are filtered by action, data and time range\n", func() {
+			got := store.Query(eventLog.Query{
+				Action: "checkin",
+				Data:   map[string]string{"gateway": "gate2"},
+				From:   nine,
+				To:     nine.Add(30 * time.Minute),
+			})
+
+			Expect(eventSeqs(got)).To(Equal([]uint64{2}))
+			Expect(got[0].ItemKey).To(Equal("2"))
+		})
+
+		It("a feed reads the events after its cursor\n", func() {
+			Expect(eventSeqs(store.Query(eventLog.Query{After: 2, Limit: 1}))).To(Equal([]uint64{3}))
+			Expect(store.Query(eventLog.Query{After: 4})).To(BeEmpty())
+		})
+
+		It("a reopened store keeps its events and drops a torn line\n", func() {
+			_ = store.Close()
+
+			fp, _ := os.OpenFile(folder+"/guests.events", os.O_APPEND|os.O_WRONLY, 0644)
+			_, _ = fp.Write([]byte(`{"seq":5,"item":"4","act`))
+			_ = fp.Close()
+
+			store, _ = eventLog.OpenStore(folder+"/guests.events", nil)
+			Expect(store.LastSeq()).To(Equal(uint64(4)))
+
+			event, err := store.Append("4", activityAt("checkin", "gate1", nine.Add(time.Hour)))
+			Expect(err).To(BeNil())
+			Expect(event.Seq).To(Equal(uint64(5)))
+
+			_ = store.Close()
+			store, _ = eventLog.OpenStore(folder+"/guests.events", nil)
+			Expect(eventSeqs(store.Query(eventLog.Query{After: 3}))).To(Equal([]uint64{4, 5}))
+		})
+	})
+
+	Context("Given a store sealed with the storage key\n", func() {
+		It("its file holds no clear event, is private and opens only with that key until rekeyed\n", func() {
+			fileName := folder + "/guests.events"
+			key, next := newTestCipher(), newTestCipher()
+
+			store, err := eventLog.OpenStore(fileName, key)
+			Expect(err).To(BeNil())
+			_, _ = store.Append("1", activityAt("checkin", "gate-secret", nine))
+			_ = store.Close()
+
+			content, _ := ioutil.ReadFile(fileName)
+			Expect(string(content)).NotTo(ContainSubstring("gate-secret"))
+
+			info, _ := os.Stat(fileName)
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			_, err = eventLog.OpenStore(fileName, next)
+			Expect(err).To(MatchError(atRest.ErrWrongKey))
+
+			_, err = eventLog.OpenStore(fileName, nil)
+			Expect(err).To(HaveOccurred())
+
+			Expect(eventLog.Rekey(folder, key, next)).To(Succeed())
+
+			store, err = eventLog.OpenStore(fileName, next)
+			Expect(err).To(BeNil())
+			defer func() { _ = store.Close() }()
+
+			Expect(store.Query(eventLog.Query{})).To(HaveLen(1))
+			Expect(store.Query(eventLog.Query{})[0].Data["gateway"]).To(Equal("gate-secret"))
+		})
+	})
+
+	Context("Given a repository with activities and no event log\n", func() {
+		It("the log starts with the existing activities, then records new ones\n", func() {
+			repo, _ := memDb.NewMemDbConnection(folder + "/db").InitRepository("guests")
+			_, _ = repo.NewItem("1", nil)
+			repo.AddItemActivity("1", activityAt("lunch", "gate1", nine.Add(time.Hour)))
+			repo.AddItemActivity("1", activityAt("checkin", "gate1", nine))
+			repo.CloseDb()
+
+			ctx := context.Background()
+			logged, err := eventLog.NewLoggedConnection(memDb.NewMemDbConnection(folder+"/db"), folder+"/events", nil, zap.NewNop()).OpenRepository(ctx, "guests")
+			Expect(err).To(BeNil())
+			defer func() { _ = logged.CloseDb(ctx) }()
+
+			_, _ = logged.AddItemActivity(ctx, "1", activityAt("dinner", "gate1", nine.Add(8*time.Hour)))
+			_, _ = logged.AddItemActivity(ctx, "unknown", activityAt("dinner", "gate1", nine.Add(8*time.Hour)))
+
+			events := logged.(eventLog.Logged).Events(eventLog.Query{})
+			Expect(events).To(HaveLen(3))
+			Expect(events[0].Action).To(Equal("checkin"))
+			Expect(events[1].Action).To(Equal("lunch"))
+			Expect(events[2].Action).To(Equal("dinner"))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package itemHistory_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestItemHistory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Item History Suite")
+}
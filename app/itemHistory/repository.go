@@ -0,0 +1,146 @@
+package itemHistory
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+)
+
+// Recorder is implemented by repositories which keep the versions of their items
+type Recorder interface {
+	// SetItemInRun stores an item set by an import run, SetItem records versions without a run
+	SetItemInRun(ctx context.Context, item *scanItem.ScanItem, run string) error
+	History(itemKey string) []Entry
+}
+
+// Forgetter is implemented by repositories which can drop the kept versions of their items
+type Forgetter interface {
+	Forget(itemKeys []string) error
+}
+
+// HistoryConnection opens repositories which keep the latest versions of every item in folder,
+// sealed with cipher like the storage of the items. A version which can not be recorded is reported to logger.
+type HistoryConnection struct {
+	service.DbConnectionInterface
+	folder string
+	depth  int
+	cipher *atRest.Cipher
+	logger *zap.Logger
+}
+
+func NewHistoryConnection(connection service.DbConnectionInterface, folder string, depth int, cipher *atRest.Cipher, logger *zap.Logger) *HistoryConnection {
+	return &HistoryConnection{
+		DbConnectionInterface: connection,
+		folder:                folder,
+		depth:                 depth,
+		cipher:                cipher,
+		logger:                logger,
+	}
+}
+
+func (c *HistoryConnection) InitRepository(name string) (scanItem.RepositoryInterface, error) {
+	return scanItem.LegacyOpen(c.OpenRepository(context.Background(), name))
+}
+
+func (c *HistoryConnection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	repo, err := c.DbConnectionInterface.OpenRepository(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(c.folder, 0755)
+
+	var store *Store
+
+	if nil == err {
+		store, err = OpenStore(filepath.Join(c.folder, name+FileExtension), c.depth, c.cipher)
+	}
+
+	if err != nil {
+		_ = repo.CloseDb(ctx)
+		return nil, err
+	}
+
+	return &HistoryRepository{
+		RepositoryInterfaceV2: repo,
+		store:                 store,
+		logger:                c.logger,
+	}, nil
+}
+
+// HistoryRepository records a version of an item every time its data changes
+type HistoryRepository struct {
+	scanItem.RepositoryInterfaceV2
+	store  *Store
+	logger *zap.Logger
+}
+
+func (r *HistoryRepository) NewItem(ctx context.Context, itemKey string, data map[string]string) (*scanItem.ScanItem, error) {
+	item, err := scanItem.NewScanItem(itemKey, data)
+
+	if nil != err {
+		return nil, err
+	}
+
+	if err := r.SetItem(ctx, item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (r *HistoryRepository) SetItem(ctx context.Context, item *scanItem.ScanItem) error {
+	return r.SetItemInRun(ctx, item, "")
+}
+
+// SetItemInRun records the version before storing the item, an item which can not be read or stored is not recorded
+func (r *HistoryRepository) SetItemInRun(ctx context.Context, item *scanItem.ScanItem, run string) error {
+	var previous map[string]string
+
+	existing, found, err := r.RepositoryInterfaceV2.GetItem(ctx, item.Key)
+
+	if err != nil {
+		return err
+	}
+
+	if found {
+		previous = existing.Data
+	}
+
+	if err := r.RepositoryInterfaceV2.SetItem(ctx, item); err != nil {
+		return err
+	}
+
+	if err := r.store.Record(item.Key, item.Data, run, previous); err != nil {
+		r.logger.Error("itemHistory: could not record",
+			zap.String("dbName", r.GetRepoName()),
+			zap.String("itemKey", item.Key),
+			zap.Error(err))
+	}
+
+	return nil
+}
+
+func (r *HistoryRepository) History(itemKey string) []Entry {
+	return r.store.History(itemKey)
+}
+
+func (r *HistoryRepository) Forget(itemKeys []string) error {
+	return r.store.Forget(itemKeys)
+}
+
+func (r *HistoryRepository) CloseDb(ctx context.Context) error {
+	err := r.RepositoryInterfaceV2.CloseDb(ctx)
+	_ = r.store.Close()
+
+	return err
+}
+
+func (r *HistoryRepository) Unwrap() scanItem.RepositoryInterfaceV2 {
+	return r.RepositoryInterfaceV2
+}
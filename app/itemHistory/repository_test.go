@@ -0,0 +1,159 @@
+package itemHistory_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/app/itemHistory"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"os"
+)
+
+var ctx = context.Background()
+
+func openRepository(folder string, depth int) scanItem.RepositoryInterfaceV2 {
+	connection := itemHistory.NewHistoryConnection(memDb.NewMemDbConnection(folder+"/db"), folder+"/history", depth, nil, zap.NewNop())
+	repo, err := connection.OpenRepository(ctx, "guests")
+	Expect(err).To(BeNil())
+
+	return repo
+}
+
+var _ = Describe("Item history\n", func() {
+	var folder string
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "itemHistory")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	Context("Given an item changed by two imports\n", func() {
+		It("versions are listed latest first with their run and field changes\n", func() {
+			repo := openRepository(folder, 0)
+			defer func() { _ = repo.CloseDb(ctx) }()
+
+			recorder := repo.(itemHistory.Recorder)
+			recorder.SetItemInRun(ctx, &scanItem.ScanItem{Key: "1", Data: map[string]string{"company": "Acme", "ticket": "Standard"}}, "import-a")
+			recorder.SetItemInRun(ctx, &scanItem.ScanItem{Key: "1", Data: map[string]string{"company": "Acme", "ticket": "Standard"}}, "import-b")
+			recorder.SetItemInRun(ctx, &scanItem.ScanItem{Key: "1", Data: map[string]string{"company": "Globex", "seat": "A1"}}, "import-c")
+
+			history := recorder.History("1")
+			Expect(history).To(HaveLen(2))
+
+			Expect(history[0].Version.Version).To(Equal(2))
+			Expect(history[0].Run).To(Equal("import-c"))
+			Expect(history[0].Changes).To(Equal([]itemHistory.FieldChange{
+				{Field: "company", From: "Acme", To: "Globex"},
+				{Field: "seat", From: "", To: "A1"},
+				{Field: "ticket", From: "Standard", To: ""},
+			}))
+
+			Expect(history[1].Run).To(Equal("import-a"))
+			Expect(history[1].Changes).To(BeEmpty())
+		})
+	})
+
+	Context("Given an item stored before history was kept\n", func() {
+		It("its data is the first version of its history\n", func() {
+			plain, _ := memDb.NewMemDbConnection(folder + "/db").InitRepository("guests")
+			_, _ = plain.NewItem("1", map[string]string{"company": "Acme"})
+			plain.CloseDb()
+
+			repo := openRepository(folder, 0)
+			defer func() { _ = repo.CloseDb(ctx) }()
+
+			_, _ = repo.NewItem(ctx, "1", map[string]string{"company": "Globex"})
+
+			history := repo.(itemHistory.Recorder).History("1")
+			Expect(history).To(HaveLen(2))
+			Expect(history[0].Changes).To(Equal([]itemHistory.FieldChange{{Field: "company", From: "Acme", To: "Globex"}}))
+		})
+	})
+
+	Context("Given more versions than the depth\n", func() {
+		It("only the latest versions are kept, also after reopening\n", func() {
+			repo := openRepository(folder, 2)
+
+			for _, company := range []string{"A", "B", "C", "D"} {
+				_ = repo.SetItem(ctx, &scanItem.ScanItem{Key: "1", Data: map[string]string{"company": company}})
+			}
+
+			_ = repo.CloseDb(ctx)
+
+			reopened := openRepository(folder, 2)
+			defer func() { _ = reopened.CloseDb(ctx) }()
+
+			history := reopened.(itemHistory.Recorder).History("1")
+			Expect(history).To(HaveLen(2))
+			Expect(history[0].Data["company"]).To(Equal("D"))
+			Expect(history[0].Version.Version).To(Equal(4))
+			Expect(history[1].Data["company"]).To(Equal("C"))
+		})
+	})
+
+	Context("Given a history sealed with the storage key\n", func() {
+		It("its file holds no clear data, is private and opens only with that key until rekeyed\n", func() {
+			text, _ := atRest.GenerateKey()
+			raw, _ := atRest.ParseKey(text)
+			key, _ := atRest.NewCipher(raw)
+			text, _ = atRest.GenerateKey()
+			raw, _ = atRest.ParseKey(text)
+			next, _ := atRest.NewCipher(raw)
+			fileName := folder + "/guests.history"
+
+			store, err := itemHistory.OpenStore(fileName, 0, key)
+			Expect(err).To(BeNil())
+			Expect(store.Record("1", map[string]string{"name": "Jane Doe"}, "import-a", nil)).To(Succeed())
+			_ = store.Close()
+
+			content, _ := ioutil.ReadFile(fileName)
+			Expect(string(content)).NotTo(ContainSubstring("Jane Doe"))
+
+			info, _ := os.Stat(fileName)
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			_, err = itemHistory.OpenStore(fileName, 0, next)
+			Expect(err).To(MatchError(atRest.ErrWrongKey))
+
+			Expect(itemHistory.Rekey(folder, key, next)).To(Succeed())
+
+			store, err = itemHistory.OpenStore(fileName, 0, next)
+			Expect(err).To(BeNil())
+			defer func() { _ = store.Close() }()
+
+			Expect(store.History("1")).To(HaveLen(1))
+			Expect(store.History("1")[0].Data["name"]).To(Equal("Jane Doe"))
+		})
+	})
+
+	Context("Given an item whose versions are forgotten\n", func() {
+		It("they are gone from the file, other items keep theirs\n", func() {
+			repo := openRepository(folder, 0)
+
+			_ = repo.SetItem(ctx, &scanItem.ScanItem{Key: "1", Data: map[string]string{"name": "Jane Doe"}})
+			_ = repo.SetItem(ctx, &scanItem.ScanItem{Key: "2", Data: map[string]string{"name": "John Doe"}})
+			_ = repo.SetItem(ctx, &scanItem.ScanItem{Key: "1", Data: map[string]string{"name": "[redacted]"}})
+			Expect(repo.(itemHistory.Forgetter).Forget([]string{"1"})).To(Succeed())
+
+			By("appends go on after forgetting")
+			_ = repo.SetItem(ctx, &scanItem.ScanItem{Key: "2", Data: map[string]string{"name": "[redacted]"}})
+			_ = repo.CloseDb(ctx)
+
+			content, _ := ioutil.ReadFile(folder + "/history/guests.history")
+			Expect(string(content)).NotTo(ContainSubstring("Jane Doe"))
+
+			reopened := openRepository(folder, 0)
+			defer func() { _ = reopened.CloseDb(ctx) }()
+
+			Expect(reopened.(itemHistory.Recorder).History("1")).To(BeEmpty())
+			Expect(reopened.(itemHistory.Recorder).History("2")).To(HaveLen(2))
+		})
+	})
+})
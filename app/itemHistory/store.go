@@ -0,0 +1,348 @@
+package itemHistory
+
+import (
+	"bufio"
+	"encoding/json"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultDepth is the number of versions kept per item when none is configured
+const DefaultDepth = 10
+
+// FileExtension ends the name of the history file of a repository
+const FileExtension = ".history"
+
+// Version is the data an item had from Recorded on, Run is the import run which produced it,
+// empty when the data was set outside of an import (local edit, restore)
+type Version struct {
+	Version  int               `json:"version"`
+	Run      string            `json:"run,omitempty"`
+	Recorded time.Time         `json:"recorded"`
+	Data     map[string]string `json:"data"`
+}
+
+// FieldChange is the change of one field from the previous version
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Entry is a version with its changes, the oldest kept version has no changes
+type Entry struct {
+	Version
+	Changes []FieldChange `json:"changes"`
+}
+
+type storedVersion struct {
+	Key     string  `json:"key"`
+	Version Version `json:"version"`
+}
+
+// Store keeps the latest versions of every item of a repository.
+// Versions are appended to a JSON lines file, which is compacted down to depth versions per item when opened.
+// Lines are sealed with the cipher of the repository's storage, a nil cipher keeps them in the clear.
+type Store struct {
+	mu       sync.RWMutex
+	fileName string
+	file     *os.File
+	cipher   *atRest.Cipher
+	depth    int
+	versions map[string][]Version
+}
+
+func OpenStore(fileName string, depth int, cipher *atRest.Cipher) (*Store, error) {
+	if depth <= 0 {
+		depth = DefaultDepth
+	}
+
+	store := &Store{
+		fileName: fileName,
+		cipher:   cipher,
+		depth:    depth,
+		versions: make(map[string][]Version),
+	}
+
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	if err := store.compact(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return nil, err
+	}
+
+	store.file = file
+
+	return store, nil
+}
+
+// Rekey seals the history files in folder with to, no key leaves them in the clear.
+// The files must be sealed with from, or with to when an interrupted rekey is run again.
+func Rekey(folder string, from *atRest.Cipher, to *atRest.Cipher) error {
+	files, err := filepath.Glob(filepath.Join(folder, "*"+FileExtension))
+
+	for _, fileName := range files {
+		if nil == err {
+			err = atRest.RekeyLines(fileName, from, to)
+		}
+	}
+
+	return err
+}
+
+// load reads the stored versions, a line torn by a crash ends the file.
+// A complete line the cipher can not open is an error: the file was sealed with another key.
+func (s *Store) load() error {
+	fp, err := os.Open(s.fileName)
+
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	defer func() { _ = fp.Close() }()
+
+	reader := bufio.NewReader(fp)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		plain, err := s.cipher.OpenLine(line[:len(line)-1])
+
+		if err != nil {
+			return err
+		}
+
+		var stored storedVersion
+
+		if err := json.Unmarshal(plain, &stored); err != nil {
+			return nil
+		}
+
+		s.keep(stored.Key, stored.Version)
+	}
+}
+
+func (s *Store) keep(key string, version Version) {
+	versions := append(s.versions[key], version)
+
+	if len(versions) > s.depth {
+		versions = versions[len(versions)-s.depth:]
+	}
+
+	s.versions[key] = versions
+}
+
+// compact rewrites the file with the kept versions only
+func (s *Store) compact() error {
+	tmpName := s.fileName + ".tmp"
+	fp, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(s.versions))
+	for key := range s.versions {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	writer := bufio.NewWriter(fp)
+
+	for _, key := range keys {
+		for _, version := range s.versions[key] {
+			if err == nil {
+				err = s.writeLine(writer, storedVersion{Key: key, Version: version})
+			}
+		}
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	if err == nil {
+		err = fp.Sync()
+	}
+
+	if closeErr := fp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, s.fileName)
+}
+
+// Record stores data as the latest version of key unless it equals the latest version already.
+// previous, when given, is recorded first for items which have no history yet.
+func (s *Store) Record(key string, data map[string]string, run string, previous map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if 0 == len(s.versions[key]) && nil != previous && !sameData(previous, data) {
+		if err := s.append(key, previous, ""); err != nil {
+			return err
+		}
+	}
+
+	if versions := s.versions[key]; len(versions) > 0 && sameData(versions[len(versions)-1].Data, data) {
+		return nil
+	}
+
+	return s.append(key, data, run)
+}
+
+func (s *Store) append(key string, data map[string]string, run string) error {
+	version := Version{
+		Version:  1,
+		Run:      run,
+		Recorded: time.Now(),
+		Data:     make(map[string]string, len(data)),
+	}
+
+	for field, value := range data {
+		version.Data[field] = value
+	}
+
+	if versions := s.versions[key]; len(versions) > 0 {
+		version.Version = versions[len(versions)-1].Version + 1
+	}
+
+	if err := s.writeLine(s.file, storedVersion{Key: key, Version: version}); err != nil {
+		return err
+	}
+
+	s.keep(key, version)
+
+	return nil
+}
+
+// History returns the kept versions of key, latest first, with the changes each version made
+func (s *Store) History(key string) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.versions[key]
+	entries := make([]Entry, 0, len(versions))
+
+	for idx := len(versions) - 1; idx >= 0; idx-- {
+		entry := Entry{Version: versions[idx], Changes: []FieldChange{}}
+
+		if idx > 0 {
+			entry.Changes = Diff(versions[idx-1].Data, versions[idx].Data)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// Forget drops every kept version of keys, the file is compacted so they are gone from disk as well
+func (s *Store) Forget(keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.versions, key)
+	}
+
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	err := s.compact()
+
+	// appends go on in the compacted file, or in the old one when it could not be replaced
+	file, openErr := os.OpenFile(s.fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if openErr != nil {
+		return openErr
+	}
+
+	s.file = file
+
+	return err
+}
+
+// writeLine writes stored as one sealed line in a single write
+func (s *Store) writeLine(w io.Writer, stored storedVersion) error {
+	line, err := json.Marshal(stored)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(s.cipher.SealLine(line), '\n'))
+
+	return err
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// Diff lists the fields changed, added or removed from before to after, sorted by field
+func Diff(before map[string]string, after map[string]string) []FieldChange {
+	changes := []FieldChange{}
+
+	for field, value := range after {
+		if previous, found := before[field]; !found || previous != value {
+			changes = append(changes, FieldChange{Field: field, From: previous, To: value})
+		}
+	}
+
+	for field, previous := range before {
+		if _, found := after[field]; !found {
+			changes = append(changes, FieldChange{Field: field, From: previous})
+		}
+	}
+
+	sort.Slice(changes, func(a, b int) bool {
+		return changes[a].Field < changes[b].Field
+	})
+
+	return changes
+}
+
+func sameData(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for field, value := range a {
+		if other, found := b[field]; !found || other != value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,204 @@
+package search
+
+import (
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Scores of a query token matching an indexed token
+const (
+	scoreExact  = 3
+	scorePrefix = 2
+	scoreTypo   = 1
+)
+
+// Hit is a ranked search result
+type Hit struct {
+	Key   string
+	Score int
+	Data  map[string]string
+}
+
+// Index is an in-memory full text index over some fields of the items of a repository.
+// Every token of a query must match a token of an item, exactly, as a prefix or with a typo.
+type Index struct {
+	fields   []string
+	mu       sync.RWMutex
+	postings map[string]map[string]bool
+	tokens   map[string][]string
+	items    map[string]map[string]string
+}
+
+// NewIndex indexes fields of items, every field is indexed when fields is empty
+func NewIndex(fields []string) *Index {
+	return &Index{
+		fields:   fields,
+		postings: make(map[string]map[string]bool),
+		tokens:   make(map[string][]string),
+		items:    make(map[string]map[string]string),
+	}
+}
+
+// Set indexes an item, replacing what was indexed for its key
+func (idx *Index) Set(item *scanItem.ScanItem) {
+	if nil == item {
+		return
+	}
+
+	tokens := idx.itemTokens(item)
+
+	data := make(map[string]string, len(item.Data))
+	for field, value := range item.Data {
+		data[field] = value
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(item.Key)
+
+	for _, token := range tokens {
+		if nil == idx.postings[token] {
+			idx.postings[token] = make(map[string]bool)
+		}
+
+		idx.postings[token][item.Key] = true
+	}
+
+	idx.tokens[item.Key] = tokens
+	idx.items[item.Key] = data
+}
+
+func (idx *Index) remove(key string) {
+	for _, token := range idx.tokens[key] {
+		delete(idx.postings[token], key)
+
+		if 0 == len(idx.postings[token]) {
+			delete(idx.postings, token)
+		}
+	}
+
+	delete(idx.tokens, key)
+	delete(idx.items, key)
+}
+
+func (idx *Index) itemTokens(item *scanItem.ScanItem) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+
+	add := func(value string) {
+		for _, token := range Tokens(value) {
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token)
+			}
+		}
+	}
+
+	add(item.Key)
+
+	if 0 == len(idx.fields) {
+		for _, value := range item.Data {
+			add(value)
+		}
+	} else {
+		for _, field := range idx.fields {
+			add(item.Data[field])
+		}
+	}
+
+	return tokens
+}
+
+// Search returns at most limit items matching every token of q, best first
+func (idx *Index) Search(q string, limit int) []Hit {
+	queryTokens := Tokens(q)
+
+	if 0 == len(queryTokens) {
+		return []Hit{}
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var scores map[string]int
+
+	for _, queryToken := range queryTokens {
+		tokenScores := idx.matchToken(queryToken)
+
+		if nil == scores {
+			scores = tokenScores
+			continue
+		}
+
+		for key := range scores {
+			if score, found := tokenScores[key]; found {
+				scores[key] += score
+			} else {
+				delete(scores, key)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for key, score := range scores {
+		hits = append(hits, Hit{Key: key, Score: score, Data: idx.items[key]})
+	}
+
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+
+		return hits[a].Key < hits[b].Key
+	})
+
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits
+}
+
+// matchToken scores items by the best indexed token matching queryToken
+func (idx *Index) matchToken(queryToken string) map[string]int {
+	scores := make(map[string]int)
+	typos := allowedTypos(queryToken)
+
+	for token, keys := range idx.postings {
+		score := 0
+
+		switch {
+		case token == queryToken:
+			score = scoreExact
+		case strings.HasPrefix(token, queryToken):
+			score = scorePrefix
+		case typos > 0 && withinDistance(token, queryToken, typos):
+			score = scoreTypo
+		default:
+			continue
+		}
+
+		for key := range keys {
+			if score > scores[key] {
+				scores[key] = score
+			}
+		}
+	}
+
+	return scores
+}
+
+// allowedTypos grows with the token length, short tokens must be typed right
+func allowedTypos(token string) int {
+	switch length := len([]rune(token)); {
+	case length >= 8:
+		return 2
+	case length >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,85 @@
+package search_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/app/search"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+)
+
+func hitKeys(hits []search.Hit) []string {
+	keys := []string{}
+
+	for _, hit := range hits {
+		keys = append(keys, hit.Key)
+	}
+
+	return keys
+}
+
+var _ = Describe("Search index\n", func() {
+	It("Fold removes Vietnamese diacritics\n", func() {
+		Expect(search.Fold("Nguyễn Thị Hồng")).To(Equal("nguyen thi hong"))
+		Expect(search.Fold("Đặng Văn Đức")).To(Equal("dang van duc"))
+	})
+
+	Context("Given an index over the Name field\n", func() {
+		var index *search.Index
+
+		BeforeEach(func() {
+			index = search.NewIndex([]string{"Name"})
+			index.Set(&scanItem.ScanItem{Key: "1", Data: map[string]string{"Name": "Nguyễn Thị Hồng", "Company": "Hong Ha"}})
+			index.Set(&scanItem.ScanItem{Key: "2", Data: map[string]string{"Name": "Nguyễn Hồng Nhung"}})
+			index.Set(&scanItem.ScanItem{Key: "3", Data: map[string]string{"Name": "Trần Văn Thịnh"}})
+		})
+
+		It("unaccented queries match, the closest names first\n", func() {
+			Expect(hitKeys(index.Search("nguyen thi hong", 10))).To(Equal([]string{"1"}))
+			Expect(hitKeys(index.Search("nguyen hong", 10))).To(Equal([]string{"1", "2"}))
+		})
+
+		It("token prefixes and typos match with a lower score\n", func() {
+			Expect(hitKeys(index.Search("thin", 10))).To(Equal([]string{"3", "1"}))
+			Expect(hitKeys(index.Search("nguyem nhung", 10))).To(Equal([]string{"2"}))
+
+			hits := index.Search("hong", 10)
+			Expect(hits[0].Score).To(BeNumerically(">", index.Search("nhun", 10)[0].Score))
+		})
+
+		It("fields which are not indexed do not match\n", func() {
+			Expect(index.Search("ha", 10)).To(BeEmpty())
+		})
+
+		It("an item set again is indexed under its new values only\n", func() {
+			index.Set(&scanItem.ScanItem{Key: "3", Data: map[string]string{"Name": "Lê Thu"}})
+
+			Expect(index.Search("thinh", 10)).To(BeEmpty())
+			Expect(hitKeys(index.Search("le thu", 10))).To(Equal([]string{"3"}))
+		})
+	})
+
+	Context("Given an indexed repository\n", func() {
+		It("items already stored and items set later are searchable\n", func() {
+			folder, _ := ioutil.TempDir("", "search")
+			defer func() { _ = os.RemoveAll(folder) }()
+
+			connection := memDb.NewMemDbConnection(folder)
+			stored, _ := connection.InitRepository("guests")
+			_, _ = stored.NewItem("101", map[string]string{"Name": "Phạm Minh Châu"})
+			stored.CloseDb()
+
+			ctx := context.Background()
+			repo, err := search.NewIndexedConnection(memDb.NewMemDbConnection(folder), nil).OpenRepository(ctx, "guests")
+			Expect(err).To(BeNil())
+			defer func() { _ = repo.CloseDb(ctx) }()
+
+			Expect(repo.SetItem(ctx, &scanItem.ScanItem{Key: "102", Data: map[string]string{"Name": "Võ Minh"}})).To(Succeed())
+
+			Expect(hitKeys(repo.(search.Searchable).Search("minh", 10))).To(Equal([]string{"101", "102"}))
+		})
+	})
+})
@@ -0,0 +1,91 @@
+package search
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+)
+
+// Searchable is implemented by repositories which keep a search index
+type Searchable interface {
+	Search(q string, limit int) []Hit
+}
+
+// IndexedConnection opens repositories which keep a search index in sync with their items
+type IndexedConnection struct {
+	service.DbConnectionInterface
+	fields []string
+}
+
+func NewIndexedConnection(connection service.DbConnectionInterface, fields []string) *IndexedConnection {
+	return &IndexedConnection{
+		DbConnectionInterface: connection,
+		fields:                fields,
+	}
+}
+
+func (c *IndexedConnection) InitRepository(name string) (scanItem.RepositoryInterface, error) {
+	return scanItem.LegacyOpen(c.OpenRepository(context.Background(), name))
+}
+
+// OpenRepository opens the repository and indexes the items it already holds
+func (c *IndexedConnection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	repo, err := c.DbConnectionInterface.OpenRepository(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := repo.Items(ctx)
+
+	if err != nil {
+		_ = repo.CloseDb(ctx)
+		return nil, err
+	}
+
+	indexed := &IndexedRepository{
+		RepositoryInterfaceV2: repo,
+		index:                 NewIndex(c.fields),
+	}
+
+	for _, item := range items {
+		indexed.index.Set(item)
+	}
+
+	return indexed, nil
+}
+
+// IndexedRepository is a repository whose item writes also update its search index
+type IndexedRepository struct {
+	scanItem.RepositoryInterfaceV2
+	index *Index
+}
+
+func (r *IndexedRepository) NewItem(ctx context.Context, itemKey string, data map[string]string) (*scanItem.ScanItem, error) {
+	item, err := r.RepositoryInterfaceV2.NewItem(ctx, itemKey, data)
+
+	if nil == err {
+		r.index.Set(item)
+	}
+
+	return item, err
+}
+
+// SetItem indexes the item once it is stored, an item the storage refused is not searchable
+func (r *IndexedRepository) SetItem(ctx context.Context, item *scanItem.ScanItem) error {
+	if err := r.RepositoryInterfaceV2.SetItem(ctx, item); err != nil {
+		return err
+	}
+
+	r.index.Set(item)
+
+	return nil
+}
+
+func (r *IndexedRepository) Search(q string, limit int) []Hit {
+	return r.index.Search(q, limit)
+}
+
+func (r *IndexedRepository) Unwrap() scanItem.RepositoryInterfaceV2 {
+	return r.RepositoryInterfaceV2
+}
@@ -0,0 +1,38 @@
+package sourceKeeper
+
+import (
+	"errors"
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"time"
+)
+
+var ErrNoSession = errors.New("no session of the agenda is running")
+
+func newAgenda(source config.DbSource) (scanItem.Agenda, error) {
+	agenda := scanItem.Agenda{Sessions: make([]scanItem.Session, 0, len(source.Agenda))}
+
+	for _, cfg := range source.Agenda {
+		session, err := scanItem.NewSession(cfg.Name, cfg.Activity, cfg.Room, cfg.Gateways, cfg.From, cfg.To)
+
+		if err != nil {
+			return scanItem.Agenda{}, fmt.Errorf("agenda: %w", err)
+		}
+
+		agenda.Sessions = append(agenda.Sessions, session)
+	}
+
+	return agenda, nil
+}
+
+// resolveSession finds the session of the agenda running at now for a scan at gateway
+func resolveSession(agenda scanItem.Agenda, gateway string, now time.Time) (*scanItem.Session, error) {
+	session, found := agenda.Resolve(gateway, now)
+
+	if !found {
+		return nil, fmt.Errorf("%w at gateway %q", ErrNoSession, gateway)
+	}
+
+	return &session, nil
+}
@@ -0,0 +1,99 @@
+package sourceKeeper_test
+
+import (
+	"context"
+	"errors"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+var _ = Describe("Keeper agenda\n", func() {
+	ctx := context.Background()
+	var folder string
+	var keeper *sourceKeeper.Keeper
+	var registry service.RepositoryRegistryInterface
+
+	at := func(offset time.Duration) string {
+		return time.Now().Add(offset).Format("2006-01-02 15:04:05")
+	}
+
+	guests := config.DbSource{
+		Name:        "guests",
+		IdField:     "code",
+		FetchingUrl: "http://localhost/fetch",
+		Agenda: []config.Session{
+			{Name: "Breakfast", Activity: "breakfast", From: at(-3 * time.Hour), To: at(-2 * time.Hour)},
+			{Name: "Keynote", Activity: "keynote", Room: "Main hall", Gateways: []string{"hall-1"}, From: at(-time.Hour), To: at(time.Hour)},
+			{Name: "Lunch", Activity: "lunch", From: at(-time.Hour), To: at(time.Hour)},
+		},
+	}
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "agenda")
+		registry = service.NewRepositoryRegistry(memDb.NewMemDbConnection(folder))
+		keeper = newKeeperOf(registry, guests)
+
+		repo, _ := registry.GetRepositoryV2(ctx, "guests")
+		_, _ = repo.NewItem(ctx, "100", map[string]string{"code": "100"})
+	})
+
+	AfterEach(func() {
+		registry.Shutdown()
+		_ = os.RemoveAll(folder)
+	})
+
+	It("records the activity of the session running at the gateway when the scan names none\n", func() {
+		result, found, err := keeper.Scan(ctx, "guests", "100", "", map[string]string{"gateway": "hall-1"})
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(result.Session).NotTo(BeNil())
+		Expect(result.Session.Name).To(Equal("Keynote"))
+		Expect(result.Activities[0].Action).To(Equal("keynote"))
+
+		By("the room name is a gateway of the session")
+		result, _, _ = keeper.Scan(ctx, "guests", "100", "", map[string]string{"gateway": "main hall"})
+		Expect(result.Session.Name).To(Equal("Keynote"))
+
+		By("a session held everywhere serves the other gateways")
+		result, _, _ = keeper.Scan(ctx, "guests", "100", "", map[string]string{"gateway": "lobby"})
+		Expect(result.Session.Name).To(Equal("Lunch"))
+		Expect(result.Activities[0].Action).To(Equal("lunch"))
+
+		By("a scan naming its activity ignores the agenda")
+		result, _, _ = keeper.Scan(ctx, "guests", "100", "checkin", map[string]string{"gateway": "hall-1"})
+		Expect(result.Session).To(BeNil())
+		Expect(result.Activities[0].Action).To(Equal("checkin"))
+	})
+
+	It("refuses a scan no running session resolves\n", func() {
+		talks := config.DbSource{
+			Name:    "talks",
+			IdField: "code",
+			Agenda: []config.Session{
+				{Name: "Keynote", Activity: "keynote", Room: "Main hall", From: at(-time.Hour), To: at(time.Hour)},
+			},
+		}
+		Expect(keeper.AddSource(talks)).To(Succeed())
+
+		_, found, err := keeper.Scan(ctx, "talks", "100", "", map[string]string{"gateway": "lobby"})
+		Expect(errors.Is(err, sourceKeeper.ErrNoSession)).To(BeTrue())
+		Expect(found).To(BeFalse())
+	})
+
+	It("refuses a source whose agenda is invalid\n", func() {
+		invalid := config.DbSource{
+			Name:    "invalid",
+			IdField: "code",
+			Agenda:  []config.Session{{Name: "Keynote", From: "09:00", To: "10:00"}},
+		}
+
+		Expect(keeper.AddSource(invalid)).NotTo(Succeed())
+	})
+})
@@ -2,39 +2,47 @@ package sourceKeeper
 
 import (
 	"errors"
-	"fmt"
 	"git.anphabe.net/event/anphabe-event-hub/config"
 	"git.anphabe.net/event/anphabe-event-hub/domain/model/dbSource"
 	"git.anphabe.net/event/anphabe-event-hub/infrastructure/jsonapiClient"
 	"go.uber.org/zap"
+	"sync/atomic"
 )
 
 type Communicator struct {
-	name          string
-	idField       string
-	source        *dbSource.DbSource
-	logger        *zap.Logger
-	importRunning bool
+	name    string
+	idField string
+	source  *dbSource.DbSource
+	logger  *zap.Logger
+	limiter *RateLimiter
+	// importRunning is read by status requests while an import sets it, 1 while importing
+	importRunning int32
 }
 
 func NewCommunicator(cfg config.DbSource, logger *zap.Logger) *Communicator {
 	return &Communicator{
-		name:          cfg.Name,
-		idField:       cfg.IdField,
-		source:        dbSource.NewDBSource(cfg),
-		importRunning: false,
-		logger:        logger,
+		name:    cfg.Name,
+		idField: cfg.IdField,
+		source:  dbSource.NewDBSource(cfg),
+		limiter: NewRateLimiter(cfg.RateLimit),
+		logger:  logger,
 	}
 }
 
+func (i *Communicator) IsImporting() bool {
+	return 1 == atomic.LoadInt32(&i.importRunning)
+}
+
+func (i *Communicator) GetRateLimiterState() RateLimiterState {
+	return i.limiter.State()
+}
+
 func (i *Communicator) Import(callback func(repoName string, idField string, data []map[string]string) int) error {
-	if i.importRunning {
+	if !atomic.CompareAndSwapInt32(&i.importRunning, 0, 1) {
 		return errors.New("there is an import currently importRunning")
-	} else {
-		i.importRunning = true
 	}
 
-	defer func() { i.importRunning = false }()
+	defer atomic.StoreInt32(&i.importRunning, 0)
 	i.logger.Info("Communicator: start import", zap.String("dbName", i.name))
 
 	progress := newFetchingProgress(i.source.GetFetchingUrl(0, 200))
@@ -42,6 +50,8 @@ func (i *Communicator) Import(callback func(repoName string, idField string, dat
 	var count int = 0
 
 	for progress.HasNext() {
+		i.limiter.WaitFetch()
+
 		if data, err := progress.FetchNext(); err == nil {
 			count += callback(i.name, i.idField, data)
 		} else {
@@ -55,16 +65,18 @@ func (i *Communicator) Import(callback func(repoName string, idField string, dat
 	return nil
 }
 
-func (i *Communicator) Update(key string, params map[string]string) bool {
+// Update pushes params of an item to upstream, the push only counts when
+// the response satisfies the source's success predicate
+func (i *Communicator) Update(key string, params map[string]string) error {
 	updateUrl := i.source.GetUpdateUrl(key, params)
-	fmt.Println(updateUrl)
+	i.logger.Debug("Communicator: push update", zap.String("dbName", i.name), zap.String("url", updateUrl))
+
+	i.limiter.WaitPush()
+	body, err := jsonapiClient.GetRaw(updateUrl)
 
-	if _, err := jsonapiClient.Get(updateUrl); nil == err {
-		fmt.Print(updateUrl)
-		return true
-	} else {
-		fmt.Println(err.Error())
+	if nil != err {
+		return err
 	}
 
-	return false
+	return i.source.VerifyUpdateResponse(body)
 }
@@ -70,6 +70,67 @@ var _ = Describe("Communicator\n", func() {
 	})
 })
 
+var _ = Describe("Communicator Update\n", func() {
+	domain := "http://stub-update.com"
+
+	newCommunicator := func(success config.UpdateSuccess) *sourceKeeper.Communicator {
+		stubLogger, _ := fakeLogger()
+
+		return sourceKeeper.NewCommunicator(config.DbSource{
+			Name:          "dbUpdate",
+			IdField:       "code",
+			UpdateUrl:     domain + "/checkin/%key%?method=POST",
+			UpdateMethod:  "GET",
+			UpdateSuccess: success,
+		}, stubLogger)
+	}
+
+	stubResponse := func(status int, body string) {
+		gock.New(domain).
+			Get("/checkin/101").
+			Reply(status).
+			BodyString(body)
+	}
+
+	AfterEach(func() {
+		gock.Off()
+	})
+
+	Context("Given no success predicate is configured\n", func() {
+		It("a response with data is acknowledged\n", func() {
+			stubResponse(http.StatusOK, `{"data":{"code":"101"}}`)
+			Expect(newCommunicator(config.UpdateSuccess{}).Update("101", nil)).To(Succeed())
+		})
+
+		It("a response without data is a failed push\n", func() {
+			stubResponse(http.StatusOK, `{"links":{}}`)
+			Expect(newCommunicator(config.UpdateSuccess{}).Update("101", nil)).NotTo(Succeed())
+		})
+
+		It("a non-2xx response is a failed push\n", func() {
+			stubResponse(http.StatusInternalServerError, `{"data":{}}`)
+			Expect(newCommunicator(config.UpdateSuccess{}).Update("101", nil)).NotTo(Succeed())
+		})
+	})
+
+	Context("Given the predicate requires status equal to ok\n", func() {
+		success := config.UpdateSuccess{Path: "result.status", Equals: "ok", ErrorPath: "result.message"}
+
+		It("a matching response is acknowledged\n", func() {
+			stubResponse(http.StatusOK, `{"result":{"status":"ok"}}`)
+			Expect(newCommunicator(success).Update("101", nil)).To(Succeed())
+		})
+
+		It("the upstream error message is reported\n", func() {
+			stubResponse(http.StatusOK, `{"result":{"status":"error","message":"Sheet is locked"}}`)
+			err := newCommunicator(success).Update("101", nil)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Sheet is locked"))
+		})
+	})
+})
+
 func getFakeDbSource1() fakeDbSource {
 	a := fakeDbSource{
 		Domain:      "http://stub1.com",
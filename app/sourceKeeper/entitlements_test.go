@@ -0,0 +1,86 @@
+package sourceKeeper_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+)
+
+var _ = Describe("Keeper ticket entitlements\n", func() {
+	ctx := context.Background()
+	var folder string
+	var keeper *sourceKeeper.Keeper
+	var registry service.RepositoryRegistryInterface
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "entitlements")
+
+		logger, _ := fakeLogger()
+		registry = service.NewRepositoryRegistry(memDb.NewMemDbConnection(folder))
+		keeper = sourceKeeper.NewSourceKeeper(nil, registry, logger)
+
+		Expect(keeper.AddSource(config.DbSource{
+			Name:        "guests",
+			IdField:     "code",
+			FetchingUrl: "http://localhost/fetch",
+			Entitlements: config.Entitlements{
+				Field: "ticket",
+				Types: map[string]map[string]int{
+					"VIP":      {"lounge": 0, "lunch": 2},
+					"Standard": {"lunch": 1},
+				},
+			},
+		})).To(Succeed())
+
+		repo, _ := registry.GetRepositoryV2(ctx, "guests")
+		_, _ = repo.NewItem(ctx, "101", map[string]string{"code": "101", "ticket": "Standard"})
+		_, _ = repo.NewItem(ctx, "102", map[string]string{"code": "102", "ticket": "VIP"})
+	})
+
+	AfterEach(func() {
+		registry.Shutdown()
+		_ = os.RemoveAll(folder)
+	})
+
+	It("denies activities the ticket type is not entitled to\n", func() {
+		result, found, err := keeper.Scan(ctx, "guests", "101", "lounge", nil)
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(result.Verdict.Rule).To(Equal(scanItem.RuleEntitlement))
+		Expect(result.Activities).To(BeEmpty())
+		Expect(result.Entitlements).To(Equal([]scanItem.Entitlement{{Action: "lunch", Quota: 1, Remaining: 1}}))
+
+		result, _, _ = keeper.Scan(ctx, "guests", "102", "lounge", nil)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+	})
+
+	It("counts the daily quota down and denies once it is used up\n", func() {
+		result, _, _ := keeper.Scan(ctx, "guests", "102", "lunch", nil)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+		Expect(result.Entitlements).To(ContainElement(scanItem.Entitlement{Action: "lunch", Quota: 2, Used: 1, Remaining: 1}))
+
+		result, _, _ = keeper.Scan(ctx, "guests", "102", "lunch", nil)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+
+		result, _, _ = keeper.Scan(ctx, "guests", "102", "lunch", nil)
+		Expect(result.Verdict.Rule).To(Equal(scanItem.RuleQuota))
+		Expect(result.Activities).To(HaveLen(2))
+		Expect(result.Entitlements).To(ContainElement(scanItem.Entitlement{Action: "lunch", Quota: 2, Used: 2}))
+	})
+
+	It("a source with entitlements but no ticket type field is refused\n", func() {
+		err := keeper.AddSource(config.DbSource{
+			Name:         "staff",
+			IdField:      "code",
+			Entitlements: config.Entitlements{Types: map[string]map[string]int{"VIP": {"lunch": 1}}},
+		})
+		Expect(err).NotTo(BeNil())
+	})
+})
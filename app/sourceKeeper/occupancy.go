@@ -0,0 +1,187 @@
+package sourceKeeper
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"sync"
+)
+
+// The occupancy of the zones of a source is loaded from the activities on first use, then moved by the scans.
+// The counts of a source are guarded by its scan lock, which its scans entering or leaving a zone hold.
+// i.scanMu only guards the maps of the locks and of the counts, scans of different sources do not wait on each other.
+
+// scanLock returns the lock serializing the scans of a source judged against its rules,
+// local edits and imports of the source hold it while they read, change and write an item
+func (i *Keeper) scanLock(repoName string) *sync.Mutex {
+	i.scanMu.Lock()
+	defer i.scanMu.Unlock()
+
+	lock, found := i.scanLocks[repoName]
+
+	if !found {
+		lock = &sync.Mutex{}
+		i.scanLocks[repoName] = lock
+	}
+
+	return lock
+}
+
+// sourceOccupancy returns the counts of a source, false until they are loaded
+func (i *Keeper) sourceOccupancy(repoName string) (map[string]int, bool) {
+	i.scanMu.Lock()
+	defer i.scanMu.Unlock()
+
+	counts, loaded := i.occupancy[repoName]
+
+	return counts, loaded
+}
+
+// GetOccupancy lists the zones of a source with how many items are inside and how many more they admit
+func (i *Keeper) GetOccupancy(ctx context.Context, repoName string) ([]scanItem.ZoneOccupancy, error) {
+	i.mu.RLock()
+	rules, found := i.rules[repoName]
+	i.mu.RUnlock()
+
+	if !found {
+		return nil, ErrUnknownSource
+	}
+
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	lock := i.scanLock(repoName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	counts, err := i.loadOccupancy(ctx, repoName, repo, rules.Zones)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]scanItem.ZoneOccupancy, 0, len(rules.Zones))
+
+	for _, zone := range rules.Zones {
+		result = append(result, zone.Occupancy(counts[zone.Name]))
+	}
+
+	return result, nil
+}
+
+// loadOccupancy needs the scan lock of the source held, it counts the items inside each zone the first time the source needs it
+func (i *Keeper) loadOccupancy(ctx context.Context, repoName string, repo scanItem.RepositoryInterfaceV2, zones []scanItem.Zone) (map[string]int, error) {
+	if counts, loaded := i.sourceOccupancy(repoName); loaded {
+		return counts, nil
+	}
+
+	counts := make(map[string]int, len(zones))
+
+	if len(zones) > 0 {
+		items, err := repo.Items(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			activities, err := repo.GetItemActivities(ctx, item.Key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if nil == activities {
+				continue
+			}
+
+			for _, zone := range zones {
+				if inside, _ := zone.Inside(activities.Activities); inside {
+					counts[zone.Name] += 1
+				}
+			}
+		}
+	}
+
+	i.scanMu.Lock()
+	i.occupancy[repoName] = counts
+	i.scanMu.Unlock()
+
+	return counts, nil
+}
+
+// admit needs the scan lock of the source held. It refuses a scan entering a full zone, or entering again an anti-passback zone,
+// otherwise it returns how the scan moves the occupancy of each zone:
+// an item entering a zone it is inside already, or leaving one it is not in, moves nothing.
+func (i *Keeper) admit(ctx context.Context, repoName string, repo scanItem.RepositoryInterfaceV2, zones []scanItem.Zone, activities []scanItem.ItemActivity, action string) (scanItem.Verdict, map[string]int, error) {
+	counts, err := i.loadOccupancy(ctx, repoName, repo, zones)
+
+	if err != nil {
+		return scanItem.Verdict{}, nil, err
+	}
+
+	moves := map[string]int{}
+
+	for _, zone := range zones {
+		inside, since := zone.Inside(activities)
+
+		if zone.Enters(action) && inside {
+			if verdict := zone.Reenter(since); !verdict.Allowed() {
+				return verdict, nil, nil
+			}
+		} else if zone.Enters(action) {
+			if verdict := zone.Admit(counts[zone.Name]); !verdict.Allowed() {
+				return verdict, nil, nil
+			}
+
+			moves[zone.Name] = 1
+		} else if zone.Leaves(action) && inside {
+			moves[zone.Name] = -1
+		}
+	}
+
+	return scanItem.Allowed(), moves, nil
+}
+
+// moveOccupancy needs the scan lock of the source held, moves come from admit so the occupancy of the source is loaded
+func (i *Keeper) moveOccupancy(repoName string, moves map[string]int) {
+	counts, loaded := i.sourceOccupancy(repoName)
+
+	if !loaded {
+		return
+	}
+
+	for zone, delta := range moves {
+		counts[zone] += delta
+	}
+}
+
+// zoneOccupancy needs the scan lock of the source held, it lists the zones action enters or leaves, none until the occupancy is loaded
+func (i *Keeper) zoneOccupancy(repoName string, zones []scanItem.Zone, action string) []scanItem.ZoneOccupancy {
+	counts, loaded := i.sourceOccupancy(repoName)
+
+	if !loaded {
+		return nil
+	}
+
+	var result []scanItem.ZoneOccupancy
+
+	for _, zone := range zones {
+		if zone.Enters(action) || zone.Leaves(action) {
+			result = append(result, zone.Occupancy(counts[zone.Name]))
+		}
+	}
+
+	return result
+}
+
+// forgetOccupancy needs the scan lock of the source held, it makes the occupancy load again
+// after activities were written around the scans
+func (i *Keeper) forgetOccupancy(repoName string) {
+	i.scanMu.Lock()
+	defer i.scanMu.Unlock()
+
+	delete(i.occupancy, repoName)
+}
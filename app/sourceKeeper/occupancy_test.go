@@ -0,0 +1,214 @@
+package sourceKeeper_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+)
+
+var _ = Describe("Keeper zone occupancy\n", func() {
+	ctx := context.Background()
+	var folder string
+	var keeper *sourceKeeper.Keeper
+	var registry service.RepositoryRegistryInterface
+
+	guests := config.DbSource{
+		Name:        "guests",
+		IdField:     "code",
+		FetchingUrl: "http://localhost/fetch",
+		Zones: map[string]config.Zone{
+			"workshop": {Capacity: 3, Entry: []string{"workshop-in"}, Exit: []string{"workshop-out"}},
+			"keynote":  {Capacity: 1, Entry: []string{"keynote"}},
+		},
+	}
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "occupancy")
+		registry = service.NewRepositoryRegistry(memDb.NewMemDbConnection(folder))
+		keeper = newKeeperOf(registry, guests)
+
+		repo, _ := registry.GetRepositoryV2(ctx, "guests")
+		for idx := 0; idx < 10; idx++ {
+			key := strconv.Itoa(100 + idx)
+			_, _ = repo.NewItem(ctx, key, map[string]string{"code": key})
+		}
+	})
+
+	AfterEach(func() {
+		registry.Shutdown()
+		_ = os.RemoveAll(folder)
+	})
+
+	workshop := func(keeper *sourceKeeper.Keeper) scanItem.ZoneOccupancy {
+		occupancy, err := keeper.GetOccupancy(ctx, "guests")
+		Expect(err).To(BeNil())
+		Expect(occupancy).To(HaveLen(2))
+
+		return occupancy[1]
+	}
+
+	It("admits concurrent entries up to the capacity and frees a seat on exit\n", func() {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		verdicts := map[string]int{}
+
+		for idx := 0; idx < 10; idx++ {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				result, _, _ := keeper.Scan(ctx, "guests", key, "workshop-in", nil)
+
+				mu.Lock()
+				verdicts[result.Verdict.Status] += 1
+				mu.Unlock()
+			}(strconv.Itoa(100 + idx))
+		}
+
+		wg.Wait()
+		Expect(verdicts).To(Equal(map[string]int{scanItem.VerdictAllowed: 3, scanItem.VerdictFull: 7}))
+		Expect(workshop(keeper)).To(Equal(scanItem.ZoneOccupancy{Zone: "workshop", Capacity: 3, Occupancy: 3}))
+
+		var inside string
+		for idx := 0; idx < 10 && "" == inside; idx++ {
+			item, _, _ := keeper.GetItemDetail(ctx, "guests", strconv.Itoa(100+idx))
+			if len(item.Activities) > 0 {
+				inside = item.Key
+			}
+		}
+
+		By("a second entry of an item inside does not take another seat")
+		result, _, _ := keeper.Scan(ctx, "guests", inside, "workshop-in", nil)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+		Expect(result.Occupancy).To(Equal([]scanItem.ZoneOccupancy{{Zone: "workshop", Capacity: 3, Occupancy: 3}}))
+
+		result, _, _ = keeper.Scan(ctx, "guests", inside, "workshop-out", nil)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+		Expect(result.Occupancy).To(Equal([]scanItem.ZoneOccupancy{{Zone: "workshop", Capacity: 3, Occupancy: 2, Remaining: 1}}))
+
+		By("the occupancy is loaded from the activities after a restart")
+		Expect(workshop(newKeeperOf(registry, guests))).To(Equal(scanItem.ZoneOccupancy{Zone: "workshop", Capacity: 3, Occupancy: 2, Remaining: 1}))
+	})
+
+	It("a zone without exit caps its entry activity\n", func() {
+		result, _, _ := keeper.Scan(ctx, "guests", "100", "keynote", nil)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+
+		result, _, _ = keeper.Scan(ctx, "guests", "101", "keynote", nil)
+		Expect(result.Verdict.Status).To(Equal(scanItem.VerdictFull))
+		Expect(result.Activities).To(BeEmpty())
+	})
+
+	It("scans are not held up by a full push queue, their activities wait in the outbox\n", func() {
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			// the broker is not started, nothing drains the push queue
+			for idx := 0; idx < 40; idx++ {
+				_, _, _ = keeper.Scan(ctx, "guests", "100", []string{"workshop-in", "workshop-out"}[idx%2], nil)
+			}
+		}()
+
+		Eventually(done, "5s").Should(BeClosed())
+
+		status, _ := keeper.GetSourceStatus("guests")
+		Expect(status.Outbox).To(Equal(40))
+	})
+
+	It("scans wait for a restore of their source and are checked against the restored occupancy\n", func() {
+		By("an archive of a hub where three guests entered the workshop")
+		otherFolder, _ := ioutil.TempDir("", "occupancy")
+		defer func() { _ = os.RemoveAll(otherFolder) }()
+
+		other := service.NewRepositoryRegistry(memDb.NewMemDbConnection(otherFolder))
+		defer other.Shutdown()
+
+		otherKeeper := newKeeperOf(other, guests)
+		otherRepo, _ := other.GetRepositoryV2(ctx, "guests")
+		for idx := 0; idx < 3; idx++ {
+			key := strconv.Itoa(200 + idx)
+			_, _ = otherRepo.NewItem(ctx, key, map[string]string{"code": key})
+
+			result, _, _ := otherKeeper.Scan(ctx, "guests", key, "workshop-in", nil)
+			Expect(result.Verdict.Allowed()).To(BeTrue())
+		}
+
+		var archived bytes.Buffer
+		_, err := otherKeeper.Backup(ctx, &archived)
+		Expect(err).To(BeNil())
+
+		zr, _ := gzip.NewReader(&archived)
+		content, _ := ioutil.ReadAll(zr)
+		lines := bytes.SplitAfter(bytes.TrimRight(content, "\n"), []byte("\n"))
+
+		Expect(workshop(keeper).Occupancy).To(Equal(0))
+
+		By("the restore stalls before the end of the archive")
+		pr, pw := io.Pipe()
+		zw := gzip.NewWriter(pw)
+		restored := make(chan error)
+
+		go func() {
+			_, err := keeper.Restore(ctx, bufio.NewReader(pr))
+			restored <- err
+		}()
+
+		_, _ = zw.Write(bytes.Join(lines[:len(lines)-1], nil))
+		_ = zw.Flush()
+
+		repo, _ := registry.GetRepositoryV2(ctx, "guests")
+		Eventually(func() int {
+			inside := 0
+			for idx := 0; idx < 3; idx++ {
+				if activities, _ := repo.GetItemActivities(ctx, strconv.Itoa(200+idx)); nil != activities {
+					inside += len(activities.Activities)
+				}
+			}
+			return inside
+		}, "5s").Should(Equal(3))
+
+		scanned := make(chan *sourceKeeper.ScanResult)
+		go func() {
+			result, _, _ := keeper.Scan(ctx, "guests", "100", "workshop-in", nil)
+			scanned <- result
+		}()
+
+		Consistently(scanned, "200ms").ShouldNot(Receive())
+
+		_, _ = zw.Write(lines[len(lines)-1])
+		_ = zw.Close()
+		_ = pw.Close()
+
+		Expect(<-restored).To(BeNil())
+		Expect((<-scanned).Verdict.Status).To(Equal(scanItem.VerdictFull))
+		Expect(workshop(keeper)).To(Equal(scanItem.ZoneOccupancy{Zone: "workshop", Capacity: 3, Occupancy: 3}))
+	})
+
+	It("an unknown source has no occupancy\n", func() {
+		_, err := keeper.GetOccupancy(ctx, "unknown")
+		Expect(err).To(Equal(sourceKeeper.ErrUnknownSource))
+	})
+})
+
+// newKeeperOf keeps source from the repositories of registry, as a restarted hub would
+func newKeeperOf(registry service.RepositoryRegistryInterface, source config.DbSource) *sourceKeeper.Keeper {
+	logger, _ := fakeLogger()
+	keeper := sourceKeeper.NewSourceKeeper(nil, registry, logger)
+	Expect(keeper.AddSource(source)).To(Succeed())
+
+	return keeper
+}
@@ -0,0 +1,130 @@
+package sourceKeeper
+
+import (
+	"context"
+	"errors"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"sort"
+	"time"
+)
+
+var ErrUnknownZone = errors.New("unknown zone")
+
+// PresentItem is an item inside a zone, with its data so a listing can name it
+type PresentItem struct {
+	Key  string
+	Data map[string]string
+	scanItem.Presence
+}
+
+// GetPresent lists the items inside the zones of a source which track presence, of zone only when it is not empty.
+// Items are sorted by zone then by how long they are inside, the longest first.
+func (i *Keeper) GetPresent(ctx context.Context, repoName string, zoneName string) ([]PresentItem, error) {
+	zones, err := i.presenceZones(repoName, zoneName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := repo.Items(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := []PresentItem{}
+
+	for _, item := range items {
+		activities, err := repo.GetItemActivities(ctx, item.Key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if nil == activities {
+			continue
+		}
+
+		for _, zone := range zones {
+			if presence := zone.Presence(activities.Activities, now); presence.Inside {
+				result = append(result, PresentItem{Key: item.Key, Data: item.Data, Presence: presence})
+			}
+		}
+	}
+
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Zone != result[b].Zone {
+			return result[a].Zone < result[b].Zone
+		}
+
+		return result[a].Since.Before(result[b].Since)
+	})
+
+	return result, nil
+}
+
+// GetPresence tells for each zone of a source which tracks presence whether the item is inside and how long it stayed
+func (i *Keeper) GetPresence(ctx context.Context, repoName string, itemKey string) ([]scanItem.Presence, bool, error) {
+	zones, err := i.presenceZones(repoName, "")
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	detail, found, err := repo.GetItemDetail(ctx, itemKey)
+
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	result := make([]scanItem.Presence, 0, len(zones))
+
+	for _, zone := range zones {
+		result = append(result, zone.Presence(detail.Activities, now))
+	}
+
+	return result, true, nil
+}
+
+// presenceZones returns the zones of a source which track presence, only zoneName when it is not empty
+func (i *Keeper) presenceZones(repoName string, zoneName string) ([]scanItem.Zone, error) {
+	i.mu.RLock()
+	rules, found := i.rules[repoName]
+	i.mu.RUnlock()
+
+	if !found {
+		return nil, ErrUnknownSource
+	}
+
+	var zones []scanItem.Zone
+
+	for _, zone := range rules.Zones {
+		if !zone.TracksPresence() {
+			continue
+		}
+
+		if "" == zoneName || zone.Name == zoneName {
+			zones = append(zones, zone)
+		}
+	}
+
+	if "" != zoneName && 0 == len(zones) {
+		return nil, ErrUnknownZone
+	}
+
+	return zones, nil
+}
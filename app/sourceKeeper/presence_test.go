@@ -0,0 +1,120 @@
+package sourceKeeper_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+var _ = Describe("Keeper presence\n", func() {
+	ctx := context.Background()
+	var folder string
+	var keeper *sourceKeeper.Keeper
+	var registry service.RepositoryRegistryInterface
+
+	guests := config.DbSource{
+		Name:        "guests",
+		IdField:     "code",
+		FetchingUrl: "http://localhost/fetch",
+		Zones: map[string]config.Zone{
+			"hall":     {Entry: []string{"hall-in"}, Exit: []string{"hall-out"}, AntiPassback: true},
+			"workshop": {Entry: []string{"workshop-in"}, Exit: []string{"workshop-out"}},
+			"keynote":  {Entry: []string{"keynote"}},
+		},
+	}
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "presence")
+		registry = service.NewRepositoryRegistry(memDb.NewMemDbConnection(folder))
+		keeper = newKeeperOf(registry, guests)
+
+		repo, _ := registry.GetRepositoryV2(ctx, "guests")
+		for idx := 0; idx < 3; idx++ {
+			key := strconv.Itoa(100 + idx)
+			_, _ = repo.NewItem(ctx, key, map[string]string{"code": key, "name": "guest " + key})
+		}
+	})
+
+	AfterEach(func() {
+		registry.Shutdown()
+		_ = os.RemoveAll(folder)
+	})
+
+	It("anti-passback refuses a second entry until the item exits\n", func() {
+		result, _, _ := keeper.Scan(ctx, "guests", "100", "hall-in", nil)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+		entered := result.Activities[0].Created
+
+		result, _, _ = keeper.Scan(ctx, "guests", "100", "hall-in", nil)
+		Expect(result.Verdict.Status).To(Equal(scanItem.VerdictAlreadyDone))
+		Expect(result.Verdict.Rule).To(Equal(scanItem.RulePassback))
+		Expect(result.Verdict.At).To(Equal(entered))
+		Expect(result.Activities).To(HaveLen(1))
+
+		result, _, _ = keeper.Scan(ctx, "guests", "100", "hall-out", nil)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+
+		result, _, _ = keeper.Scan(ctx, "guests", "100", "hall-in", nil)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+
+		By("a zone without anti-passback lets an item inside enter again")
+		_, _, _ = keeper.Scan(ctx, "guests", "100", "workshop-in", nil)
+		result, _, _ = keeper.Scan(ctx, "guests", "100", "workshop-in", nil)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+	})
+
+	It("lists the items inside the zones which track presence\n", func() {
+		_, _, _ = keeper.Scan(ctx, "guests", "100", "hall-in", nil)
+		_, _, _ = keeper.Scan(ctx, "guests", "101", "hall-in", nil)
+		_, _, _ = keeper.Scan(ctx, "guests", "101", "workshop-in", nil)
+		_, _, _ = keeper.Scan(ctx, "guests", "100", "hall-out", nil)
+		_, _, _ = keeper.Scan(ctx, "guests", "102", "keynote", nil)
+
+		present, err := keeper.GetPresent(ctx, "guests", "")
+		Expect(err).To(BeNil())
+		Expect(present).To(HaveLen(2))
+		Expect(present[0].Key).To(Equal("101"))
+		Expect(present[0].Zone).To(Equal("hall"))
+		Expect(present[0].Data["name"]).To(Equal("guest 101"))
+		Expect(present[1].Key).To(Equal("101"))
+		Expect(present[1].Zone).To(Equal("workshop"))
+
+		present, err = keeper.GetPresent(ctx, "guests", "workshop")
+		Expect(err).To(BeNil())
+		Expect(present).To(HaveLen(1))
+
+		_, err = keeper.GetPresent(ctx, "guests", "keynote")
+		Expect(err).To(Equal(sourceKeeper.ErrUnknownZone))
+
+		_, err = keeper.GetPresent(ctx, "unknown", "")
+		Expect(err).To(Equal(sourceKeeper.ErrUnknownSource))
+	})
+
+	It("tells the presence of an item with the time it stayed\n", func() {
+		_, _, _ = keeper.Scan(ctx, "guests", "100", "hall-in", nil)
+		_, _, _ = keeper.Scan(ctx, "guests", "100", "hall-out", nil)
+
+		presence, found, err := keeper.GetPresence(ctx, "guests", "100")
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(presence).To(HaveLen(2))
+		Expect(presence[0].Zone).To(Equal("hall"))
+		Expect(presence[0].Inside).To(BeFalse())
+		Expect(presence[0].Visits).To(Equal(1))
+		Expect(presence[0].Dwell).To(BeNumerically(">=", 0))
+		Expect(presence[1].Zone).To(Equal("workshop"))
+		Expect(presence[1].Visits).To(Equal(0))
+
+		_, found, err = keeper.GetPresence(ctx, "guests", "999")
+		Expect(err).To(BeNil())
+		Expect(found).To(BeFalse())
+	})
+})
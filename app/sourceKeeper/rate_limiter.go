@@ -0,0 +1,208 @@
+package sourceKeeper
+
+import (
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultFetchShare is the percentage of the upstream budget imports get when pushes compete, when none is configured
+const DefaultFetchShare = 25
+
+// RateLimiter shares the upstream quota of a source between pushes and imports.
+// Both take their tokens from one bucket, so the upstream never sees more than the budget.
+// Pushes go first, but while a fetch waits it gets a token after every few pushes so imports keep their share.
+type RateLimiter struct {
+	mu             sync.Mutex
+	bucket         *tokenBucket
+	pushesPerFetch int
+	pushWaiting    int
+	fetchWaiting   int
+	pushRun        int
+}
+
+// RateLimiterState is a snapshot of the limiter, tokens are -1 for an unlimited budget
+type RateLimiterState struct {
+	Tokens       float64
+	PushWaiting  int
+	FetchWaiting int
+	FetchBlocked bool
+}
+
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	perSec   float64
+	last     time.Time
+}
+
+func NewRateLimiter(cfg config.RateLimit) *RateLimiter {
+	perMinute := cfg.PerMinute
+
+	if perMinute <= 0 {
+		// older configurations budget each kind of traffic, their sum bounds the upstream
+		perMinute = cfg.PushPerMinute + cfg.FetchPerMinute
+	}
+
+	share := cfg.FetchShare
+
+	if share <= 0 || share > 100 {
+		share = DefaultFetchShare
+	}
+
+	return &RateLimiter{
+		bucket:         newTokenBucket(perMinute, cfg.Burst),
+		pushesPerFetch: int(math.Ceil(float64(100-share) / float64(share))),
+	}
+}
+
+func newTokenBucket(perMinute int, burst int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		perSec:   float64(perMinute) / 60,
+		last:     time.Now(),
+	}
+}
+
+// WaitPush blocks until a push may be sent
+func (l *RateLimiter) WaitPush() {
+	l.wait(&l.pushWaiting, l.takePush)
+}
+
+// WaitFetch blocks until a page may be fetched
+func (l *RateLimiter) WaitFetch() {
+	l.wait(&l.fetchWaiting, l.takeFetch)
+}
+
+// TryPush takes a token without waiting, it fails while a waiting fetch has its turn
+func (l *RateLimiter) TryPush() bool {
+	_, ok := l.takePush()
+	return ok
+}
+
+// TryFetch takes a token without waiting, it fails while pushes are waiting and imports had their share
+func (l *RateLimiter) TryFetch() bool {
+	_, ok := l.takeFetch()
+	return ok
+}
+
+func (l *RateLimiter) State() RateLimiterState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return RateLimiterState{
+		Tokens:       l.bucket.available(time.Now()),
+		PushWaiting:  l.pushWaiting,
+		FetchWaiting: l.fetchWaiting,
+		FetchBlocked: nil != l.bucket && l.pushWaiting > 0 && !l.fetchTurn(),
+	}
+}
+
+func (l *RateLimiter) wait(waiting *int, take func() (time.Duration, bool)) {
+	l.mu.Lock()
+	*waiting += 1
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		*waiting -= 1
+		l.mu.Unlock()
+	}()
+
+	for {
+		wait, ok := take()
+
+		if ok {
+			return
+		}
+
+		time.Sleep(wait)
+	}
+}
+
+func (l *RateLimiter) takePush() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if nil == l.bucket {
+		return 0, true
+	}
+
+	if l.fetchWaiting > 0 && l.fetchTurn() {
+		return 100 * time.Millisecond, false
+	}
+
+	wait, ok := l.bucket.take(time.Now())
+
+	if ok && l.fetchWaiting > 0 {
+		l.pushRun += 1
+	}
+
+	return wait, ok
+}
+
+func (l *RateLimiter) takeFetch() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if nil == l.bucket {
+		return 0, true
+	}
+
+	if l.pushWaiting > 0 && !l.fetchTurn() {
+		return 100 * time.Millisecond, false
+	}
+
+	wait, ok := l.bucket.take(time.Now())
+
+	if ok {
+		l.pushRun = 0
+	}
+
+	return wait, ok
+}
+
+// fetchTurn needs l.mu locked, it tells whether enough pushes went first for a waiting fetch to go
+func (l *RateLimiter) fetchTurn() bool {
+	return l.pushRun >= l.pushesPerFetch
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.perSec)
+	b.last = now
+}
+
+func (b *tokenBucket) take(now time.Time) (time.Duration, bool) {
+	if nil == b {
+		return 0, true
+	}
+
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens -= 1
+		return 0, true
+	}
+
+	return time.Duration((1 - b.tokens) / b.perSec * float64(time.Second)), false
+}
+
+func (b *tokenBucket) available(now time.Time) float64 {
+	if nil == b {
+		return -1
+	}
+
+	b.refill(now)
+
+	return b.tokens
+}
@@ -0,0 +1,97 @@
+package sourceKeeper_test
+
+import (
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sync"
+	"time"
+)
+
+var _ = Describe("RateLimiter\n", func() {
+	Context("Given no budget is configured\n", func() {
+		sut := sourceKeeper.NewRateLimiter(config.RateLimit{})
+
+		It("never limits\n", func() {
+			for n := 0; n < 100; n++ {
+				Expect(sut.TryPush()).To(BeTrue())
+				Expect(sut.TryFetch()).To(BeTrue())
+			}
+			Expect(sut.State().Tokens).To(Equal(float64(-1)))
+		})
+	})
+
+	Context("Given a burst of 2\n", func() {
+		It("pushes and fetches share one budget\n", func() {
+			sut := sourceKeeper.NewRateLimiter(config.RateLimit{PerMinute: 1, Burst: 2})
+
+			Expect(sut.TryPush()).To(BeTrue())
+			Expect(sut.TryFetch()).To(BeTrue())
+			Expect(sut.TryPush()).To(BeFalse())
+			Expect(sut.TryFetch()).To(BeFalse())
+			Expect(sut.State().Tokens).To(BeNumerically("~", 0, 0.01))
+		})
+
+		It("older push and fetch budgets are summed into the shared one\n", func() {
+			sut := sourceKeeper.NewRateLimiter(config.RateLimit{PushPerMinute: 1, FetchPerMinute: 1, Burst: 2})
+
+			Expect(sut.TryPush()).To(BeTrue())
+			Expect(sut.TryPush()).To(BeTrue())
+			Expect(sut.TryFetch()).To(BeFalse())
+		})
+
+		It("imports yield while a push is waiting\n", func() {
+			sut := sourceKeeper.NewRateLimiter(config.RateLimit{PerMinute: 600, Burst: 1})
+			Expect(sut.TryPush()).To(BeTrue())
+
+			done := make(chan struct{})
+			go func() {
+				sut.WaitPush()
+				close(done)
+			}()
+
+			Eventually(func() int { return sut.State().PushWaiting }).Should(Equal(1))
+			Expect(sut.TryFetch()).To(BeFalse())
+			Eventually(done, time.Second).Should(BeClosed())
+			Eventually(sut.TryFetch, time.Second).Should(BeTrue())
+		})
+	})
+
+	Context("Given pushes keep waiting\n", func() {
+		It("a waiting fetch still gets its share\n", func() {
+			sut := sourceKeeper.NewRateLimiter(config.RateLimit{PerMinute: 6000, Burst: 1, FetchShare: 50})
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+
+			for n := 0; n < 3; n++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+							sut.WaitPush()
+						}
+					}
+				}()
+			}
+
+			Eventually(func() int { return sut.State().PushWaiting }).Should(BeNumerically(">", 0))
+
+			fetched := make(chan struct{})
+			go func() {
+				for n := 0; n < 3; n++ {
+					sut.WaitFetch()
+				}
+				close(fetched)
+			}()
+
+			Eventually(fetched, 2*time.Second).Should(BeClosed())
+			close(stop)
+			wg.Wait()
+		})
+	})
+})
@@ -0,0 +1,262 @@
+package sourceKeeper
+
+import (
+	"context"
+	"errors"
+	"git.anphabe.net/event/anphabe-event-hub/app/auditLog"
+	"git.anphabe.net/event/anphabe-event-hub/app/itemHistory"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetentionAction is the audit log action of a retention run
+const RetentionAction = "retention"
+
+// What started a retention run
+const (
+	RetentionScheduled = "schedule"
+	RetentionOnDemand  = "admin"
+)
+
+var ErrNoRetention = errors.New("no retention policy is configured for the source")
+
+// RetentionReport counts what a retention run did, the activities are kept so attendance numbers survive
+type RetentionReport struct {
+	Source     string
+	Mode       string
+	Items      int
+	Anonymised int
+	Activities int
+}
+
+func newRetentionPolicy(source config.DbSource) (scanItem.RetentionPolicy, error) {
+	retention := source.Retention
+
+	return scanItem.NewRetentionPolicy(retention.After, retention.Mode, retention.Fields, retention.Salt)
+}
+
+// SetAuditLog sets where retention runs are recorded, a source with a recorded run is not imported anymore
+func (i *Keeper) SetAuditLog(store *auditLog.Store) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.auditLog = store
+
+	for name := range i.dbSources {
+		i.retained[name] = i.retained[name] || i.retentionApplied(name)
+	}
+}
+
+// retentionApplied tells whether the audit log holds a successful retention run of the source
+func (i *Keeper) retentionApplied(name string) bool {
+	if nil == i.auditLog {
+		return false
+	}
+
+	for _, entry := range i.auditLog.Entries(name) {
+		if RetentionAction == entry.Action && "" == entry.Details["error"] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsRetained tells whether the personal data of the source is removed or due to be,
+// such a source is not imported anymore: upstream would bring the data back
+func (i *Keeper) IsRetained(name string) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.isRetained(name, time.Now())
+}
+
+// isRetained needs i.mu locked
+func (i *Keeper) isRetained(name string, now time.Time) bool {
+	return i.retained[name] || i.retention[name].Due(now)
+}
+
+// dueRetention returns the sources whose retention date is past and which were not retained yet,
+// they are marked retained so the next tick does not start them again
+func (i *Keeper) dueRetention(now time.Time) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var due []string
+
+	for name, policy := range i.retention {
+		if policy.Due(now) && !i.retained[name] && !i.removing[name] {
+			i.retained[name] = true
+			due = append(due, name)
+		}
+	}
+
+	return due
+}
+
+func (i *Keeper) runScheduledRetention(name string, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if _, err := i.ApplyRetention(context.Background(), name, RetentionScheduled); err != nil {
+		// retried at the next tick
+		i.mu.Lock()
+		i.retained[name] = false
+		i.mu.Unlock()
+	}
+}
+
+// ApplyRetention removes the personal data of a source now, whatever its retention date.
+// Items are purged or anonymised by the policy and their kept versions are forgotten, activities are kept.
+// Imports of the source stop for good, the run is recorded in the audit log.
+func (i *Keeper) ApplyRetention(ctx context.Context, name string, trigger string) (RetentionReport, error) {
+	i.mu.Lock()
+	communicator, found := i.dbSources[name]
+	policy := i.retention[name]
+
+	if !found || i.removing[name] {
+		i.mu.Unlock()
+		return RetentionReport{}, ErrUnknownSource
+	}
+
+	if !policy.Enabled() {
+		i.mu.Unlock()
+		return RetentionReport{}, ErrNoRetention
+	}
+
+	i.retained[name] = true
+	i.mu.Unlock()
+
+	// an import still running would write the data back
+	deadline := time.Now().Add(DrainTimeout)
+	for time.Now().Before(deadline) && communicator.IsImporting() {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	report, err := i.anonymise(ctx, name, policy)
+	i.auditRetention(report, policy, trigger, err)
+
+	if err != nil {
+		i.logger.Error("Retention failed", zap.String("dbName", name), zap.Error(err))
+	} else {
+		i.logger.Info("Retention applied",
+			zap.String("dbName", name),
+			zap.String("mode", report.Mode),
+			zap.Int("items", report.Items),
+			zap.Int("anonymised", report.Anonymised),
+			zap.Int("activities", report.Activities))
+	}
+
+	return report, err
+}
+
+func (i *Keeper) anonymise(ctx context.Context, name string, policy scanItem.RetentionPolicy) (RetentionReport, error) {
+	report := RetentionReport{Source: name, Mode: policy.Mode}
+	repo, err := i.getRepository(ctx, name)
+
+	if err != nil {
+		return report, err
+	}
+
+	items, err := repo.Items(ctx)
+
+	if err != nil {
+		return report, err
+	}
+
+	keys := make([]string, 0, len(items))
+
+	for _, item := range items {
+		activities, err := repo.GetItemActivities(ctx, item.Key)
+
+		if err != nil {
+			return report, err
+		}
+
+		if nil != activities {
+			report.Activities += len(activities.Activities)
+		}
+
+		if anonymised, changed := item.Anonymise(policy); changed {
+			if err := repo.SetItem(ctx, anonymised); err != nil {
+				return report, err
+			}
+
+			report.Anonymised += 1
+		}
+
+		report.Items += 1
+		keys = append(keys, item.Key)
+	}
+
+	// every item is forgotten, a run stopped by an error left earlier items anonymised but maybe not forgotten
+	for _, layer := range scanItem.Layers(repo) {
+		if forgetter, ok := layer.(itemHistory.Forgetter); ok {
+			return report, forgetter.Forget(keys)
+		}
+	}
+
+	return report, nil
+}
+
+func (i *Keeper) auditRetention(report RetentionReport, policy scanItem.RetentionPolicy, trigger string, runErr error) {
+	i.mu.RLock()
+	store := i.auditLog
+	i.mu.RUnlock()
+
+	if nil == store {
+		i.logger.Warn("Retention run not audited, no audit log is set", zap.String("dbName", report.Source))
+		return
+	}
+
+	fields := make([]string, 0, len(policy.Fields))
+	for field, treatment := range policy.Fields {
+		fields = append(fields, field+":"+treatment)
+	}
+
+	sort.Strings(fields)
+
+	details := map[string]string{
+		"mode":       policy.Mode,
+		"items":      strconv.Itoa(report.Items),
+		"anonymised": strconv.Itoa(report.Anonymised),
+		"activities": strconv.Itoa(report.Activities),
+	}
+
+	if len(fields) > 0 {
+		details["fields"] = strings.Join(fields, ",")
+	}
+
+	if nil != runErr {
+		details["error"] = runErr.Error()
+	}
+
+	_, err := store.Append(auditLog.Entry{
+		Source:  report.Source,
+		Action:  RetentionAction,
+		Trigger: trigger,
+		Details: details,
+	})
+
+	if err != nil {
+		i.logger.Error("Could not audit retention run", zap.String("dbName", report.Source), zap.Error(err))
+	}
+}
+
+// GetAuditLog returns the audit log entries of a source, of every source when name is empty
+func (i *Keeper) GetAuditLog(name string) []auditLog.Entry {
+	i.mu.RLock()
+	store := i.auditLog
+	i.mu.RUnlock()
+
+	if nil == store {
+		return []auditLog.Entry{}
+	}
+
+	return store.Entries(name)
+}
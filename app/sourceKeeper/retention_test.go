@@ -0,0 +1,143 @@
+package sourceKeeper_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/app/auditLog"
+	"git.anphabe.net/event/anphabe-event-hub/app/itemHistory"
+	"git.anphabe.net/event/anphabe-event-hub/app/search"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("Keeper retention of personal data\n", func() {
+	ctx := context.Background()
+	var folder string
+	var audit *auditLog.Store
+	var keeper *sourceKeeper.Keeper
+	var registry service.RepositoryRegistryInterface
+
+	newKeeper := func() *sourceKeeper.Keeper {
+		logger, _ := fakeLogger()
+		connection := itemHistory.NewHistoryConnection(
+			search.NewIndexedConnection(memDb.NewMemDbConnection(folder), []string{"name"}),
+			filepath.Join(folder, "history"), 0, nil, logger)
+
+		registry = service.NewRepositoryRegistry(connection)
+		audit, _ = auditLog.OpenStore(filepath.Join(folder, "audit.log"))
+
+		keeper := sourceKeeper.NewSourceKeeper(nil, registry, logger)
+		keeper.SetAuditLog(audit)
+
+		return keeper
+	}
+
+	guests := func(retention config.Retention) config.DbSource {
+		return config.DbSource{Name: "guests", IdField: "code", FetchingUrl: "http://localhost/fetch", Retention: retention}
+	}
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "retention")
+		keeper = newKeeper()
+	})
+
+	AfterEach(func() {
+		registry.Shutdown()
+		_ = audit.Close()
+		_ = os.RemoveAll(folder)
+	})
+
+	It("anonymises the configured fields, keeps the activities and audits the run\n", func() {
+		Expect(keeper.AddSource(guests(config.Retention{
+			Mode:   "anonymise",
+			Fields: map[string]string{"name": "redact", "email": "hash"},
+		}))).To(Succeed())
+
+		repo, _ := registry.GetRepositoryV2(ctx, "guests")
+		_, _ = repo.NewItem(ctx, "101", map[string]string{"code": "101", "name": "Jane Doe", "email": "jane@example.com"})
+		_ = repo.SetItem(ctx, &scanItem.ScanItem{Key: "101", Data: map[string]string{"code": "101", "name": "Jane M. Doe", "email": "jane@example.com"}})
+		_, _, _ = keeper.ScanItem(ctx, "guests", "101", "checkin", nil)
+		_, _, _ = keeper.ScanItem(ctx, "guests", "101", "lunch", nil)
+
+		report, err := keeper.ApplyRetention(ctx, "guests", sourceKeeper.RetentionOnDemand)
+		Expect(err).To(BeNil())
+		Expect(report).To(Equal(sourceKeeper.RetentionReport{Source: "guests", Mode: "anonymise", Items: 1, Anonymised: 1, Activities: 2}))
+
+		item, found, _ := keeper.GetItemDetail(ctx, "guests", "101")
+		Expect(found).To(BeTrue())
+		Expect(item.Data["name"]).To(Equal(scanItem.Redacted))
+		Expect(item.Data["email"]).To(HavePrefix("sha256:"))
+		Expect(item.Data["code"]).To(Equal("101"))
+		Expect(item.Activities).To(HaveLen(2))
+
+		history, _, _ := keeper.GetItemHistory(ctx, "guests", "101")
+		Expect(history).To(BeEmpty())
+
+		hits, _, _ := keeper.Search(ctx, "guests", "jane", 10)
+		Expect(hits).To(BeEmpty())
+
+		entries := keeper.GetAuditLog("guests")
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Action).To(Equal(sourceKeeper.RetentionAction))
+		Expect(entries[0].Trigger).To(Equal(sourceKeeper.RetentionOnDemand))
+		Expect(entries[0].Details).To(HaveKeyWithValue("fields", "email:hash,name:redact"))
+		Expect(entries[0].Details).To(HaveKeyWithValue("activities", "2"))
+
+		By("the source is not imported anymore, also after a restart")
+		Expect(keeper.StartImport("guests")).To(BeFalse())
+		status, _ := keeper.GetSourceStatus("guests")
+		Expect(status.Retained).To(BeTrue())
+
+		registry.Shutdown()
+		_ = audit.Close()
+
+		restarted := newKeeper()
+		Expect(restarted.AddSource(guests(config.Retention{Mode: "purge"}))).To(Succeed())
+		Expect(restarted.IsRetained("guests")).To(BeTrue())
+	})
+
+	It("purges every item\n", func() {
+		Expect(keeper.AddSource(guests(config.Retention{Mode: "purge"}))).To(Succeed())
+
+		repo, _ := registry.GetRepositoryV2(ctx, "guests")
+		_, _ = repo.NewItem(ctx, "101", map[string]string{"code": "101", "name": "Jane Doe"})
+		_, _ = repo.NewItem(ctx, "102", map[string]string{"code": "102", "name": "John Doe"})
+
+		report, err := keeper.ApplyRetention(ctx, "guests", sourceKeeper.RetentionOnDemand)
+		Expect(err).To(BeNil())
+		Expect(report.Anonymised).To(Equal(2))
+
+		items, _ := keeper.GetItems(ctx, "guests")
+		Expect(items).To(HaveLen(2))
+		for _, item := range items {
+			Expect(item.Data).To(BeEmpty())
+		}
+	})
+
+	It("a past retention date stops imports until the scheduled run\n", func() {
+		Expect(keeper.AddSource(guests(config.Retention{After: "2019-10-01", Mode: "purge"}))).To(Succeed())
+
+		Expect(keeper.IsRetained("guests")).To(BeTrue())
+		Expect(keeper.StartImport("guests")).To(BeFalse())
+	})
+
+	It("refuses sources without policy and invalid policies\n", func() {
+		Expect(keeper.AddSource(guests(config.Retention{}))).To(Succeed())
+
+		_, err := keeper.ApplyRetention(ctx, "guests", sourceKeeper.RetentionOnDemand)
+		Expect(err).To(Equal(sourceKeeper.ErrNoRetention))
+
+		_, err = keeper.ApplyRetention(ctx, "staff", sourceKeeper.RetentionOnDemand)
+		Expect(err).To(Equal(sourceKeeper.ErrUnknownSource))
+
+		err = keeper.AddSource(config.DbSource{Name: "staff", IdField: "code", Retention: config.Retention{Mode: "shred"}})
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -1,11 +1,17 @@
 package sourceKeeper
 
 import (
-	"fmt"
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/app/archive"
+	"git.anphabe.net/event/anphabe-event-hub/app/auditLog"
+	"git.anphabe.net/event/anphabe-event-hub/app/eventLog"
+	"git.anphabe.net/event/anphabe-event-hub/app/itemHistory"
+	"git.anphabe.net/event/anphabe-event-hub/app/search"
 	"git.anphabe.net/event/anphabe-event-hub/config"
 	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
 	"git.anphabe.net/event/anphabe-event-hub/domain/service"
 	"go.uber.org/zap"
+	"io"
 	"strings"
 	"sync"
 	"time"
@@ -13,21 +19,74 @@ import (
 
 const SyncTime time.Duration = 5 * time.Minute
 
+// A failed push is retried after a backoff doubling from PushBackoff up to MaxPushBackoff,
+// after PushAttempts attempts it is given up and stays failed in the repository
+const (
+	PushAttempts   = 8
+	PushBackoff    = 2 * time.Second
+	MaxPushBackoff = 5 * time.Minute
+)
+
+// EditActivity is the activity recording local edits of item fields
+const EditActivity = "edit"
+
+// ScanListener is notified after an activity of a scan has been stored
+type ScanListener interface {
+	OnScan(repoName string, item *scanItem.ItemDetail, activity scanItem.ItemActivity)
+}
+
+// Keeper is safe for concurrent use, mu guards the sources and their state
 type Keeper struct {
-	conf         []config.DbSource
-	repoRegistry service.RepositoryRegistryInterface
-	dbSources    map[string]*Communicator
-	nextImports  map[string]time.Time
-	stop         chan struct{}
-	importChan   chan string
-	activityChan chan *activityLog
-	logger       *zap.Logger
+	mu             sync.RWMutex
+	conf           []config.DbSource
+	repoRegistry   service.RepositoryRegistryInterface
+	openConnection ConnectionFactory
+	dbSources      map[string]*Communicator
+	mergePolicy    map[string]scanItem.MergePolicy
+	retention      map[string]scanItem.RetentionPolicy
+	retained       map[string]bool
+	rules          map[string]scanItem.ScanRules
+	agendas        map[string]scanItem.Agenda
+	scanMu         sync.Mutex
+	scanLocks      map[string]*sync.Mutex
+	occupancy      map[string]map[string]int
+	auditLog       *auditLog.Store
+	nextImports    map[string]time.Time
+	lastImports    map[string]time.Time
+	paused         map[string]bool
+	removing       map[string]bool
+	removed        map[string]bool
+	outbox         map[string]int
+	held           map[string][]activityLog
+	pushAttempts   int
+	pushBackoff    time.Duration
+	stop           chan struct{}
+	quit           chan struct{}
+	importChan     chan string
+	activityChan   chan *activityLog
+	listeners      []ScanListener
+	logger         *zap.Logger
+}
+
+// SourceStatus describes the runtime state of a source, Outbox counts the activities waiting to be pushed.
+// LastImport is when the last complete import since start finished, zero when none did.
+type SourceStatus struct {
+	Name       string
+	Importing  bool
+	Paused     bool
+	Retained   bool
+	Outbox     int
+	LastImport time.Time
+	NextImport time.Time
+	RateLimit  RateLimiterState
 }
 
 type activityLog struct {
-	repoName string
-	itemKey  string
-	activity scanItem.ItemActivity
+	repoName  string
+	itemKey   string
+	activity  scanItem.ItemActivity
+	attempts  int
+	lastError string
 }
 
 func NewSourceKeeper(cfg []config.DbSource, registry service.RepositoryRegistryInterface, logger *zap.Logger) *Keeper {
@@ -36,8 +95,24 @@ func NewSourceKeeper(cfg []config.DbSource, registry service.RepositoryRegistryI
 		conf: cfg,
 		repoRegistry: registry,
 		dbSources:    make(map[string]*Communicator),
+		mergePolicy:  make(map[string]scanItem.MergePolicy),
+		retention:    make(map[string]scanItem.RetentionPolicy),
+		retained:     make(map[string]bool),
+		rules:        make(map[string]scanItem.ScanRules),
+		agendas:      make(map[string]scanItem.Agenda),
+		scanLocks:    make(map[string]*sync.Mutex),
+		occupancy:    make(map[string]map[string]int),
 		nextImports:  make(map[string]time.Time),
+		lastImports:  make(map[string]time.Time),
+		paused:       make(map[string]bool),
+		removing:     make(map[string]bool),
+		removed:      make(map[string]bool),
+		outbox:       make(map[string]int),
+		held:         make(map[string][]activityLog),
+		pushAttempts: PushAttempts,
+		pushBackoff:  PushBackoff,
 		stop:         make(chan struct{}, 1),
+		quit:         make(chan struct{}),
 		activityChan: make(chan *activityLog, 30),
 		importChan:   make(chan string, 2),
 		logger:       logger,
@@ -46,15 +121,33 @@ func NewSourceKeeper(cfg []config.DbSource, registry service.RepositoryRegistryI
 	return instance
 }
 
+func (i *Keeper) AddScanListener(listener ScanListener) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.listeners = append(i.listeners, listener)
+}
+
 func (i *Keeper) Stop() {
 	i.stop <- struct{}{}
 }
 
 func (i *Keeper) init() {
-	for _, cfgDbSource := range i.conf {
-		_, _ = i.repoRegistry.GetRepository(cfgDbSource.Name)
-		i.updateNextImport(cfgDbSource.Name)
-		i.dbSources[cfgDbSource.Name] = NewCommunicator(cfgDbSource, i.logger)
+	i.mu.RLock()
+	sources := append([]config.DbSource(nil), i.conf...)
+	i.mu.RUnlock()
+
+	for _, cfgDbSource := range sources {
+		// data which can not be read must stop the hub, an empty start would overwrite it
+		if _, err := i.repoRegistry.GetRepositoryV2(context.Background(), cfgDbSource.Name); err != nil {
+			panic("could not open repository " + cfgDbSource.Name + ": " + err.Error())
+		}
+
+		if err := validateSource(cfgDbSource); err != nil {
+			panic("source " + cfgDbSource.Name + ": " + err.Error())
+		}
+
+		i.registerSource(cfgDbSource)
 	}
 }
 
@@ -68,6 +161,8 @@ func (i *Keeper) Start(wg *sync.WaitGroup) {
 
 		defer func() {
 			tick.Stop()
+			// pushes waiting for a retry give up, their activities stay failed in the repository
+			close(i.quit)
 			wgChild.Wait()
 
 			close(i.stop)
@@ -99,6 +194,11 @@ func (i *Keeper) Start(wg *sync.WaitGroup) {
 					if repoName := i.pickImportSource(); repoName != "" {
 						i.importChan <- repoName
 					}
+
+					for _, repoName := range i.dueRetention(time.Now()) {
+						wgChild.Add(1)
+						go i.runScheduledRetention(repoName, &wgChild)
+					}
 				}
 
 			case repoName, ok := <-i.importChan:
@@ -114,8 +214,12 @@ func (i *Keeper) Start(wg *sync.WaitGroup) {
 }
 
 func (i *Keeper) StartImport(repoName string) bool {
+	if i.IsPaused(repoName) || i.isRemoved(repoName) || i.IsRetained(repoName) {
+		return false
+	}
+
 	if _, err := i.repoRegistry.GetRepository(repoName); nil == err {
-		fmt.Println(repoName)
+		i.logger.Info("Import requested", zap.String("dbName", repoName))
 		i.importChan <- repoName
 		return true
 	}
@@ -123,67 +227,455 @@ func (i *Keeper) StartImport(repoName string) bool {
 	return false
 }
 
-func (i *Keeper) GetItemDetail(repoName string, itemKey string) (*scanItem.ItemDetail, bool) {
-	return i.getRepository(repoName).GetItemDetail(itemKey)
+// GetItemDetail returns an item with its activities, false when the item or the source does not exist
+func (i *Keeper) GetItemDetail(ctx context.Context, repoName string, itemKey string) (*scanItem.ItemDetail, bool, error) {
+	if i.isRemoved(repoName) {
+		return nil, false, nil
+	}
+
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return repo.GetItemDetail(ctx, itemKey)
+}
+
+func (i *Keeper) GetItems(ctx context.Context, repoName string) ([]*scanItem.ScanItem, error) {
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.Items(ctx)
+}
+
+func (i *Keeper) QueryItems(ctx context.Context, repoName string, query scanItem.Query) (scanItem.QueryResult, error) {
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return scanItem.QueryResult{}, err
+	}
+
+	return repo.Query(ctx, query)
+}
+
+// Search ranks the items of a repository against q, false when the repository keeps no search index
+func (i *Keeper) Search(ctx context.Context, repoName string, q string, limit int) ([]search.Hit, bool, error) {
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, layer := range scanItem.Layers(repo) {
+		if searchable, ok := layer.(search.Searchable); ok {
+			return searchable.Search(q, limit), true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// GetEvents returns activities of a repository in the order they were recorded, false when it keeps no event log
+func (i *Keeper) GetEvents(ctx context.Context, repoName string, query eventLog.Query) ([]eventLog.Event, uint64, bool, error) {
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	for _, layer := range scanItem.Layers(repo) {
+		if logged, ok := layer.(eventLog.Logged); ok {
+			return logged.Events(query), logged.LastSeq(), true, nil
+		}
+	}
+
+	return nil, 0, false, nil
 }
 
-func (i *Keeper) GetItems(repoName string) []*scanItem.ScanItem {
-	return i.getRepository(repoName).Items()
+// GetItemHistory returns the kept versions of an item, latest first, false when the repository keeps none
+func (i *Keeper) GetItemHistory(ctx context.Context, repoName string, itemKey string) ([]itemHistory.Entry, bool, error) {
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, layer := range scanItem.Layers(repo) {
+		if recorder, ok := layer.(itemHistory.Recorder); ok {
+			return recorder.History(itemKey), true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+func (i *Keeper) GetSourceStatus(repoName string) (*SourceStatus, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	communicator, found := i.dbSources[repoName]
+
+	if !found {
+		return nil, false
+	}
+
+	return &SourceStatus{
+		Name:       repoName,
+		Importing:  communicator.IsImporting(),
+		Paused:     i.paused[repoName],
+		Retained:   i.isRetained(repoName, time.Now()),
+		Outbox:     i.outbox[repoName],
+		LastImport: i.lastImports[repoName],
+		NextImport: i.nextImports[repoName],
+		RateLimit:  communicator.GetRateLimiterState(),
+	}, true
+}
+
+func (i *Keeper) GetRepositoryNames() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	names := make([]string, 0, len(i.conf))
+
+	for _, cfgDbSource := range i.conf {
+		names = append(names, cfgDbSource.Name)
+	}
+
+	return names
+}
+
+// Backup writes an archive of every configured repository
+func (i *Keeper) Backup(ctx context.Context, w io.Writer) (archive.Stats, error) {
+	var repos []scanItem.RepositoryInterfaceV2
+
+	for _, name := range i.GetRepositoryNames() {
+		repo, err := i.getRepository(ctx, name)
+
+		if err != nil {
+			return archive.Stats{}, err
+		}
+
+		repos = append(repos, repo)
+	}
+
+	return archive.Export(ctx, w, repos)
+}
+
+// Restore loads an archive into the repositories of the running hub.
+// The scans of a restored source wait until the archive is loaded, then its occupancy loads again.
+func (i *Keeper) Restore(ctx context.Context, r io.Reader) (archive.Stats, error) {
+	held := make(map[string]*sync.Mutex)
+
+	defer func() {
+		for repoName, lock := range held {
+			i.forgetOccupancy(repoName)
+			lock.Unlock()
+		}
+	}()
+
+	return archive.Import(ctx, r, func(ctx context.Context, repoName string) (scanItem.RepositoryInterfaceV2, error) {
+		repo, err := i.getRepository(ctx, repoName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		lock := i.scanLock(repoName)
+		lock.Lock()
+		held[repoName] = lock
+
+		return repo, nil
+	})
+}
+
+// GetSyncSummary counts the activities of a repository which have not reached upstream yet
+func (i *Keeper) GetSyncSummary(ctx context.Context, repoName string) (scanItem.SyncSummary, error) {
+	summary := scanItem.SyncSummary{}
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return summary, err
+	}
+
+	items, err := repo.Items(ctx)
+
+	if err != nil {
+		return summary, err
+	}
+
+	for _, item := range items {
+		activities, err := repo.GetItemActivities(ctx, item.Key)
+
+		if err != nil {
+			return summary, err
+		}
+
+		if nil != activities {
+			for _, activity := range activities.Activities {
+				summary.Add(activity)
+			}
+		}
+	}
+
+	return summary, nil
 }
 
-func (i *Keeper) ScanItem(repoName string, itemKey string, activityName string, properties map[string]string) (*scanItem.ItemDetail, bool) {
-	i.addItemActivity(repoName, itemKey, activityName, properties)
-	return i.GetItemDetail(repoName, itemKey)
+// ScanItem records an activity of an item when the scan rules of the source allow it,
+// false when the item or the source does not exist
+func (i *Keeper) ScanItem(ctx context.Context, repoName string, itemKey string, activityName string, properties map[string]string) (*scanItem.ItemDetail, bool, error) {
+	result, found, err := i.Scan(ctx, repoName, itemKey, activityName, properties)
+
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	return result.ItemDetail, true, nil
 }
 
-func (i *Keeper) addItemActivity(repoName string, itemKey string, action string, properties map[string]string) *scanItem.ItemActivities {
-	if _, found := i.GetItemDetail(repoName, itemKey); found {
-		activity := scanItem.NewActivity(action, properties)
-		activityLog := &activityLog{
-			repoName: repoName,
-			itemKey:  itemKey,
-			activity: activity,
+// SetLocalFields edits fields of an item at the hub. The edit is stored as an "edit" activity
+// which is pushed upstream, the fields survive imports according to the source's merge policy.
+func (i *Keeper) SetLocalFields(ctx context.Context, repoName string, itemKey string, fields map[string]string) (*scanItem.ItemDetail, bool, error) {
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	// an import of the source must not merge the item between the read and the write of the edit
+	lock := i.scanLock(repoName)
+	lock.Lock()
+
+	item, found, err := repo.GetItem(ctx, itemKey)
+
+	if found && nil == err {
+		// the repository may hand out the item it keeps, the edit is made on a copy
+		item = item.Clone()
+		now := time.Now()
+
+		for field, value := range fields {
+			item.SetLocalField(field, value, now)
 		}
 
-		i.activityChan <- activityLog
+		err = repo.SetItem(ctx, item)
+	}
+
+	lock.Unlock()
+
+	if !found || err != nil {
+		return nil, false, err
+	}
+
+	// an edit is not a scan, the scan rules do not apply
+	result, found, err := i.scan(ctx, repoName, itemKey, EditActivity, fields, scanItem.ScanRules{})
 
-		return i.getRepository(repoName).AddItemActivity(itemKey, activity)
+	if err != nil || !found {
+		return nil, false, err
 	}
 
-	return nil
+	return result.ItemDetail, true, nil
+}
+
+// addItemActivity stores the activity, the caller queues it for its source with enqueue once it holds no lock
+func (i *Keeper) addItemActivity(ctx context.Context, repoName string, itemKey string, action string, properties map[string]string) (scanItem.ItemActivity, bool, error) {
+	if !i.accepts(repoName) {
+		return scanItem.ItemActivity{}, false, nil
+	}
+
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return scanItem.ItemActivity{}, false, err
+	}
+
+	activity := scanItem.NewActivity(action, properties)
+	activities, err := repo.AddItemActivity(ctx, itemKey, activity)
+
+	if nil == activities || nil != err {
+		return scanItem.ItemActivity{}, false, err
+	}
+
+	return activity, true, nil
 }
 
 func (i *Keeper) pushActivityToSource(log activityLog, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	// convert action-moment into string
-	properties := log.activity.Data
+	// convert action-moment into string, on a copy as the activity is shared with listeners
+	properties := make(map[string]string, len(log.activity.Data)+1)
+	for key, value := range log.activity.Data {
+		properties[key] = value
+	}
 	properties[log.activity.Action] = log.activity.Created.Format("2 Jan 2006 15:04:05")
 
-	dbSource := i.dbSources[log.repoName]
-	finish := dbSource.Update(log.itemKey, properties)
+	i.mu.Lock()
+	dbSource, found := i.dbSources[log.repoName]
+	paused := i.paused[log.repoName]
+
+	if found && paused {
+		// kept in the outbox until the source is resumed
+		i.held[log.repoName] = append(i.held[log.repoName], log)
+	}
+	i.mu.Unlock()
 
-	if finish {
+	if !found {
+		i.dequeue(log.repoName)
+		return
+	}
+
+	if paused {
+		return
+	}
+
+	err := dbSource.Update(log.itemKey, properties)
+
+	sync := scanItem.ActivitySync{
+		Status:   scanItem.SyncSent,
+		Attempts: log.attempts + 1,
+		Updated:  time.Now(),
+	}
+
+	attempts, backoff := i.pushRetry()
+
+	// an activity is failed once no attempt is left, until then it is retrying
+	if nil != err && !i.isRemoving(log.repoName) && sync.Attempts < attempts {
+		sync.Status = scanItem.SyncRetrying
+		sync.LastError = err.Error()
+	} else if nil != err {
+		sync.Status = scanItem.SyncFailed
+		sync.LastError = err.Error()
+	}
+
+	i.setActivitySync(log, sync)
+
+	if nil == err {
+		i.dequeue(log.repoName)
 		i.logger.Info("Successful push activity", zap.String("dbName", log.repoName), zap.String("itemKey", log.itemKey))
+	} else if i.isRemoving(log.repoName) {
+		// a source being removed gets a last attempt, the activity stays failed in the repository
+		i.dequeue(log.repoName)
+		i.logger.Error("Fail pushing activity of a removed source",
+			zap.String("dbName", log.repoName),
+			zap.String("itemKey", log.itemKey),
+			zap.String("reason", sync.LastError))
+	} else if scanItem.SyncFailed == sync.Status {
+		// the activity stays failed in the repository
+		i.dequeue(log.repoName)
+		i.logger.Error("Gave up pushing activity",
+			zap.String("dbName", log.repoName),
+			zap.String("itemKey", log.itemKey),
+			zap.Int("attempts", sync.Attempts),
+			zap.String("reason", sync.LastError))
 	} else {
-		// send back to queue to re-process
+		// sent back to the queue after the backoff, it stays in the outbox
 		sendBack := log
-		i.activityChan <- &sendBack
-		i.logger.Error("Fail pushing activity", zap.String("dbName", log.repoName), zap.String("itemKey", log.itemKey))
+		sendBack.attempts = sync.Attempts
+		sendBack.lastError = sync.LastError
+		delay := pushDelay(backoff, sendBack.attempts)
+
+		i.logger.Error("Fail pushing activity",
+			zap.String("dbName", log.repoName),
+			zap.String("itemKey", log.itemKey),
+			zap.Int("attempts", sendBack.attempts),
+			zap.Duration("retryIn", delay),
+			zap.String("reason", sendBack.lastError))
+
+		if !i.retryPush(&sendBack, delay) {
+			// the keeper stopped before the next attempt
+			sync.Status = scanItem.SyncFailed
+			sync.Updated = time.Now()
+			i.setActivitySync(log, sync)
+		}
+	}
+}
+
+// SetPushRetry sets how many times a push is attempted and the first backoff between two attempts
+func (i *Keeper) SetPushRetry(attempts int, backoff time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.pushAttempts, i.pushBackoff = attempts, backoff
+}
+
+func (i *Keeper) pushRetry() (int, time.Duration) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.pushAttempts, i.pushBackoff
+}
+
+// pushDelay doubles backoff for every attempt after the first, up to MaxPushBackoff
+func pushDelay(backoff time.Duration, attempts int) time.Duration {
+	delay := backoff
+
+	for n := 1; n < attempts && delay < MaxPushBackoff; n++ {
+		delay *= 2
+	}
+
+	if delay > MaxPushBackoff {
+		return MaxPushBackoff
+	}
+
+	return delay
+}
+
+// retryPush sends log back to the broker after delay, false when the keeper stops first
+func (i *Keeper) retryPush(log *activityLog, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-i.quit:
+		return false
+	}
+
+	select {
+	case i.activityChan <- log:
+		return true
+	case <-i.quit:
+		return false
 	}
 }
 
 func (i *Keeper) importFromSource(repoName string, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	_ = i.dbSources[repoName].Import(i.saveItems)
+	i.mu.RLock()
+	communicator, found := i.dbSources[repoName]
+	i.mu.RUnlock()
+
+	if !found {
+		return
+	}
+
+	run := newImportRun(time.Now())
+
+	err := communicator.Import(func(repoName string, idField string, data []map[string]string) int {
+		return i.saveItems(repoName, idField, data, run)
+	})
+
+	if nil == err {
+		i.mu.Lock()
+		if _, found := i.dbSources[repoName]; found {
+			i.lastImports[repoName] = time.Now()
+		}
+		i.mu.Unlock()
+	}
+
 	i.updateNextImport(repoName)
 }
 
 func (i *Keeper) pickImportSource() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	for name, t := range i.nextImports {
 		now := time.Now()
-		if now.After(t) {
+		if now.After(t) && !i.paused[name] && !i.removing[name] && !i.isRetained(name, now) {
 			return name
 		}
 	}
@@ -191,18 +683,51 @@ func (i *Keeper) pickImportSource() string {
 	return ""
 }
 
-func (i *Keeper) saveItems(repoName string, idField string, data []map[string]string) int {
-	repo := i.getRepository(repoName)
+// newImportRun names the items versions written by an import started at started
+func newImportRun(started time.Time) string {
+	return "import-" + started.Format("20060102T150405.000")
+}
+
+func (i *Keeper) saveItems(repoName string, idField string, data []map[string]string, run string) int {
+	ctx := context.Background()
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		i.logger.Error("Communicator: could not open repository", zap.String("dbName", repoName), zap.Error(err))
+		return 0
+	}
+
 	count := 0
 
+	i.mu.RLock()
+	policy := i.mergePolicy[repoName]
+	i.mu.RUnlock()
+
+	// a local edit must not land between the read of an item and the write of its merge
+	lock := i.scanLock(repoName)
+
 	for _, item := range data {
 		if key, found := item[idField]; found {
 
 			if key = strings.TrimSpace(key); key != "" {
-				_, err := repo.NewItem(key, item)
+				var saved *scanItem.ScanItem
+
+				lock.Lock()
+				existing, found, err := repo.GetItem(ctx, key)
+
+				if nil == err && found && len(existing.Local) > 0 {
+					saved = existing.Merge(item, policy)
+				} else if nil == err {
+					saved, err = scanItem.NewScanItem(key, item)
+				}
+
+				if nil == err {
+					err = setItemInRun(ctx, repo, saved, run)
+				}
+				lock.Unlock()
 
 				if nil != err {
-					i.logger.Error("Communicator: could not new item", zap.String("dbName", repoName))
+					i.logger.Error("Communicator: could not save item", zap.String("dbName", repoName), zap.Error(err))
 				} else {
 					count += 1
 				}
@@ -215,7 +740,41 @@ func (i *Keeper) saveItems(repoName string, idField string, data []map[string]st
 	return count
 }
 
+// setItemInRun records the import run with the item when the repository keeps item versions
+func setItemInRun(ctx context.Context, repo scanItem.RepositoryInterfaceV2, item *scanItem.ScanItem, run string) error {
+	if recorder, ok := repo.(itemHistory.Recorder); ok {
+		return recorder.SetItemInRun(ctx, item, run)
+	}
+
+	return repo.SetItem(ctx, item)
+}
+
+// setActivitySync records the outcome of a push, a failure only costs the sync state: the push already happened
+func (i *Keeper) setActivitySync(log activityLog, sync scanItem.ActivitySync) {
+	ctx := context.Background()
+	repo, err := i.getRepository(ctx, log.repoName)
+
+	if nil == err {
+		_, err = repo.SetActivitySync(ctx, log.itemKey, log.activity.Id, sync)
+	}
+
+	if nil != err {
+		i.logger.Error("Could not record push of activity",
+			zap.String("dbName", log.repoName),
+			zap.String("itemKey", log.itemKey),
+			zap.Error(err))
+	}
+}
+
 func (i *Keeper) updateNextImport(repoName string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	// a source removed while importing is not scheduled again
+	if _, found := i.dbSources[repoName]; !found {
+		return
+	}
+
 	if nextRun, exist := i.nextImports[repoName]; exist {
 		i.nextImports[repoName] = nextRun.Add(SyncTime)
 		i.logger.Info("Next importing schedule", zap.String("dbName", repoName), zap.Time("time", i.nextImports[repoName]))
@@ -224,10 +783,17 @@ func (i *Keeper) updateNextImport(repoName string) {
 	}
 }
 
-func (i *Keeper) getRepository(repoName string) scanItem.RepositoryInterface {
-	if repo, err := i.repoRegistry.GetRepository(repoName); nil == err {
-		return repo
-	} else {
-		panic(err)
+// getRepository returns ErrUnknownSource for a removed source, a repository which can not be opened is a StorageError
+func (i *Keeper) getRepository(ctx context.Context, repoName string) (scanItem.RepositoryInterfaceV2, error) {
+	if i.isRemoved(repoName) {
+		return nil, ErrUnknownSource
+	}
+
+	repo, err := i.repoRegistry.GetRepositoryV2(ctx, repoName)
+
+	if err != nil && !scanItem.IsStorageError(err) {
+		err = scanItem.NewStorageError(repoName, "open", err)
 	}
+
+	return repo, err
 }
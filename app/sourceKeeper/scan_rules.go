@@ -0,0 +1,201 @@
+package sourceKeeper
+
+import (
+	"context"
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"sort"
+	"time"
+)
+
+// ScanResult is the item as a scan left it, with the verdict of the scan.
+// Entitlements lists what the ticket type of the item may still do today, when the source has entitlements.
+// Occupancy lists the zones the activity enters or leaves.
+// Session is the session of the agenda the activity was resolved from, when the scan named none.
+type ScanResult struct {
+	*scanItem.ItemDetail
+	Verdict      scanItem.Verdict
+	Entitlements []scanItem.Entitlement
+	Occupancy    []scanItem.ZoneOccupancy
+	Session      *scanItem.Session
+}
+
+func newScanRules(source config.DbSource) (scanItem.ScanRules, error) {
+	rules := scanItem.ScanRules{Activities: make(map[string]scanItem.ScanRule, len(source.Rules.Activities))}
+	var err error
+
+	if rules.Source, err = newScanRule(source.Rules.Source); err != nil {
+		return scanItem.ScanRules{}, fmt.Errorf("source rules: %w", err)
+	}
+
+	for action, rule := range source.Rules.Activities {
+		if rules.Activities[action], err = newScanRule(rule); err != nil {
+			return scanItem.ScanRules{}, fmt.Errorf("rules of %s: %w", action, err)
+		}
+	}
+
+	if rules.Entitlements, err = scanItem.NewEntitlementMatrix(source.Entitlements.Field, source.Entitlements.Types); err != nil {
+		return scanItem.ScanRules{}, err
+	}
+
+	names := make([]string, 0, len(source.Zones))
+	for name := range source.Zones {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		cfg := source.Zones[name]
+		zone, err := scanItem.NewZone(name, cfg.Capacity, cfg.Entry, cfg.Exit, cfg.AntiPassback)
+
+		if err != nil {
+			return scanItem.ScanRules{}, err
+		}
+
+		rules.Zones = append(rules.Zones, zone)
+	}
+
+	return rules, nil
+}
+
+func newScanRule(rule config.ScanRule) (scanItem.ScanRule, error) {
+	windows := make([]scanItem.TimeWindow, 0, len(rule.Windows))
+
+	for _, window := range rule.Windows {
+		parsed, err := scanItem.NewTimeWindow(window.From, window.To)
+
+		if err != nil {
+			return scanItem.ScanRule{}, err
+		}
+
+		windows = append(windows, parsed)
+	}
+
+	return scanItem.NewScanRule(rule.Once, rule.MinInterval, rule.Gateways, rule.Require, windows...)
+}
+
+// Scan judges a scan by the rules of the source and records its activity when they allow it,
+// false when the item or the source does not exist. A scan without activityName of a source with an agenda
+// records the activity of the session running at its gateway, ErrNoSession when none runs.
+func (i *Keeper) Scan(ctx context.Context, repoName string, itemKey string, activityName string, properties map[string]string) (*ScanResult, bool, error) {
+	i.mu.RLock()
+	rules := i.rules[repoName]
+	agenda := i.agendas[repoName]
+	i.mu.RUnlock()
+
+	var session *scanItem.Session
+
+	if "" == activityName && agenda.Enabled() {
+		var err error
+
+		if session, err = resolveSession(agenda, properties[scanItem.GatewayField], time.Now()); err != nil {
+			return nil, false, err
+		}
+
+		activityName = session.Activity
+	}
+
+	result, found, err := i.scan(ctx, repoName, itemKey, activityName, properties, rules)
+
+	if nil != result {
+		result.Session = session
+	}
+
+	return result, found, err
+}
+
+func (i *Keeper) scan(ctx context.Context, repoName string, itemKey string, activityName string, properties map[string]string, rules scanItem.ScanRules) (*ScanResult, bool, error) {
+	verdict := scanItem.Allowed()
+	var occupancy []scanItem.ZoneOccupancy
+	var activity scanItem.ItemActivity
+	var added bool
+	var err error
+
+	if rules.Empty() {
+		activity, added, err = i.addItemActivity(ctx, repoName, itemKey, activityName, properties)
+	} else {
+		// the rules read the activities and the occupancy a scan adds to, two scans of a source must not be judged together
+		lock := i.scanLock(repoName)
+		lock.Lock()
+		var moves map[string]int
+		verdict, moves, err = i.judgeScan(ctx, repoName, itemKey, activityName, properties, rules)
+
+		if nil == err && verdict.Allowed() {
+			activity, added, err = i.addItemActivity(ctx, repoName, itemKey, activityName, properties)
+		}
+
+		if added {
+			i.moveOccupancy(repoName, moves)
+		}
+
+		occupancy = i.zoneOccupancy(repoName, rules.Zones, activityName)
+		lock.Unlock()
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	// an activity the storage refused is not pushed
+	if added {
+		i.enqueue(&activityLog{
+			repoName: repoName,
+			itemKey:  itemKey,
+			activity: activity,
+		})
+	}
+
+	item, found, err := i.GetItemDetail(ctx, repoName, itemKey)
+
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	if added {
+		i.mu.RLock()
+		listeners := i.listeners
+		i.mu.RUnlock()
+
+		for _, listener := range listeners {
+			listener.OnScan(repoName, item, activity)
+		}
+	}
+
+	result := &ScanResult{ItemDetail: item, Verdict: verdict, Occupancy: occupancy}
+
+	if rules.Entitlements.Enabled() {
+		result.Entitlements = rules.Entitlements.Remaining(&item.ScanItem, item.Activities, time.Now())
+	}
+
+	return result, true, nil
+}
+
+// judgeScan needs the scan lock of the source held, a scan of a missing item or of a source refusing scans is left to addItemActivity.
+// An allowed scan comes with how it moves the occupancy of the zones.
+func (i *Keeper) judgeScan(ctx context.Context, repoName string, itemKey string, activityName string, properties map[string]string, rules scanItem.ScanRules) (scanItem.Verdict, map[string]int, error) {
+	if !i.accepts(repoName) {
+		return scanItem.Allowed(), nil, nil
+	}
+
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return scanItem.Verdict{}, nil, err
+	}
+
+	item, found, err := repo.GetItemDetail(ctx, itemKey)
+
+	if err != nil || !found {
+		return scanItem.Allowed(), nil, err
+	}
+
+	verdict := rules.Check(&item.ScanItem, item.Activities, activityName, properties, time.Now())
+
+	if !verdict.Allowed() || 0 == len(rules.Zones) {
+		return verdict, nil, nil
+	}
+
+	return i.admit(ctx, repoName, repo, rules.Zones, item.Activities, activityName)
+}
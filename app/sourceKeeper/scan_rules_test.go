@@ -0,0 +1,112 @@
+package sourceKeeper_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+)
+
+var _ = Describe("Keeper scan rules\n", func() {
+	ctx := context.Background()
+	var folder string
+	var keeper *sourceKeeper.Keeper
+	var registry service.RepositoryRegistryInterface
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "scanRules")
+
+		logger, _ := fakeLogger()
+		registry = service.NewRepositoryRegistry(memDb.NewMemDbConnection(folder))
+		keeper = sourceKeeper.NewSourceKeeper(nil, registry, logger)
+
+		Expect(keeper.AddSource(config.DbSource{
+			Name:        "guests",
+			IdField:     "code",
+			FetchingUrl: "http://localhost/fetch",
+			Rules: config.ScanRules{
+				Source: config.ScanRule{Gateways: []string{"north", "south"}},
+				Activities: map[string]config.ScanRule{
+					"checkin": {Once: true},
+					"lounge":  {Require: map[string][]string{"ticket": {"VIP"}}},
+				},
+			},
+		})).To(Succeed())
+
+		repo, _ := registry.GetRepositoryV2(ctx, "guests")
+		_, _ = repo.NewItem(ctx, "101", map[string]string{"code": "101", "ticket": "Standard"})
+	})
+
+	AfterEach(func() {
+		registry.Shutdown()
+		_ = os.RemoveAll(folder)
+	})
+
+	north := map[string]string{"gateway": "north"}
+
+	It("records the first check-in only and tells when it was done\n", func() {
+		first, found, err := keeper.Scan(ctx, "guests", "101", "checkin", north)
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(first.Verdict.Allowed()).To(BeTrue())
+
+		again, found, err := keeper.Scan(ctx, "guests", "101", "checkin", map[string]string{"gateway": "south"})
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(again.Verdict.Status).To(Equal(scanItem.VerdictAlreadyDone))
+		Expect(again.Verdict.At).To(Equal(first.Activities[0].Created))
+		Expect(again.Activities).To(HaveLen(1))
+
+		item, _, _ := keeper.ScanItem(ctx, "guests", "101", "checkin", north)
+		Expect(item.Activities).To(HaveLen(1))
+	})
+
+	It("denies scans at other gateways and without the required field value\n", func() {
+		result, _, _ := keeper.Scan(ctx, "guests", "101", "checkin", map[string]string{"gateway": "east"})
+		Expect(result.Verdict.Status).To(Equal(scanItem.VerdictDenied))
+		Expect(result.Verdict.Rule).To(Equal(scanItem.RuleGateway))
+
+		result, _, _ = keeper.Scan(ctx, "guests", "101", "lounge", north)
+		Expect(result.Verdict.Status).To(Equal(scanItem.VerdictDenied))
+		Expect(result.Verdict.Rule).To(Equal(scanItem.RuleField))
+		Expect(result.Activities).To(BeEmpty())
+
+		By("edits are not scans")
+		before, _, _ := keeper.GetItemDetail(ctx, "guests", "101")
+		ticket := before.Data["ticket"]
+
+		item, found, err := keeper.SetLocalFields(ctx, "guests", "101", map[string]string{"ticket": "VIP"})
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(item.Activities).To(HaveLen(1))
+		Expect(item.Data["ticket"]).To(Equal("VIP"))
+
+		By("the edit is made on a copy, an item read before is left as it was")
+		Expect(before.Data["ticket"]).To(Equal(ticket))
+		Expect(before.Local).To(BeEmpty())
+
+		result, _, _ = keeper.Scan(ctx, "guests", "101", "lounge", north)
+		Expect(result.Verdict.Allowed()).To(BeTrue())
+	})
+
+	It("a missing item is not found whatever the rules\n", func() {
+		_, found, err := keeper.Scan(ctx, "guests", "999", "checkin", north)
+		Expect(err).To(BeNil())
+		Expect(found).To(BeFalse())
+	})
+
+	It("a source with an invalid rule is refused\n", func() {
+		err := keeper.AddSource(config.DbSource{
+			Name:    "staff",
+			IdField: "code",
+			Rules:   config.ScanRules{Activities: map[string]config.ScanRule{"checkin": {MinInterval: "often"}}},
+		})
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,320 @@
+package sourceKeeper
+
+import (
+	"context"
+	"errors"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DrainTimeout bounds how long RemoveSource waits for the outbox and a running import of the source
+const DrainTimeout = 30 * time.Second
+
+var (
+	ErrUnknownSource = errors.New("unknown source")
+	ErrSourceExists  = errors.New("source already exists")
+)
+
+// ConnectionFactory opens the storage of a source added at runtime
+type ConnectionFactory func(source config.DbSource) (service.DbConnectionInterface, error)
+
+// SetConnectionFactory sets how the storage of sources added at runtime is opened,
+// they use the registry's default storage when it is not set
+func (i *Keeper) SetConnectionFactory(factory ConnectionFactory) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.openConnection = factory
+}
+
+// AddSource starts keeping a source without a restart, its first import is scheduled right away.
+// The source is not written to the configuration file.
+func (i *Keeper) AddSource(source config.DbSource) error {
+	source.Name = strings.TrimSpace(source.Name)
+
+	if "" == source.Name || "" == strings.TrimSpace(source.IdField) {
+		return errors.New("source must have a name and an id field")
+	}
+
+	i.mu.RLock()
+	_, exist := i.dbSources[source.Name]
+	factory := i.openConnection
+	i.mu.RUnlock()
+
+	if exist || i.isRemoving(source.Name) {
+		return ErrSourceExists
+	}
+
+	if err := validateSource(source); err != nil {
+		return err
+	}
+
+	if nil != factory {
+		connection, err := factory(source)
+
+		if err != nil {
+			return err
+		}
+
+		i.repoRegistry.SetConnection(source.Name, connection)
+	}
+
+	i.mu.Lock()
+	delete(i.removed, source.Name)
+	i.mu.Unlock()
+
+	if _, err := i.repoRegistry.GetRepositoryV2(context.Background(), source.Name); err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	i.conf = append(i.conf, source)
+	i.mu.Unlock()
+
+	i.registerSource(source)
+	i.logger.Info("Source added", zap.String("dbName", source.Name))
+
+	return nil
+}
+
+// validateSource checks the policies and rules of a source, registerSource relies on them being valid
+func validateSource(source config.DbSource) error {
+	if err := scanItem.CheckRepoName(source.Name); err != nil {
+		return err
+	}
+
+	if err := newMergePolicy(source).Validate(); err != nil {
+		return err
+	}
+
+	if _, err := newRetentionPolicy(source); err != nil {
+		return err
+	}
+
+	if _, err := newScanRules(source); err != nil {
+		return err
+	}
+
+	_, err := newAgenda(source)
+
+	return err
+}
+
+func newMergePolicy(source config.DbSource) scanItem.MergePolicy {
+	policy := source.MergePolicy
+
+	return scanItem.NewMergePolicy(policy.Default, policy.Fields, policy.TimestampField)
+}
+
+// registerSource creates the runtime state of a configured source
+func (i *Keeper) registerSource(source config.DbSource) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.dbSources[source.Name] = NewCommunicator(source, i.logger)
+	i.mergePolicy[source.Name] = newMergePolicy(source)
+	i.retention[source.Name], _ = newRetentionPolicy(source)
+	i.rules[source.Name], _ = newScanRules(source)
+	i.agendas[source.Name], _ = newAgenda(source)
+	i.retained[source.Name] = i.retentionApplied(source.Name)
+	i.nextImports[source.Name] = time.Now()
+}
+
+// RemoveSource stops keeping a source. New scans of the source are refused, then its outbox is drained
+// (every queued activity gets a last push attempt) and a running import is awaited, at most for timeout.
+// Its repository is closed, the data stays on disk. It returns the number of activities left unpushed.
+func (i *Keeper) RemoveSource(name string, timeout time.Duration) (int, error) {
+	i.mu.Lock()
+	communicator, found := i.dbSources[name]
+
+	if !found || i.removing[name] {
+		i.mu.Unlock()
+		return 0, ErrUnknownSource
+	}
+
+	i.removing[name] = true
+	delete(i.nextImports, name)
+	held := i.resume(name)
+	i.mu.Unlock()
+
+	i.requeue(held)
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && (i.outboxLen(name) > 0 || communicator.IsImporting()) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	i.mu.Lock()
+	left := i.outbox[name]
+
+	delete(i.dbSources, name)
+	delete(i.mergePolicy, name)
+	delete(i.retention, name)
+	delete(i.rules, name)
+	delete(i.agendas, name)
+	delete(i.retained, name)
+	delete(i.lastImports, name)
+	delete(i.outbox, name)
+	delete(i.removing, name)
+	i.removed[name] = true
+
+	for idx, source := range i.conf {
+		if source.Name == name {
+			i.conf = append(i.conf[:idx:idx], i.conf[idx+1:]...)
+			break
+		}
+	}
+	i.mu.Unlock()
+
+	i.scanMu.Lock()
+	delete(i.occupancy, name)
+	delete(i.scanLocks, name)
+	i.scanMu.Unlock()
+
+	i.repoRegistry.RemoveRepository(name)
+	i.logger.Info("Source removed", zap.String("dbName", name), zap.Int("unpushed", left))
+
+	return left, nil
+}
+
+// PauseSource stops imports and pushes of a source, scans are still recorded and queued in its outbox
+func (i *Keeper) PauseSource(name string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, found := i.dbSources[name]; !found || i.removing[name] {
+		return ErrUnknownSource
+	}
+
+	i.paused[name] = true
+	i.logger.Info("Source paused", zap.String("dbName", name))
+
+	return nil
+}
+
+// ResumeSource restarts imports and pushes of a paused source, the activities held meanwhile are pushed
+func (i *Keeper) ResumeSource(name string) error {
+	i.mu.Lock()
+
+	if _, found := i.dbSources[name]; !found || i.removing[name] {
+		i.mu.Unlock()
+		return ErrUnknownSource
+	}
+
+	held := i.resume(name)
+	i.mu.Unlock()
+
+	i.requeue(held)
+	i.logger.Info("Source resumed", zap.String("dbName", name), zap.Int("held", len(held)))
+
+	return nil
+}
+
+// resume clears the pause of a source and returns its held activities, i.mu must be locked
+func (i *Keeper) resume(name string) []activityLog {
+	held := i.held[name]
+
+	delete(i.paused, name)
+	delete(i.held, name)
+
+	return held
+}
+
+// requeue sends held activities back to the broker, without blocking the caller on a full queue
+func (i *Keeper) requeue(held []activityLog) {
+	if 0 == len(held) {
+		return
+	}
+
+	go func() {
+		for idx := range held {
+			select {
+			case i.activityChan <- &held[idx]:
+			case <-i.quit:
+				return
+			}
+		}
+	}()
+}
+
+// ListSources returns the status of every source, sorted by name
+func (i *Keeper) ListSources() []SourceStatus {
+	var statuses []SourceStatus
+
+	for _, name := range i.GetRepositoryNames() {
+		if status, found := i.GetSourceStatus(name); found {
+			statuses = append(statuses, *status)
+		}
+	}
+
+	sort.Slice(statuses, func(a, b int) bool {
+		return statuses[a].Name < statuses[b].Name
+	})
+
+	return statuses
+}
+
+func (i *Keeper) IsPaused(name string) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.paused[name]
+}
+
+func (i *Keeper) isRemoving(name string) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.removing[name]
+}
+
+func (i *Keeper) isRemoved(name string) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.removed[name]
+}
+
+// accepts tells whether new activities of the repository are recorded
+func (i *Keeper) accepts(name string) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return !i.removing[name] && !i.removed[name]
+}
+
+// enqueue puts an activity in the outbox of its source, a full queue does not block the scan:
+// the activity is sent to the broker in the background and stays counted in the outbox meanwhile
+func (i *Keeper) enqueue(log *activityLog) {
+	i.mu.Lock()
+	i.outbox[log.repoName] += 1
+	i.mu.Unlock()
+
+	select {
+	case i.activityChan <- log:
+	default:
+		i.requeue([]activityLog{*log})
+	}
+}
+
+// dequeue takes an activity out of the outbox once it was pushed or given up
+func (i *Keeper) dequeue(name string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if count, found := i.outbox[name]; found && count > 0 {
+		i.outbox[name] = count - 1
+	}
+}
+
+func (i *Keeper) outboxLen(name string) int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.outbox[name]
+}
@@ -0,0 +1,205 @@
+package sourceKeeper_test
+
+import (
+	"context"
+	"errors"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/h2non/gock.v1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ = Describe("Keeper sources at runtime\n", func() {
+	ctx := context.Background()
+	var folder string
+	var upstream *httptest.Server
+	var pushes int32
+	var failing int32
+	var keeper *sourceKeeper.Keeper
+	var registry service.RepositoryRegistryInterface
+	var wg sync.WaitGroup
+
+	BeforeEach(func() {
+		gock.Off()
+
+		folder, _ = ioutil.TempDir("", "sources")
+		atomic.StoreInt32(&pushes, 0)
+		atomic.StoreInt32(&failing, 0)
+
+		upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&pushes, 1)
+
+			if 1 == atomic.LoadInt32(&failing) {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"data": {}}`))
+		}))
+
+		logger, _ := fakeLogger()
+		registry = service.NewRepositoryRegistry(memDb.NewMemDbConnection(folder))
+		keeper = sourceKeeper.NewSourceKeeper(nil, registry, logger)
+
+		wg.Add(1)
+		keeper.Start(&wg)
+	})
+
+	AfterEach(func() {
+		keeper.Stop()
+		wg.Wait()
+		upstream.Close()
+		_ = os.RemoveAll(folder)
+	})
+
+	addGuests := func() {
+		Expect(keeper.AddSource(config.DbSource{
+			Name:        "guests",
+			IdField:     "code",
+			FetchingUrl: upstream.URL + "/fetch?offset=%offset%&limit=%size%",
+			UpdateUrl:   upstream.URL + "/update/%key%",
+		})).To(Succeed())
+
+		repo, _ := registry.GetRepository("guests")
+		_, _ = repo.NewItem("101", map[string]string{"code": "101"})
+	}
+
+	It("an added source is listed and scanned, adding it twice fails\n", func() {
+		addGuests()
+
+		Expect(keeper.ListSources()).To(HaveLen(1))
+		Expect(keeper.AddSource(config.DbSource{Name: "guests", IdField: "code"})).To(Equal(sourceKeeper.ErrSourceExists))
+
+		_, found, _ := keeper.ScanItem(ctx, "guests", "101", "checkin", nil)
+		Expect(found).To(BeTrue())
+		Eventually(func() int32 { return atomic.LoadInt32(&pushes) }).Should(Equal(int32(1)))
+	})
+
+	It("a source merging by last writer without the upstream row time is refused\n", func() {
+		err := keeper.AddSource(config.DbSource{
+			Name:        "lww",
+			IdField:     "code",
+			MergePolicy: config.MergePolicy{Fields: map[string]string{"seat": "lww"}},
+		})
+
+		Expect(err).NotTo(BeNil())
+		Expect(keeper.ListSources()).To(BeEmpty())
+	})
+
+	It("a source with a misspelled merge policy is refused\n", func() {
+		err := keeper.AddSource(config.DbSource{
+			Name:        "typo",
+			IdField:     "code",
+			MergePolicy: config.MergePolicy{Default: "upsteam"},
+		})
+
+		Expect(err).NotTo(BeNil())
+		Expect(keeper.ListSources()).To(BeEmpty())
+	})
+
+	It("a source named like the records storages keep for themselves is refused\n", func() {
+		err := keeper.AddSource(config.DbSource{Name: scanItem.ReservedPrefix + "format", IdField: "code"})
+
+		Expect(errors.Is(err, scanItem.ErrReservedName)).To(BeTrue())
+		Expect(keeper.ListSources()).To(BeEmpty())
+	})
+
+	It("a failed push is retried with backoff, then given up and left failed\n", func() {
+		addGuests()
+		atomic.StoreInt32(&failing, 1)
+		keeper.SetPushRetry(3, 20*time.Millisecond)
+
+		_, found, _ := keeper.ScanItem(ctx, "guests", "101", "checkin", nil)
+		Expect(found).To(BeTrue())
+
+		Eventually(func() int {
+			status, _ := keeper.GetSourceStatus("guests")
+			return status.Outbox
+		}).Should(Equal(0))
+		Consistently(func() int32 { return atomic.LoadInt32(&pushes) }, 200*time.Millisecond).Should(Equal(int32(3)))
+
+		item, _, _ := keeper.GetItemDetail(ctx, "guests", "101")
+		Expect(item.Activities[0].Sync.Status).To(Equal(scanItem.SyncFailed))
+		Expect(item.Activities[0].Sync.Attempts).To(Equal(3))
+	})
+
+	It("a failed push with attempts left is retrying, not failed\n", func() {
+		addGuests()
+		atomic.StoreInt32(&failing, 1)
+		keeper.SetPushRetry(3, time.Hour)
+
+		_, found, _ := keeper.ScanItem(ctx, "guests", "101", "checkin", nil)
+		Expect(found).To(BeTrue())
+
+		Eventually(func() string {
+			item, _, _ := keeper.GetItemDetail(ctx, "guests", "101")
+			return item.Activities[0].Sync.Status
+		}).Should(Equal(scanItem.SyncRetrying))
+
+		summary, err := keeper.GetSyncSummary(ctx, "guests")
+		Expect(err).To(BeNil())
+		Expect(summary.Retrying).To(Equal(1))
+		Expect(summary.Failed).To(Equal(0))
+		Expect(summary.Unsynced).To(Equal(1))
+
+		status, _ := keeper.GetSourceStatus("guests")
+		Expect(status.Outbox).To(Equal(1))
+	})
+
+	It("a paused source holds its pushes until it is resumed\n", func() {
+		addGuests()
+		Expect(keeper.PauseSource("guests")).To(Succeed())
+
+		_, found, _ := keeper.ScanItem(ctx, "guests", "101", "checkin", nil)
+		Expect(found).To(BeTrue())
+
+		Consistently(func() int32 { return atomic.LoadInt32(&pushes) }, 200*time.Millisecond).Should(Equal(int32(0)))
+		status, _ := keeper.GetSourceStatus("guests")
+		Expect(status.Paused).To(BeTrue())
+		Expect(status.Outbox).To(Equal(1))
+		Expect(keeper.StartImport("guests")).To(BeFalse())
+
+		Expect(keeper.ResumeSource("guests")).To(Succeed())
+		Eventually(func() int32 { return atomic.LoadInt32(&pushes) }).Should(Equal(int32(1)))
+		Eventually(func() int {
+			status, _ := keeper.GetSourceStatus("guests")
+			return status.Outbox
+		}).Should(Equal(0))
+	})
+
+	It("a removed source drains its outbox, then refuses scans\n", func() {
+		addGuests()
+		Expect(keeper.PauseSource("guests")).To(Succeed())
+		_, _, _ = keeper.ScanItem(ctx, "guests", "101", "checkin", nil)
+
+		unpushed, err := keeper.RemoveSource("guests", 5*time.Second)
+		Expect(err).To(BeNil())
+		Expect(unpushed).To(Equal(0))
+		Expect(atomic.LoadInt32(&pushes)).To(Equal(int32(1)))
+
+		_, found, _ := keeper.ScanItem(ctx, "guests", "101", "checkin", nil)
+		Expect(found).To(BeFalse())
+		Expect(keeper.ListSources()).To(BeEmpty())
+
+		_, err = keeper.RemoveSource("guests", time.Second)
+		Expect(err).To(Equal(sourceKeeper.ErrUnknownSource))
+
+		By("it can be added again with the data it had")
+		addGuests()
+		item, found, err := keeper.GetItemDetail(ctx, "guests", "101")
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(item.Activities).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,65 @@
+package sourceKeeper
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"time"
+)
+
+// SourceStats are the stats of a repository with the import and push state of its source.
+// Unsynced counts the activities upstream has not acknowledged, Outbox those queued to be pushed now.
+type SourceStats struct {
+	scanItem.Stats
+	LastImport time.Time
+	Unsynced   int
+	Outbox     int
+}
+
+// GetStats summarises the repository of a source, items and activities are grouped by the groupBy field, DefaultStatsGroup when empty
+func (i *Keeper) GetStats(ctx context.Context, repoName string, groupBy string) (SourceStats, error) {
+	if "" == groupBy {
+		groupBy = scanItem.DefaultStatsGroup
+	}
+
+	i.mu.RLock()
+	_, found := i.dbSources[repoName]
+	i.mu.RUnlock()
+
+	// a repository is opened on first use, an unknown name would create one
+	if !found {
+		return SourceStats{}, ErrUnknownSource
+	}
+
+	repo, err := i.getRepository(ctx, repoName)
+
+	if err != nil {
+		return SourceStats{}, err
+	}
+
+	stats, err := collectStats(ctx, repo, groupBy)
+
+	if err != nil {
+		return SourceStats{}, err
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return SourceStats{
+		Stats:      stats,
+		LastImport: i.lastImports[repoName],
+		Unsynced:   stats.Sync.Unsynced,
+		Outbox:     i.outbox[repoName],
+	}, nil
+}
+
+// collectStats asks the storage adapter under the decorators, the decorators add nothing to the stats
+func collectStats(ctx context.Context, repo scanItem.RepositoryInterfaceV2, groupBy string) (scanItem.Stats, error) {
+	for _, layer := range scanItem.Layers(repo) {
+		if statsRepo, ok := layer.(scanItem.StatsRepository); ok {
+			return statsRepo.Stats(ctx, groupBy)
+		}
+	}
+
+	return scanItem.CollectStats(ctx, repo, groupBy)
+}
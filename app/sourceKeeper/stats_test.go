@@ -0,0 +1,97 @@
+package sourceKeeper_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/h2non/gock.v1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+)
+
+var _ = Describe("Keeper stats of a source\n", func() {
+	ctx := context.Background()
+	var folder string
+	var upstream *httptest.Server
+	var keeper *sourceKeeper.Keeper
+	var registry service.RepositoryRegistryInterface
+	var wg sync.WaitGroup
+
+	BeforeEach(func() {
+		gock.Off()
+
+		folder, _ = ioutil.TempDir("", "stats")
+
+		upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if "/fetch" == r.URL.Path {
+				_, _ = w.Write([]byte(`{"data": [{"code": "101", "gateway": "north"}, {"code": "102", "gateway": "south"}, {"code": "103"}]}`))
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+		logger, _ := fakeLogger()
+		registry = service.NewRepositoryRegistry(memDb.NewMemDbConnection(folder))
+		keeper = sourceKeeper.NewSourceKeeper(nil, registry, logger)
+
+		wg.Add(1)
+		keeper.Start(&wg)
+
+		Expect(keeper.AddSource(config.DbSource{
+			Name:        "guests",
+			IdField:     "code",
+			FetchingUrl: upstream.URL + "/fetch?offset=%offset%&limit=%size%",
+			UpdateUrl:   upstream.URL + "/update/%key%",
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		keeper.Stop()
+		wg.Wait()
+		upstream.Close()
+		_ = os.RemoveAll(folder)
+	})
+
+	It("counts items, scanned items by action and by gateway, and reports the last import\n", func() {
+		stats, err := keeper.GetStats(ctx, "guests", "")
+		Expect(err).To(BeNil())
+		Expect(stats.LastImport.IsZero()).To(BeTrue())
+
+		before := time.Now()
+		Expect(keeper.StartImport("guests")).To(BeTrue())
+		Eventually(func() time.Time {
+			status, _ := keeper.GetSourceStatus("guests")
+			return status.LastImport
+		}, 5*time.Second).Should(BeTemporally(">=", before))
+
+		Expect(keeper.PauseSource("guests")).To(Succeed())
+		_, _, _ = keeper.ScanItem(ctx, "guests", "101", "checkin", nil)
+		_, _, _ = keeper.ScanItem(ctx, "guests", "101", "checkin", nil)
+		_, _, _ = keeper.ScanItem(ctx, "guests", "102", "checkin", map[string]string{"gateway": "north"})
+		_, _, _ = keeper.ScanItem(ctx, "guests", "103", "lunch", nil)
+
+		stats, err = keeper.GetStats(ctx, "guests", "")
+		Expect(err).To(BeNil())
+		Expect(stats.Items).To(Equal(3))
+		Expect(stats.Actions).To(Equal(map[string]int{"checkin": 2, "lunch": 1}))
+		Expect(stats.GroupBy).To(Equal(scanItem.DefaultStatsGroup))
+		Expect(stats.Groups).To(Equal(map[string]map[string]int{"north": {"checkin": 2}}))
+		Expect(stats.Unsynced).To(Equal(4))
+		Expect(stats.Outbox).To(Equal(4))
+		Expect(stats.LastImport.Before(before)).To(BeFalse())
+
+		By("an unknown source is an error")
+		_, err = keeper.GetStats(ctx, "unknown", "")
+		Expect(err).To(Equal(sourceKeeper.ErrUnknownSource))
+	})
+})
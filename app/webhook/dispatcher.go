@@ -0,0 +1,279 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"go.uber.org/zap"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-EventHub-Signature"
+	EventHeader     = "X-EventHub-Event"
+	DeliveryHeader  = "X-EventHub-Delivery"
+
+	EventScan = "scan"
+
+	MaxAttempts int = 6
+)
+
+// Event is the payload delivered to every matching webhook target
+type Event struct {
+	Id       string                `json:"id"`
+	Type     string                `json:"type"`
+	Source   string                `json:"source"`
+	Item     *scanItem.ItemDetail  `json:"item"`
+	Activity scanItem.ItemActivity `json:"activity"`
+	Created  time.Time             `json:"created"`
+}
+
+type delivery struct {
+	target   config.Webhook
+	event    string
+	id       string
+	body     []byte
+	attempts int
+}
+
+// Dispatcher keeps the webhook subscriptions and delivers events asynchronously.
+// Targets added through the admin API live in memory only, config is the durable registry.
+type Dispatcher struct {
+	mu        sync.RWMutex
+	targets   map[string]config.Webhook
+	client    *http.Client
+	queue     chan *delivery
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	logger    *zap.Logger
+	retryWait time.Duration
+	dropped   uint64
+}
+
+func NewDispatcher(cfg []config.Webhook, logger *zap.Logger) *Dispatcher {
+	instance := &Dispatcher{
+		targets:   make(map[string]config.Webhook),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		queue:     make(chan *delivery, 100),
+		stop:      make(chan struct{}),
+		logger:    logger,
+		retryWait: 2 * time.Second,
+	}
+
+	for _, target := range cfg {
+		if err := instance.AddTarget(target); err != nil {
+			logger.Error("Webhook: invalid target "+err.Error(), zap.String("webhook", target.Name))
+		}
+	}
+
+	return instance
+}
+
+// SetRetryWait changes the base delay of the exponential retry backoff
+func (d *Dispatcher) SetRetryWait(wait time.Duration) *Dispatcher {
+	d.retryWait = wait
+	return d
+}
+
+func (d *Dispatcher) Start(workers int) {
+	for n := 0; n < workers; n++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+}
+
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) Targets() []config.Webhook {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	result := make([]config.Webhook, 0, len(d.targets))
+	for _, target := range d.targets {
+		result = append(result, target)
+	}
+
+	sort.Slice(result, func(a, b int) bool { return result[a].Name < result[b].Name })
+
+	return result
+}
+
+func (d *Dispatcher) AddTarget(target config.Webhook) error {
+	if "" == target.Name || "" == target.Url {
+		return errors.New("webhook name and url are required")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exist := d.targets[target.Name]; exist {
+		return fmt.Errorf("webhook %s already exists", target.Name)
+	}
+
+	d.targets[target.Name] = target
+
+	return nil
+}
+
+func (d *Dispatcher) RemoveTarget(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exist := d.targets[name]; exist {
+		delete(d.targets, name)
+		return true
+	}
+
+	return false
+}
+
+// OnScan queues a scan event for every target whose filters match
+func (d *Dispatcher) OnScan(repoName string, item *scanItem.ItemDetail, activity scanItem.ItemActivity) {
+	event := Event{
+		Id:       repoName + "-" + activity.Id,
+		Type:     EventScan,
+		Source:   repoName,
+		Item:     item,
+		Activity: activity,
+		Created:  time.Now(),
+	}
+
+	body, err := json.Marshal(event)
+
+	if err != nil {
+		d.logger.Error("Webhook: could not encode event "+err.Error(), zap.String("dbName", repoName))
+		return
+	}
+
+	for _, target := range d.Targets() {
+		if Match(target, repoName, activity) {
+			d.enqueue(&delivery{target: target, event: event.Type, id: event.Id, body: body})
+		}
+	}
+}
+
+// Match tells whether the target subscribes to an activity of the source
+func Match(target config.Webhook, repoName string, activity scanItem.ItemActivity) bool {
+	return matchFilter(target.Sources, repoName) &&
+		matchFilter(target.Activities, activity.Action) &&
+		matchFilter(target.Gateways, activity.Data["gateway"])
+}
+
+func matchFilter(filter []string, value string) bool {
+	if 0 == len(filter) {
+		return true
+	}
+
+	for _, allowed := range filter {
+		if allowed == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign returns the signature header value of body, the hex encoded HMAC-SHA256 with the target secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dropped counts the deliveries given up because the queue was full
+func (d *Dispatcher) Dropped() uint64 {
+	return atomic.LoadUint64(&d.dropped)
+}
+
+// enqueue never blocks, OnScan runs within the scan request: a delivery finding the queue full is dropped
+func (d *Dispatcher) enqueue(job *delivery) {
+	select {
+	case <-d.stop:
+	case d.queue <- job:
+	default:
+		atomic.AddUint64(&d.dropped, 1)
+		d.logger.Error("Webhook delivery dropped, the queue is full",
+			zap.String("webhook", job.target.Name),
+			zap.String("delivery", job.id),
+			zap.Int("attempts", job.attempts))
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+
+		case job := <-d.queue:
+			d.deliver(job)
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(job *delivery) {
+	job.attempts += 1
+	err := d.post(job)
+
+	if nil == err {
+		d.logger.Info("Webhook delivered", zap.String("webhook", job.target.Name), zap.String("delivery", job.id))
+		return
+	}
+
+	d.logger.Error("Webhook delivery failed",
+		zap.String("webhook", job.target.Name),
+		zap.String("delivery", job.id),
+		zap.Int("attempts", job.attempts),
+		zap.String("reason", err.Error()))
+
+	if job.attempts >= MaxAttempts {
+		return
+	}
+
+	// retry later with exponential backoff, without holding a worker
+	wait := d.retryWait * time.Duration(1<<uint(job.attempts-1))
+	time.AfterFunc(wait, func() { d.enqueue(job) })
+}
+
+func (d *Dispatcher) post(job *delivery) error {
+	request, err := http.NewRequest(http.MethodPost, job.target.Url, bytes.NewReader(job.body))
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, job.event)
+	request.Header.Set(DeliveryHeader, job.id)
+	request.Header.Set(SignatureHeader, Sign(job.target.Secret, job.body))
+
+	response, err := d.client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	_ = response.Body.Close()
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected http status %d", response.StatusCode)
+	}
+
+	return nil
+}
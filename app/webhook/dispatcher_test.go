@@ -0,0 +1,107 @@
+package webhook_test
+
+import (
+	"git.anphabe.net/event/anphabe-event-hub/app/webhook"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+)
+
+var _ = Describe("Dispatcher\n", func() {
+	item := &scanItem.ItemDetail{ScanItem: *scanItem.CreateTestScanItem("1a")}
+
+	Context("Match() filters by source, activity and gateway\n", func() {
+		target := config.Webhook{Name: "draw", Url: "http://x", Sources: []string{"repo1"}, Activities: []string{"checkin"}, Gateways: []string{"gate2"}}
+
+		It("matches when every filter matches\n", func() {
+			activity := scanItem.NewActivity("checkin", map[string]string{"gateway": "gate2"})
+			Expect(webhook.Match(target, "repo1", activity)).To(BeTrue())
+		})
+
+		It("does not match another gateway\n", func() {
+			activity := scanItem.NewActivity("checkin", map[string]string{"gateway": "gate1"})
+			Expect(webhook.Match(target, "repo1", activity)).To(BeFalse())
+		})
+
+		It("empty filters match everything\n", func() {
+			activity := scanItem.NewActivity("lunch", nil)
+			Expect(webhook.Match(config.Webhook{Name: "all", Url: "http://x"}, "any", activity)).To(BeTrue())
+		})
+	})
+
+	Context("Given a target which fails once then succeeds\n", func() {
+		var server *httptest.Server
+		var calls int32
+		var signatures chan string
+
+		BeforeEach(func() {
+			calls = 0
+			signatures = make(chan string, 2)
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+
+				if atomic.AddInt32(&calls, 1) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				signatures <- r.Header.Get(webhook.SignatureHeader) + "|" + webhook.Sign("s3cret", body)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("retries and delivers a correctly signed event\n", func() {
+			sut := webhook.NewDispatcher([]config.Webhook{{Name: "crm", Url: server.URL, Secret: "s3cret"}}, zap.NewNop())
+			sut.SetRetryWait(10 * time.Millisecond).Start(1)
+			defer sut.Stop()
+
+			sut.OnScan("repo1", item, scanItem.NewActivity("checkin", nil))
+
+			var got string
+			Eventually(signatures, time.Second).Should(Receive(&got))
+			Expect(got).To(MatchRegexp(`^(sha256=[0-9a-f]{64})\|(sha256=[0-9a-f]{64})$`))
+			Expect(got[:71]).To(Equal(got[72:]))
+			Expect(atomic.LoadInt32(&calls)).To(Equal(int32(2)))
+		})
+	})
+
+	Context("Given no worker takes the deliveries\n", func() {
+		It("drops the deliveries of a full queue instead of blocking the scan\n", func() {
+			sut := webhook.NewDispatcher([]config.Webhook{{Name: "crm", Url: "http://localhost"}}, zap.NewNop())
+
+			done := make(chan struct{})
+			go func() {
+				for n := 0; n < 150; n++ {
+					sut.OnScan("repo1", item, scanItem.NewActivity("checkin", nil))
+				}
+				close(done)
+			}()
+
+			Eventually(done, time.Second).Should(BeClosed())
+			Expect(sut.Dropped()).To(Equal(uint64(50)))
+		})
+	})
+
+	Context("AddTarget() / RemoveTarget()\n", func() {
+		sut := webhook.NewDispatcher(nil, zap.NewNop())
+
+		It("rejects duplicated names and removes targets\n", func() {
+			Expect(sut.AddTarget(config.Webhook{Name: "a", Url: "http://a"})).To(Succeed())
+			Expect(sut.AddTarget(config.Webhook{Name: "a", Url: "http://b"})).NotTo(Succeed())
+			Expect(sut.Targets()).To(HaveLen(1))
+			Expect(sut.RemoveTarget("a")).To(BeTrue())
+			Expect(sut.Targets()).To(BeEmpty())
+		})
+	})
+})
@@ -1,26 +1,145 @@
 package config
 
+import "time"
+
 type ConfigurationInfo struct {
 	Server    string     `yaml:"server"`
 	Monitor   string     `yaml:"monitor"`
 	Storage   Storage    `yaml:"storage"`
 	DbSources []DbSource `yaml:"dbsources"`
+	Webhooks  []Webhook  `yaml:"webhooks"`
 	Logging   Logger     `yaml:"logging"`
 }
 
+// Storage selects a storage adapter, Options holds the adapter specific settings
 type Storage struct {
-	Adapter string `yaml:"adapter"`
-	Folder string `yaml:"folder"`
+	Adapter          string                 `yaml:"adapter"`
+	Folder           string                 `yaml:"folder"`
+	SnapshotInterval time.Duration          `yaml:"snapshotinterval"`
+	Options          map[string]interface{} `yaml:"options"`
 }
 
 // DbSourceConfig ...
 type DbSource struct {
-	Name           string `yaml:"name"`
-	IdField        string `yaml:"idfield"`
-	FetchingUrl    string `yaml:"fetchingurl"`
-	FetchingFormat string `yaml:"fetchingformat"`
-	UpdateUrl      string `yaml:"updateurl"`
-	UpdateMethod   string `yaml:"updatemethod"`
+	Name           string          `yaml:"name"`
+	IdField        string          `yaml:"idfield"`
+	FetchingUrl    string          `yaml:"fetchingurl"`
+	FetchingFormat string          `yaml:"fetchingformat"`
+	UpdateUrl      string          `yaml:"updateurl"`
+	UpdateMethod   string          `yaml:"updatemethod"`
+	UpdateSuccess  UpdateSuccess   `yaml:"updatesuccess"`
+	RateLimit      RateLimit       `yaml:"ratelimit"`
+	MergePolicy    MergePolicy     `yaml:"mergepolicy"`
+	Storage        *Storage        `yaml:"storage"`
+	SearchFields   []string        `yaml:"searchfields"`
+	HistoryDepth   int             `yaml:"historydepth"`
+	Retention      Retention       `yaml:"retention"`
+	Rules          ScanRules       `yaml:"rules"`
+	Entitlements   Entitlements    `yaml:"entitlements"`
+	Zones          map[string]Zone `yaml:"zones"`
+	Agenda         []Session       `yaml:"agenda"`
+}
+
+// Zone is a room or an area, Entry and Exit list the activityName values scanned when entering and leaving it.
+// Capacity bounds how many attendees are inside, 0 for no bound. A zone without Exit caps its Entry activities.
+// AntiPassback refuses a second entry without exit.
+type Zone struct {
+	Capacity     int      `yaml:"capacity"`
+	Entry        []string `yaml:"entry"`
+	Exit         []string `yaml:"exit"`
+	AntiPassback bool     `yaml:"antipassback"`
+}
+
+// Entitlements tell which activities each ticket type may do. Field is the item field holding the ticket type,
+// Types maps a ticket type to its activityName values and their daily quota, 0 for unlimited.
+// An activity no ticket type lists is open to every item.
+type Entitlements struct {
+	Field string                    `yaml:"field"`
+	Types map[string]map[string]int `yaml:"types"`
+}
+
+// ScanRules decide whether a scan is recorded, Source applies to every activity and
+// Activities add the rules of one activityName: a scan must pass both.
+type ScanRules struct {
+	Source     ScanRule            `yaml:"source"`
+	Activities map[string]ScanRule `yaml:"activities"`
+}
+
+// ScanRule is a set of conditions, unset ones check nothing.
+// Once allows a single scan, MinInterval (e.g. 5m) the time between two scans, Gateways where the badge
+// may be scanned and Windows when. Require maps an item field to its accepted values, empty for any value.
+type ScanRule struct {
+	Once        bool                `yaml:"once"`
+	MinInterval string              `yaml:"mininterval"`
+	Gateways    []string            `yaml:"gateways"`
+	Windows     []TimeWindow        `yaml:"windows"`
+	Require     map[string][]string `yaml:"require"`
+}
+
+// Session is a slot of the agenda of a source: scans without activityName during From - To at one of Gateways,
+// or at the gateway named Room, record Activity. From and To are written as a TimeWindow.
+// A session without room nor gateways is held everywhere, when no session of the gateway runs.
+type Session struct {
+	Name     string   `yaml:"name"`
+	Activity string   `yaml:"activity"`
+	Room     string   `yaml:"room"`
+	Gateways []string `yaml:"gateways"`
+	From     string   `yaml:"from"`
+	To       string   `yaml:"to"`
+}
+
+// TimeWindow is two clock times (08:00) repeated every day, or two dates (2006-01-02 15:04:05)
+type TimeWindow struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// Retention removes the personal data of a source once the event is over.
+// Mode purge empties the data of every item, anonymise rewrites Fields only: field name to hash or redact.
+// After is the date (2006-01-02 or RFC3339) the policy runs on its own, without it the policy runs on demand.
+// Salt keys the hashes, equal values keep equal hashes.
+type Retention struct {
+	After  string            `yaml:"after"`
+	Mode   string            `yaml:"mode"`
+	Fields map[string]string `yaml:"fields"`
+	Salt   string            `yaml:"salt"`
+}
+
+// MergePolicy decides per field whether local edits survive imports: upstream, local or lww
+type MergePolicy struct {
+	Default        string            `yaml:"default"`
+	Fields         map[string]string `yaml:"fields"`
+	TimestampField string            `yaml:"timestampfield"`
+}
+
+// RateLimit is the request budget toward one upstream, zero means unlimited.
+// Pushes and fetches share PerMinute, pushes go first but imports keep FetchShare percent (25 when not set)
+// of the requests while both wait. PushPerMinute and FetchPerMinute are summed when PerMinute is not set.
+type RateLimit struct {
+	PerMinute      int `yaml:"perminute"`
+	FetchShare     int `yaml:"fetchshare"`
+	PushPerMinute  int `yaml:"pushperminute"`
+	FetchPerMinute int `yaml:"fetchperminute"`
+	Burst          int `yaml:"burst"`
+}
+
+// UpdateSuccess describes the response an upstream must return to acknowledge a push.
+// Paths are dot separated, e.g. "result.status".
+type UpdateSuccess struct {
+	Path      string   `yaml:"path"`
+	Equals    string   `yaml:"equals"`
+	Required  []string `yaml:"required"`
+	ErrorPath string   `yaml:"errorpath"`
+}
+
+// Webhook is a subscriber notified of scan events, empty filters match everything
+type Webhook struct {
+	Name       string   `yaml:"name" json:"name"`
+	Url        string   `yaml:"url" json:"url"`
+	Secret     string   `yaml:"secret" json:"secret,omitempty"`
+	Sources    []string `yaml:"sources" json:"sources"`
+	Activities []string `yaml:"activities" json:"activities"`
+	Gateways   []string `yaml:"gateways" json:"gateways"`
 }
 
 // LoggerConfig ....
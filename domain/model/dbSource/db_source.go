@@ -1,11 +1,15 @@
 package dbSource
 
 import (
+	"encoding/json"
+	"fmt"
 	"git.anphabe.net/event/anphabe-event-hub/config"
+	"github.com/buger/jsonparser"
 	"log"
 	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 const BatchSize int = 25
@@ -17,6 +21,7 @@ type DbSource struct {
 	FetchingFormat string
 	UpdateUrl      string
 	UpdateMethod   string
+	UpdateSuccess  config.UpdateSuccess
 }
 
 func NewDBSource(cfg config.DbSource) *DbSource {
@@ -26,6 +31,7 @@ func NewDBSource(cfg config.DbSource) *DbSource {
 		FetchingFormat: cfg.FetchingFormat,
 		UpdateUrl:      cfg.UpdateUrl,
 		UpdateMethod:   cfg.UpdateMethod,
+		UpdateSuccess:  cfg.UpdateSuccess,
 	}
 }
 
@@ -63,3 +69,64 @@ func (i *DbSource) GetUpdateUrl(key string, params map[string]string) string {
 	return u.String()
 }
 
+// VerifyUpdateResponse checks the upstream response of a push against the success predicate.
+// Without a configured predicate, the response must carry a "data" field and no "error".
+func (i *DbSource) VerifyUpdateResponse(body []byte) error {
+	if !json.Valid(body) {
+		return fmt.Errorf("upstream response is not valid json")
+	}
+
+	predicate := i.UpdateSuccess
+
+	if "" == predicate.ErrorPath {
+		predicate.ErrorPath = "error"
+	}
+
+	if "" == predicate.Path && 0 == len(predicate.Required) {
+		predicate.Required = []string{"data"}
+	}
+
+	upstreamError := ""
+	if value, found := lookupJSON(body, predicate.ErrorPath); found && value != "false" && value != "" {
+		upstreamError = value
+	}
+
+	for _, path := range predicate.Required {
+		if _, found := lookupJSON(body, path); !found {
+			return updateRejected(upstreamError, "required field %s is missing", path)
+		}
+	}
+
+	if "" != predicate.Path {
+		value, _ := lookupJSON(body, predicate.Path)
+
+		if value != predicate.Equals {
+			return updateRejected(upstreamError, "%s is %q, expected %q", predicate.Path, value, predicate.Equals)
+		}
+	}
+
+	if "" != upstreamError {
+		return fmt.Errorf("upstream error: %s", upstreamError)
+	}
+
+	return nil
+}
+
+func updateRejected(upstreamError string, format string, args ...interface{}) error {
+	if "" != upstreamError {
+		return fmt.Errorf("upstream error: %s", upstreamError)
+	}
+
+	return fmt.Errorf("upstream did not acknowledge update: "+format, args...)
+}
+
+// lookupJSON resolves a dot separated path, a value of null counts as not found
+func lookupJSON(body []byte, path string) (string, bool) {
+	value, vType, _, err := jsonparser.Get(body, strings.Split(path, ".")...)
+
+	if err != nil || vType == jsonparser.Null || vType == jsonparser.NotExist {
+		return "", false
+	}
+
+	return string(value), true
+}
@@ -0,0 +1,100 @@
+package scanItem
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Session is a slot of an agenda: the activity scanned during Window in Room.
+// Gateways are the scanners of the room, the room name itself is one, a session with neither is held everywhere.
+type Session struct {
+	Name     string
+	Activity string
+	Room     string
+	Gateways []string
+	Window   TimeWindow
+}
+
+// Agenda is the sessions of a source in the order they are configured
+type Agenda struct {
+	Sessions []Session
+}
+
+func NewSession(name string, activity string, room string, gateways []string, from string, to string) (Session, error) {
+	session := Session{Name: strings.TrimSpace(name), Activity: strings.TrimSpace(activity), Room: strings.TrimSpace(room)}
+
+	if "" == session.Activity {
+		return Session{}, fmt.Errorf("session %q needs an activity", session.Name)
+	}
+
+	if "" == session.Name {
+		session.Name = session.Activity
+	}
+
+	window, err := NewTimeWindow(from, to)
+
+	if err != nil {
+		return Session{}, fmt.Errorf("session %s: %w", session.Name, err)
+	}
+
+	session.Window = window
+
+	for _, gateway := range gateways {
+		if gateway = strings.TrimSpace(gateway); "" != gateway {
+			session.Gateways = append(session.Gateways, gateway)
+		}
+	}
+
+	return session, nil
+}
+
+// Enabled tells whether the agenda has a session
+func (a Agenda) Enabled() bool {
+	return len(a.Sessions) > 0
+}
+
+// Resolve finds the session running at now for a scan at gateway. A session of the gateway wins over a session
+// held everywhere, then the first configured wins.
+func (a Agenda) Resolve(gateway string, now time.Time) (Session, bool) {
+	var everywhere *Session
+
+	for idx := range a.Sessions {
+		session := &a.Sessions[idx]
+
+		if !session.Window.Contains(now) {
+			continue
+		}
+
+		if !session.located() {
+			if nil == everywhere {
+				everywhere = session
+			}
+
+			continue
+		}
+
+		if "" != strings.TrimSpace(gateway) && session.At(gateway) {
+			return *session, true
+		}
+	}
+
+	if nil == everywhere {
+		return Session{}, false
+	}
+
+	return *everywhere, true
+}
+
+// At tells whether gateway scans for the session, the room name or one of its gateways
+func (s Session) At(gateway string) bool {
+	if !s.located() {
+		return true
+	}
+
+	return containsFold(append([]string{s.Room}, s.Gateways...), gateway)
+}
+
+func (s Session) located() bool {
+	return "" != s.Room || len(s.Gateways) > 0
+}
@@ -0,0 +1,48 @@
+package scanItem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAgenda_Resolve__Given__TimeAndGateway__Expect__RunningSessionOfTheGateway(t *testing.T) {
+	keynote, err := NewSession("Opening keynote", "keynote", "Main hall", []string{"hall-1", "hall-2"}, "09:00", "10:00")
+	assert.Nil(t, err)
+	workshop, _ := NewSession("", "workshop-a", "Room A", nil, "09:30", "11:00")
+	lunch, _ := NewSession("Lunch", "lunch", "", nil, "09:00", "14:00")
+
+	agenda := Agenda{Sessions: []Session{keynote, workshop, lunch}}
+	at := func(clock string) time.Time {
+		parsed, _ := time.ParseInLocation("2006-01-02 15:04", "2024-05-06 "+clock, time.Local)
+		return parsed
+	}
+
+	session, found := agenda.Resolve("HALL-2", at("09:15"))
+	assert.True(t, found)
+	assert.Equal(t, "keynote", session.Activity)
+
+	session, _ = agenda.Resolve("room a", at("09:45"))
+	assert.Equal(t, "workshop-a", session.Activity)
+	assert.Equal(t, "workshop-a", session.Name)
+
+	activityAt := func(gateway string, clock string) string {
+		session, _ := agenda.Resolve(gateway, at(clock))
+		return session.Activity
+	}
+
+	assert.Equal(t, "lunch", activityAt("room a", "09:15"))
+	assert.Equal(t, "lunch", activityAt("", "09:15"))
+	assert.Equal(t, "lunch", activityAt("hall-1", "12:00"))
+
+	_, found = agenda.Resolve("hall-1", at("15:00"))
+	assert.False(t, found)
+}
+
+func TestNewSession__Given__InvalidSettings__Expect__Error(t *testing.T) {
+	_, err := NewSession("Keynote", "", "Main hall", nil, "09:00", "10:00")
+	assert.NotNil(t, err)
+
+	_, err = NewSession("Keynote", "keynote", "Main hall", nil, "morning", "10:00")
+	assert.NotNil(t, err)
+}
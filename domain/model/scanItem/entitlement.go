@@ -0,0 +1,142 @@
+package scanItem
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rules of the entitlement matrix a scan can break
+const (
+	RuleEntitlement = "entitlement"
+	RuleQuota       = "quota"
+)
+
+// EntitlementMatrix maps the value of an item field, the ticket type, to the actions it may do and their daily quota.
+// A quota of 0 is unlimited. Actions no type lists are open to every item.
+type EntitlementMatrix struct {
+	Field string
+	Types map[string]map[string]int
+}
+
+// Entitlement is what an item may still do today for an action
+type Entitlement struct {
+	Action    string
+	Quota     int
+	Used      int
+	Remaining int
+	Unlimited bool
+}
+
+func NewEntitlementMatrix(field string, types map[string]map[string]int) (EntitlementMatrix, error) {
+	matrix := EntitlementMatrix{Field: strings.TrimSpace(field), Types: make(map[string]map[string]int, len(types))}
+
+	if len(types) > 0 && "" == matrix.Field {
+		return EntitlementMatrix{}, errors.New("entitlements need the field holding the ticket type")
+	}
+
+	for ticketType, actions := range types {
+		matrix.Types[strings.TrimSpace(ticketType)] = make(map[string]int, len(actions))
+
+		for action, quota := range actions {
+			if quota < 0 {
+				return EntitlementMatrix{}, fmt.Errorf("quota of %s for %s must not be negative", action, ticketType)
+			}
+
+			matrix.Types[strings.TrimSpace(ticketType)][action] = quota
+		}
+	}
+
+	return matrix, nil
+}
+
+// Enabled tells whether any ticket type is configured
+func (m EntitlementMatrix) Enabled() bool {
+	return len(m.Types) > 0
+}
+
+// governs tells whether some ticket type lists action
+func (m EntitlementMatrix) governs(action string) bool {
+	for _, actions := range m.Types {
+		if _, found := actions[action]; found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// entitlements returns the actions of the ticket type of item, ticket types are matched ignoring case
+func (m EntitlementMatrix) entitlements(item *ScanItem) map[string]int {
+	ticketType := strings.TrimSpace(item.Data[m.Field])
+
+	for candidate, actions := range m.Types {
+		if strings.EqualFold(candidate, ticketType) {
+			return actions
+		}
+	}
+
+	return nil
+}
+
+// Check denies action when the ticket type of item does not list it or when its quota is used up today
+func (m EntitlementMatrix) Check(item *ScanItem, activities []ItemActivity, action string, now time.Time) Verdict {
+	if !m.Enabled() || !m.governs(action) {
+		return Allowed()
+	}
+
+	ticketType := item.Data[m.Field]
+	quota, entitled := m.entitlements(item)[action]
+
+	if !entitled {
+		return denied(RuleEntitlement, fmt.Sprintf("%s %q is not entitled to %s", m.Field, ticketType, action))
+	}
+
+	if quota > 0 && usedOn(activities, action, now) >= quota {
+		return denied(RuleQuota, fmt.Sprintf("%s quota of %d per day is used up", action, quota))
+	}
+
+	return Allowed()
+}
+
+// Remaining lists the actions the ticket type of item is entitled to with what is left of them today
+func (m EntitlementMatrix) Remaining(item *ScanItem, activities []ItemActivity, now time.Time) []Entitlement {
+	entitlements := m.entitlements(item)
+	result := make([]Entitlement, 0, len(entitlements))
+
+	for action, quota := range entitlements {
+		entitlement := Entitlement{Action: action, Quota: quota, Used: usedOn(activities, action, now), Unlimited: 0 == quota}
+
+		if !entitlement.Unlimited && entitlement.Used < quota {
+			entitlement.Remaining = quota - entitlement.Used
+		}
+
+		result = append(result, entitlement)
+	}
+
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Action < result[b].Action
+	})
+
+	return result
+}
+
+// usedOn counts the activities of action created on the local day of now
+func usedOn(activities []ItemActivity, action string, now time.Time) int {
+	year, month, day := now.Date()
+	used := 0
+
+	for _, activity := range activities {
+		if activity.Action != action {
+			continue
+		}
+
+		if y, m, d := activity.Created.In(now.Location()).Date(); y == year && m == month && d == day {
+			used += 1
+		}
+	}
+
+	return used
+}
@@ -0,0 +1,65 @@
+package scanItem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestMatrix(t *testing.T) EntitlementMatrix {
+	matrix, err := NewEntitlementMatrix("ticket", map[string]map[string]int{
+		"VIP":      {"lounge": 0, "lunch": 2},
+		"Standard": {"lunch": 1},
+	})
+	assert.Nil(t, err)
+
+	return matrix
+}
+
+func TestEntitlementMatrix_Check__Given__TicketTypes__Expect__OnlyListedActionsAllowed(t *testing.T) {
+	matrix := newTestMatrix(t)
+	standard, _ := NewScanItem("S1", map[string]string{"ticket": "standard"})
+	unknown, _ := NewScanItem("U1", map[string]string{"ticket": "Press"})
+
+	assert.True(t, matrix.Check(standard, nil, "lunch", time.Now()).Allowed())
+	assert.True(t, matrix.Check(standard, nil, "checkin", time.Now()).Allowed(), "actions no type lists are open")
+
+	verdict := matrix.Check(standard, nil, "lounge", time.Now())
+	assert.Equal(t, VerdictDenied, verdict.Status)
+	assert.Equal(t, RuleEntitlement, verdict.Rule)
+
+	assert.Equal(t, RuleEntitlement, matrix.Check(unknown, nil, "lunch", time.Now()).Rule)
+}
+
+func TestEntitlementMatrix_Check__Given__DailyQuota__Expect__DeniedOnceUsedUpToday(t *testing.T) {
+	matrix := newTestMatrix(t)
+	vip, _ := NewScanItem("V1", map[string]string{"ticket": "VIP"})
+	now := time.Date(2019, 10, 2, 12, 0, 0, 0, time.Local)
+
+	yesterday := NewActivity("lunch", nil)
+	yesterday.Created = now.AddDate(0, 0, -1)
+	today := NewActivity("lunch", nil)
+	today.Created = now.Add(-time.Hour)
+
+	assert.True(t, matrix.Check(vip, []ItemActivity{today, yesterday, yesterday}, "lunch", now).Allowed())
+
+	verdict := matrix.Check(vip, []ItemActivity{today, today, yesterday}, "lunch", now)
+	assert.Equal(t, RuleQuota, verdict.Rule)
+
+	assert.Equal(t, []Entitlement{
+		{Action: "lounge", Unlimited: true},
+		{Action: "lunch", Quota: 2, Used: 1, Remaining: 1},
+	}, matrix.Remaining(vip, []ItemActivity{today, yesterday}, now))
+}
+
+func TestNewEntitlementMatrix__Given__InvalidSettings__Expect__Error(t *testing.T) {
+	_, err := NewEntitlementMatrix("", map[string]map[string]int{"VIP": {"lunch": 1}})
+	assert.NotNil(t, err)
+
+	_, err = NewEntitlementMatrix("ticket", map[string]map[string]int{"VIP": {"lunch": -1}})
+	assert.NotNil(t, err)
+
+	matrix, err := NewEntitlementMatrix("", nil)
+	assert.Nil(t, err)
+	assert.False(t, matrix.Enabled())
+}
@@ -0,0 +1,121 @@
+package scanItem
+
+import (
+	"context"
+)
+
+// ErrorReporter receives the storage errors of a repository its caller has no way to be returned
+type ErrorReporter func(repoName string, op string, err error)
+
+// Legacy adapts a RepositoryInterfaceV2 to RepositoryInterface. Calls run without deadline,
+// a storage error is reported the way RepositoryInterface can: not found, empty or zero.
+func Legacy(repo RepositoryInterfaceV2) RepositoryInterface {
+	return LegacyReporting(repo, nil)
+}
+
+// LegacyReporting adapts repo like Legacy, the storage errors the adapter hides are handed to report
+func LegacyReporting(repo RepositoryInterfaceV2, report ErrorReporter) RepositoryInterface {
+	return &legacyRepository{repo: repo, reporter: report}
+}
+
+// LegacyOpen adapts the result of opening a RepositoryInterfaceV2
+func LegacyOpen(repo RepositoryInterfaceV2, err error) (RepositoryInterface, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return Legacy(repo), nil
+}
+
+// Upgrade returns the RepositoryInterfaceV2 a legacy repository adapts, false when repo is not one
+func Upgrade(repo RepositoryInterface) (RepositoryInterfaceV2, bool) {
+	if legacy, ok := repo.(*legacyRepository); ok {
+		return legacy.repo, true
+	}
+
+	return nil, false
+}
+
+type legacyRepository struct {
+	repo     RepositoryInterfaceV2
+	reporter ErrorReporter
+}
+
+func (r *legacyRepository) report(op string, err error) {
+	if err != nil && nil != r.reporter {
+		r.reporter(r.repo.GetRepoName(), op, err)
+	}
+}
+
+func (r *legacyRepository) GetRepoName() string {
+	return r.repo.GetRepoName()
+}
+
+func (r *legacyRepository) NewItem(itemKey string, data map[string]string) (*ScanItem, error) {
+	return r.repo.NewItem(context.Background(), itemKey, data)
+}
+
+func (r *legacyRepository) SetItem(item *ScanItem) {
+	r.report("set item", r.repo.SetItem(context.Background(), item))
+}
+
+func (r *legacyRepository) GetItem(key string) (*ScanItem, bool) {
+	item, found, err := r.repo.GetItem(context.Background(), key)
+	r.report("get item", err)
+
+	return item, found && nil == err
+}
+
+func (r *legacyRepository) GetItemDetail(key string) (*ItemDetail, bool) {
+	item, found, err := r.repo.GetItemDetail(context.Background(), key)
+	r.report("get item detail", err)
+
+	return item, found && nil == err
+}
+
+func (r *legacyRepository) Items() []*ScanItem {
+	items, err := r.repo.Items(context.Background())
+	r.report("items", err)
+
+	return items
+}
+
+func (r *legacyRepository) Query(query Query) (QueryResult, error) {
+	return r.repo.Query(context.Background(), query)
+}
+
+func (r *legacyRepository) Len() int {
+	count, err := r.repo.Len(context.Background())
+	r.report("len", err)
+
+	return count
+}
+
+func (r *legacyRepository) GetItemActivities(itemKey string) *ItemActivities {
+	activities, err := r.repo.GetItemActivities(context.Background(), itemKey)
+	r.report("get item activities", err)
+
+	return activities
+}
+
+func (r *legacyRepository) AddItemActivity(itemKey string, activity ItemActivity) *ItemActivities {
+	activities, err := r.repo.AddItemActivity(context.Background(), itemKey, activity)
+	r.report("add item activity", err)
+
+	if err != nil {
+		return nil
+	}
+
+	return activities
+}
+
+func (r *legacyRepository) SetActivitySync(itemKey string, activityId string, sync ActivitySync) bool {
+	updated, err := r.repo.SetActivitySync(context.Background(), itemKey, activityId, sync)
+	r.report("set activity sync", err)
+
+	return updated
+}
+
+func (r *legacyRepository) CloseDb() {
+	r.report("close", r.repo.CloseDb(context.Background()))
+}
@@ -0,0 +1,73 @@
+package scanItem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// unavailableRepository fails every call the way a storage which went away does
+type unavailableRepository struct {
+	RepositoryInterfaceV2
+}
+
+func (r unavailableRepository) GetRepoName() string {
+	return "guests"
+}
+
+func (r unavailableRepository) fail(op string) error {
+	return NewStorageError("guests", op, errors.New("disk I/O error"))
+}
+
+func (r unavailableRepository) GetItem(ctx context.Context, key string) (*ScanItem, bool, error) {
+	return nil, false, r.fail("get item")
+}
+
+func (r unavailableRepository) Items(ctx context.Context) ([]*ScanItem, error) {
+	return nil, r.fail("items")
+}
+
+func (r unavailableRepository) AddItemActivity(ctx context.Context, itemKey string, activity ItemActivity) (*ItemActivities, error) {
+	return &ItemActivities{Key: itemKey}, r.fail("add item activity")
+}
+
+func TestLegacy__Given__StorageErrors__Expect__NotFound_Or_Empty(t *testing.T) {
+	sut := Legacy(unavailableRepository{})
+
+	item, found := sut.GetItem("1")
+	assert.Nil(t, item)
+	assert.False(t, found)
+	assert.Empty(t, sut.Items())
+	assert.Nil(t, sut.AddItemActivity("1", NewActivity("checkin", nil)))
+}
+
+func TestUpgrade__Expect__The_Adapted_Repository(t *testing.T) {
+	repo := unavailableRepository{}
+
+	got, ok := Upgrade(Legacy(repo))
+	assert.True(t, ok)
+	assert.Exactly(t, RepositoryInterfaceV2(repo), got)
+
+	_, ok = Upgrade(nil)
+	assert.False(t, ok)
+}
+
+func TestStorageError__Expect__Found_When_Wrapped(t *testing.T) {
+	err := NewStorageError("guests", "items", context.DeadlineExceeded)
+
+	assert.Exactly(t, "guests: items: context deadline exceeded", err.Error())
+	assert.True(t, IsStorageError(fmt.Errorf("query: %w", err)))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, IsStorageError(ErrInvalidCursor))
+}
+
+func TestCheckContext__Given__Cancelled__Expect__StorageError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	assert.Nil(t, CheckContext(ctx, "guests", "items"))
+
+	cancel()
+	assert.True(t, IsStorageError(CheckContext(ctx, "guests", "items")))
+}
@@ -0,0 +1,137 @@
+package scanItem
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Merge policies of a locally owned field when upstream data is imported
+const (
+	// MergeUpstreamWins drops the local value at the next import
+	MergeUpstreamWins = "upstream"
+	// MergeLocalWins keeps the local value until upstream holds the same value
+	MergeLocalWins = "local"
+	// MergeLastWriterWins compares the local edit time with the upstream row time
+	MergeLastWriterWins = "lww"
+)
+
+var upstreamTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2 Jan 2006 15:04:05",
+	"02/01/2006 15:04:05",
+}
+
+type LocalField struct {
+	Value   string
+	Updated time.Time
+}
+
+// MergePolicy tells, per field, whether local edits survive an import.
+// TimestampField names the upstream column holding the row modification time MergeLastWriterWins compares
+// with the time of the local edit, a row without a readable time can not be newer and keeps the local value.
+type MergePolicy struct {
+	Default        string
+	Fields         map[string]string
+	TimestampField string
+}
+
+func NewMergePolicy(defaultPolicy string, fields map[string]string, timestampField string) MergePolicy {
+	if "" == defaultPolicy {
+		defaultPolicy = MergeLocalWins
+	}
+
+	normalized := make(map[string]string, len(fields))
+	for field, policy := range fields {
+		normalized[field] = strings.ToLower(policy)
+	}
+
+	return MergePolicy{
+		Default:        strings.ToLower(defaultPolicy),
+		Fields:         normalized,
+		TimestampField: timestampField,
+	}
+}
+
+// Validate refuses a policy which is not upstream, local or lww, it would silently keep local values.
+// Last writer wins needs the upstream column holding the row time, the upstream side would have no time to be compared with.
+func (p MergePolicy) Validate() error {
+	if "" != p.Default && !knownMergePolicy(p.Default) {
+		return fmt.Errorf("unknown merge policy %q, use %s, %s or %s", p.Default, MergeUpstreamWins, MergeLocalWins, MergeLastWriterWins)
+	}
+
+	for field, policy := range p.Fields {
+		if !knownMergePolicy(policy) {
+			return fmt.Errorf("unknown merge policy %q of %s, use %s, %s or %s", policy, field, MergeUpstreamWins, MergeLocalWins, MergeLastWriterWins)
+		}
+	}
+
+	if "" != strings.TrimSpace(p.TimestampField) {
+		return nil
+	}
+
+	if MergeLastWriterWins == p.Default {
+		return errors.New("merge policy lww needs the timestampfield of the upstream rows")
+	}
+
+	for field, policy := range p.Fields {
+		if MergeLastWriterWins == policy {
+			return fmt.Errorf("merge policy lww of %s needs the timestampfield of the upstream rows", field)
+		}
+	}
+
+	return nil
+}
+
+func knownMergePolicy(policy string) bool {
+	return MergeUpstreamWins == policy || MergeLocalWins == policy || MergeLastWriterWins == policy
+}
+
+func (p MergePolicy) For(field string) string {
+	if policy, found := p.Fields[field]; found {
+		return policy
+	}
+
+	if "" == p.Default {
+		return MergeLocalWins
+	}
+
+	return p.Default
+}
+
+func (p MergePolicy) keepLocal(field string, local LocalField, upstream map[string]string) bool {
+	upstreamValue, found := upstream[field]
+
+	// upstream confirmed the local value, the field is owned by upstream again
+	if found && upstreamValue == local.Value {
+		return false
+	}
+
+	switch p.For(field) {
+	case MergeUpstreamWins:
+		return false
+
+	case MergeLastWriterWins:
+		upstreamTime, known := p.upstreamTime(upstream)
+
+		return !known || local.Updated.After(upstreamTime)
+
+	default:
+		return true
+	}
+}
+
+// upstreamTime reads the row modification time, false when the row has none
+func (p MergePolicy) upstreamTime(upstream map[string]string) (time.Time, bool) {
+	if value, found := upstream[p.TimestampField]; found && "" != p.TimestampField {
+		for _, layout := range upstreamTimeLayouts {
+			if t, err := time.ParseInLocation(layout, strings.TrimSpace(value), time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
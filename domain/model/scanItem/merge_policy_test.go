@@ -0,0 +1,89 @@
+package scanItem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestScanItem_Merge_On_MergePolicies(t *testing.T) {
+	edited := time.Date(2019, 9, 10, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		policy    MergePolicy
+		upstream  map[string]string
+		wantData  map[string]string
+		wantLocal bool
+	}{
+		{
+			name:      "__Given__LocalWins_And_UpstreamNotConfirmed__Expect__LocalValueKept",
+			policy:    NewMergePolicy("", nil, ""),
+			upstream:  map[string]string{"name": "Hong", "seat": ""},
+			wantData:  map[string]string{"name": "Hong", "seat": "A12"},
+			wantLocal: true,
+		},
+		{
+			name:      "__Given__LocalWins_And_UpstreamConfirmed__Expect__FieldOwnedByUpstream",
+			policy:    NewMergePolicy(MergeLocalWins, nil, ""),
+			upstream:  map[string]string{"name": "Hong", "seat": "A12"},
+			wantData:  map[string]string{"name": "Hong", "seat": "A12"},
+			wantLocal: false,
+		},
+		{
+			name:      "__Given__UpstreamWins__Expect__LocalValueDropped",
+			policy:    NewMergePolicy(MergeLocalWins, map[string]string{"seat": "Upstream"}, ""),
+			upstream:  map[string]string{"name": "Hong", "seat": "B1"},
+			wantData:  map[string]string{"name": "Hong", "seat": "B1"},
+			wantLocal: false,
+		},
+		{
+			name:      "__Given__LastWriterWins_And_UpstreamRowOlder__Expect__LocalValueKept",
+			policy:    NewMergePolicy(MergeLastWriterWins, nil, "updated"),
+			upstream:  map[string]string{"name": "Hong", "seat": "B1", "updated": "2019-09-10 08:00:00"},
+			wantData:  map[string]string{"name": "Hong", "seat": "A12", "updated": "2019-09-10 08:00:00"},
+			wantLocal: true,
+		},
+		{
+			name:      "__Given__LastWriterWins_And_UpstreamRowNewer__Expect__UpstreamValueWins",
+			policy:    NewMergePolicy(MergeLastWriterWins, nil, "updated"),
+			upstream:  map[string]string{"name": "Hong", "seat": "B1", "updated": "2019-09-10 10:00:00"},
+			wantData:  map[string]string{"name": "Hong", "seat": "B1", "updated": "2019-09-10 10:00:00"},
+			wantLocal: false,
+		},
+		{
+			name:      "__Given__LastWriterWins_And_NoRowTime__Expect__LocalValueKept",
+			policy:    NewMergePolicy(MergeLastWriterWins, nil, "updated"),
+			upstream:  map[string]string{"name": "Hong", "seat": "B1"},
+			wantData:  map[string]string{"name": "Hong", "seat": "A12"},
+			wantLocal: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, _ := NewScanItem("key", map[string]string{"name": "Hong"})
+			item.SetLocalField("seat", "A12", edited)
+
+			got := item.Merge(tt.upstream, tt.policy)
+
+			assert.Exactly(t, tt.wantData, got.Data)
+			_, hasLocal := got.Local["seat"]
+			assert.Exactly(t, tt.wantLocal, hasLocal)
+		})
+	}
+}
+
+func TestMergePolicy_Validate__Given__LastWriterWinsWithoutTimestampField__Expect__Error(t *testing.T) {
+	assert.NotNil(t, NewMergePolicy(MergeLastWriterWins, nil, "").Validate())
+	assert.NotNil(t, NewMergePolicy(MergeLocalWins, map[string]string{"seat": "LWW"}, "").Validate())
+	assert.Nil(t, NewMergePolicy(MergeLastWriterWins, nil, "updated").Validate())
+	assert.Nil(t, NewMergePolicy(MergeLocalWins, map[string]string{"seat": "upstream"}, "").Validate())
+}
+
+func TestMergePolicy_Validate__Given__UnknownPolicy__Expect__Error(t *testing.T) {
+	assert.NotNil(t, NewMergePolicy("upsteam", nil, "").Validate())
+	assert.NotNil(t, NewMergePolicy(MergeLocalWins, map[string]string{"seat": "upsteam"}, "").Validate())
+	assert.NotNil(t, NewMergePolicy(MergeLocalWins, map[string]string{"seat": ""}, "").Validate())
+	assert.Nil(t, NewMergePolicy("", map[string]string{"seat": "Upstream"}, "").Validate())
+}
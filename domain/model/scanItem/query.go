@@ -0,0 +1,313 @@
+package scanItem
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Field filter operators, prefix and contains ignore case
+const (
+	FilterEquals   = "eq"
+	FilterPrefix   = "prefix"
+	FilterContains = "contains"
+)
+
+// SortByKey sorts items by their key, which is also the tie breaker of every other sort
+const SortByKey = "Key"
+
+type FieldFilter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// Query selects a page of items.
+// HasActivity, From and To keep items with at least one activity of that action (any action when empty)
+// created in [From, To), zero times are open bounds.
+// A page starts after Cursor when it is set, otherwise after Offset items.
+type Query struct {
+	Fields      []FieldFilter
+	HasActivity string
+	From        time.Time
+	To          time.Time
+	SortField   string
+	SortDesc    bool
+	Offset      int
+	Limit       int
+	Cursor      string
+}
+
+// QueryResult is a page of items, Total counts every matching item
+type QueryResult struct {
+	Items      []*ScanItem
+	Total      int
+	NextCursor string
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type cursorPosition struct {
+	Value string `json:"v"`
+	Key   string `json:"k"`
+}
+
+// Run applies the query to items, activities is only called when the query filters on activities
+func (q Query) Run(items []*ScanItem, activities func(itemKey string) *ItemActivities) (QueryResult, error) {
+	result := QueryResult{}
+
+	var matches []*ScanItem
+
+	for _, item := range items {
+		if q.matchFields(item) && q.matchActivities(item, activities) {
+			matches = append(matches, item)
+		}
+	}
+
+	sort.Slice(matches, func(a, b int) bool {
+		return q.less(q.position(matches[a]), q.position(matches[b]))
+	})
+
+	result.Total = len(matches)
+	start := q.Offset
+
+	if "" != q.Cursor {
+		after, err := decodeCursor(q.Cursor)
+
+		if err != nil {
+			return result, err
+		}
+
+		start = sort.Search(len(matches), func(idx int) bool {
+			return q.less(after, q.position(matches[idx]))
+		})
+	}
+
+	if start > len(matches) {
+		start = len(matches)
+	} else if start < 0 {
+		start = 0
+	}
+
+	end := len(matches)
+
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+		result.NextCursor = encodeCursor(q.position(matches[end-1]))
+	}
+
+	result.Items = matches[start:end]
+
+	return result, nil
+}
+
+// KeyOrdered tells whether the query sorts by key, a repository storing items in key order can then stream the page with a KeyPager
+func (q Query) KeyOrdered() bool {
+	return "" == q.SortField || SortByKey == q.SortField
+}
+
+// Filters tells whether the query filters items, Total then needs every item to be checked
+func (q Query) Filters() bool {
+	return len(q.Fields) > 0 || q.filtersActivities()
+}
+
+// FiltersActivities tells whether the query keeps items by their activities
+func (q Query) FiltersActivities() bool {
+	return q.filtersActivities()
+}
+
+// SeekKey returns the key a key ordered page starts after, "" when it starts at the first item.
+// Without filters a repository may seek past it, items before the cursor only matter to Total otherwise.
+func (q Query) SeekKey() (string, error) {
+	if "" == q.Cursor {
+		return "", nil
+	}
+
+	after, err := decodeCursor(q.Cursor)
+
+	return after.Key, err
+}
+
+// KeyPager builds the page of a key ordered query from items offered in key order, the greatest key first when it sorts descending.
+// It only keeps the page, so a repository answers a query without loading all of its items.
+type KeyPager struct {
+	query   Query
+	after   *cursorPosition
+	skipped int
+	result  QueryResult
+}
+
+func NewKeyPager(query Query) (*KeyPager, error) {
+	pager := &KeyPager{query: query}
+
+	if "" != query.Cursor {
+		after, err := decodeCursor(query.Cursor)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pager.after = &after
+	}
+
+	return pager, nil
+}
+
+// Offer takes the next item, activities is only called when the query filters on activities.
+// It returns false once no further item can change the page nor, for a query without filters, the total.
+func (p *KeyPager) Offer(item *ScanItem, activities func(itemKey string) (*ItemActivities, error)) (bool, error) {
+	if !p.query.matchFields(item) {
+		return true, nil
+	}
+
+	if p.query.filtersActivities() {
+		stored, err := activities(item.Key)
+
+		if err != nil {
+			return false, err
+		}
+
+		if !p.query.matchActivities(item, func(string) *ItemActivities { return stored }) {
+			return true, nil
+		}
+	}
+
+	p.result.Total += 1
+	position := p.query.position(item)
+
+	switch {
+	case nil != p.after:
+		if !p.query.less(*p.after, position) {
+			return true, nil
+		}
+	case p.skipped < p.query.Offset:
+		p.skipped += 1
+		return true, nil
+	}
+
+	if p.query.Limit > 0 && len(p.result.Items) == p.query.Limit {
+		if "" == p.result.NextCursor {
+			p.result.NextCursor = encodeCursor(p.query.position(p.result.Items[len(p.result.Items)-1]))
+		}
+
+		return p.query.Filters(), nil
+	}
+
+	p.result.Items = append(p.result.Items, item)
+
+	return true, nil
+}
+
+// Result returns the page. A query without filters counts every item, count is then the total,
+// as the repository may have seeked past or stopped before items the pager was not offered.
+func (p *KeyPager) Result(count int) QueryResult {
+	result := p.result
+
+	if !p.query.Filters() {
+		result.Total = count
+	}
+
+	return result
+}
+
+func (q Query) matchFields(item *ScanItem) bool {
+	for _, filter := range q.Fields {
+		value := item.Data[filter.Field]
+
+		if SortByKey == filter.Field {
+			value = item.Key
+		}
+
+		switch filter.Op {
+		case FilterPrefix:
+			if !strings.HasPrefix(strings.ToLower(value), strings.ToLower(filter.Value)) {
+				return false
+			}
+		case FilterContains:
+			if !strings.Contains(strings.ToLower(value), strings.ToLower(filter.Value)) {
+				return false
+			}
+		default:
+			if value != filter.Value {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (q Query) filtersActivities() bool {
+	return "" != q.HasActivity || !q.From.IsZero() || !q.To.IsZero()
+}
+
+func (q Query) matchActivities(item *ScanItem, activities func(itemKey string) *ItemActivities) bool {
+	if !q.filtersActivities() {
+		return true
+	}
+
+	stored := activities(item.Key)
+
+	if nil == stored {
+		return false
+	}
+
+	for _, activity := range stored.Activities {
+		if "" != q.HasActivity && activity.Action != q.HasActivity {
+			continue
+		}
+
+		if !q.From.IsZero() && activity.Created.Before(q.From) {
+			continue
+		}
+
+		if !q.To.IsZero() && !activity.Created.Before(q.To) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func (q Query) position(item *ScanItem) cursorPosition {
+	if "" == q.SortField || SortByKey == q.SortField {
+		return cursorPosition{Key: item.Key}
+	}
+
+	return cursorPosition{Value: item.Data[q.SortField], Key: item.Key}
+}
+
+func (q Query) less(a cursorPosition, b cursorPosition) bool {
+	if a.Value != b.Value {
+		return (a.Value < b.Value) != q.SortDesc
+	}
+
+	if a.Key != b.Key {
+		return (a.Key < b.Key) != q.SortDesc
+	}
+
+	return false
+}
+
+func encodeCursor(position cursorPosition) string {
+	raw, _ := json.Marshal(position)
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(cursor string) (cursorPosition, error) {
+	var position cursorPosition
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	if err != nil || nil != json.Unmarshal(raw, &position) {
+		return position, ErrInvalidCursor
+	}
+
+	return position, nil
+}
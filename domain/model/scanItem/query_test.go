@@ -0,0 +1,158 @@
+package scanItem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func queryTestItems() ([]*ScanItem, func(itemKey string) *ItemActivities) {
+	checkedIn := time.Date(2019, 9, 10, 9, 0, 0, 0, time.Local)
+
+	items := []*ScanItem{
+		{Key: "1", Data: map[string]string{"Name": "Nguyễn An", "Company": "Acme"}},
+		{Key: "2", Data: map[string]string{"Name": "Trần Bình", "Company": "Acme"}},
+		{Key: "3", Data: map[string]string{"Name": "Lê Chi", "Company": "Globex"}},
+		{Key: "4", Data: map[string]string{"Name": "nguyễn Dũng", "Company": "Globex"}},
+	}
+
+	activities := map[string][]ItemActivity{
+		"2": {{Action: "checkin", Created: checkedIn}},
+		"3": {{Action: "lunch", Created: checkedIn.Add(3 * time.Hour)}, {Action: "checkin", Created: checkedIn.Add(time.Hour)}},
+	}
+
+	return items, func(itemKey string) *ItemActivities {
+		return &ItemActivities{Key: itemKey, Activities: activities[itemKey]}
+	}
+}
+
+func queryKeys(result QueryResult) []string {
+	keys := []string{}
+
+	for _, item := range result.Items {
+		keys = append(keys, item.Key)
+	}
+
+	return keys
+}
+
+func TestQuery_Run_On_Filters(t *testing.T) {
+	checkedIn := time.Date(2019, 9, 10, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{name: "no filter", query: Query{}, want: []string{"1", "2", "3", "4"}},
+		{name: "equality", query: Query{Fields: []FieldFilter{{Field: "Company", Op: FilterEquals, Value: "Acme"}}}, want: []string{"1", "2"}},
+		{name: "prefix ignores case", query: Query{Fields: []FieldFilter{{Field: "Name", Op: FilterPrefix, Value: "NGUYỄN"}}}, want: []string{"1", "4"}},
+		{name: "contains", query: Query{Fields: []FieldFilter{{Field: "Name", Op: FilterContains, Value: "bình"}}}, want: []string{"2"}},
+		{name: "has activity", query: Query{HasActivity: "checkin"}, want: []string{"2", "3"}},
+		{name: "activity time range", query: Query{HasActivity: "checkin", From: checkedIn.Add(time.Minute)}, want: []string{"3"}},
+		{name: "any activity before", query: Query{To: checkedIn.Add(time.Minute)}, want: []string{"2"}},
+		{name: "sort descending", query: Query{SortField: "Name", SortDesc: true}, want: []string{"4", "2", "1", "3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, activities := queryTestItems()
+			got, err := tt.query.Run(items, activities)
+
+			assert.Nil(t, err)
+			assert.Exactly(t, tt.want, queryKeys(got))
+			assert.Exactly(t, len(tt.want), got.Total)
+		})
+	}
+}
+
+func TestQuery_Run_Given_Cursor_Expect_NextPages(t *testing.T) {
+	items, activities := queryTestItems()
+	query := Query{SortField: "Company", Limit: 3}
+
+	first, _ := query.Run(items, activities)
+	assert.Exactly(t, []string{"1", "2", "3"}, queryKeys(first))
+	assert.NotEmpty(t, first.NextCursor)
+
+	query.Cursor = first.NextCursor
+	second, _ := query.Run(items, activities)
+	assert.Exactly(t, []string{"4"}, queryKeys(second))
+	assert.Empty(t, second.NextCursor)
+	assert.Exactly(t, 4, second.Total)
+
+	query.Cursor = "%%"
+	_, err := query.Run(items, activities)
+	assert.Exactly(t, ErrInvalidCursor, err)
+}
+
+func TestQuery_Run_Given_Offset_Expect_Page(t *testing.T) {
+	items, activities := queryTestItems()
+
+	got, _ := Query{Offset: 2, Limit: 1}.Run(items, activities)
+	assert.Exactly(t, []string{"3"}, queryKeys(got))
+
+	got, _ = Query{Offset: 10, Limit: 1}.Run(items, activities)
+	assert.Empty(t, got.Items)
+}
+
+// pageByKey offers items in key order, the way a repository streaming its storage does
+func pageByKey(query Query, items []*ScanItem, activities func(itemKey string) *ItemActivities) (QueryResult, error) {
+	pager, err := NewKeyPager(query)
+
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	for idx := range items {
+		item := items[idx]
+
+		if query.SortDesc {
+			item = items[len(items)-1-idx]
+		}
+
+		more, err := pager.Offer(item, func(itemKey string) (*ItemActivities, error) { return activities(itemKey), nil })
+
+		if err != nil || !more {
+			break
+		}
+	}
+
+	return pager.Result(len(items)), nil
+}
+
+func TestKeyPager_Given_KeyOrderedQuery_Expect_SamePagesAsRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+	}{
+		{name: "all", query: Query{}},
+		{name: "first page", query: Query{Limit: 2}},
+		{name: "offset", query: Query{Offset: 1, Limit: 2}},
+		{name: "descending", query: Query{SortField: SortByKey, SortDesc: true, Limit: 3}},
+		{name: "filtered", query: Query{Fields: []FieldFilter{{Field: "Company", Op: FilterEquals, Value: "Globex"}}, Limit: 1}},
+		{name: "has activity", query: Query{HasActivity: "checkin", Limit: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, activities := queryTestItems()
+			query := tt.query
+
+			for page := 0; page < 5; page++ {
+				want, _ := query.Run(items, activities)
+				got, err := pageByKey(query, items, activities)
+
+				assert.Nil(t, err)
+				assert.Exactly(t, queryKeys(want), queryKeys(got))
+				assert.Exactly(t, want.Total, got.Total)
+				assert.Exactly(t, want.NextCursor, got.NextCursor)
+
+				if "" == want.NextCursor {
+					break
+				}
+
+				query.Cursor, query.Offset = want.NextCursor, 0
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package scanItem
 
+// RepositoryInterface is the repository as seen by callers not migrated to RepositoryInterfaceV2 yet,
+// storage adapters implement RepositoryInterfaceV2 and are adapted with Legacy
 type RepositoryInterface interface {
 	GetRepoName() string
 	NewItem(itemKey string, data map[string]string) (*ScanItem, error)
@@ -7,9 +9,34 @@ type RepositoryInterface interface {
 	GetItem(key string) (*ScanItem, bool)
 	GetItemDetail(key string) (*ItemDetail, bool)
 	Items() []*ScanItem
+	Query(query Query) (QueryResult, error)
 	Len() int
 	// latest item on top
 	GetItemActivities(itemKey string) *ItemActivities
 	AddItemActivity(itemKey string, activity ItemActivity) *ItemActivities
+	SetActivitySync(itemKey string, activityId string, sync ActivitySync) bool
 	CloseDb()
 }
+
+// RepositoryWrapper is implemented by repositories which decorate another repository
+type RepositoryWrapper interface {
+	Unwrap() RepositoryInterfaceV2
+}
+
+// Layers lists repo then every repository it decorates, outermost first
+func Layers(repo RepositoryInterfaceV2) []RepositoryInterfaceV2 {
+	var layers []RepositoryInterfaceV2
+
+	for nil != repo {
+		layers = append(layers, repo)
+
+		wrapper, ok := repo.(RepositoryWrapper)
+		if !ok {
+			break
+		}
+
+		repo = wrapper.Unwrap()
+	}
+
+	return layers
+}
@@ -0,0 +1,104 @@
+package scanItem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReservedPrefix starts the names storages keep for their own records, no repository may be named with it
+const ReservedPrefix = "_hub_"
+
+var ErrReservedName = errors.New("names starting with " + ReservedPrefix + " are reserved")
+
+// RepositoryInterfaceV2 is the repository implemented by storage adapters and their decorators.
+// Every call which may touch the storage takes a context and reports failures as errors,
+// a missing item is not an error: it is reported by the found result.
+type RepositoryInterfaceV2 interface {
+	GetRepoName() string
+	NewItem(ctx context.Context, itemKey string, data map[string]string) (*ScanItem, error)
+	SetItem(ctx context.Context, item *ScanItem) error
+	GetItem(ctx context.Context, key string) (*ScanItem, bool, error)
+	GetItemDetail(ctx context.Context, key string) (*ItemDetail, bool, error)
+	Items(ctx context.Context) ([]*ScanItem, error)
+	Query(ctx context.Context, query Query) (QueryResult, error)
+	Len(ctx context.Context) (int, error)
+	// latest item on top, nil when the item does not exist
+	GetItemActivities(ctx context.Context, itemKey string) (*ItemActivities, error)
+	AddItemActivity(ctx context.Context, itemKey string, activity ItemActivity) (*ItemActivities, error)
+	SetActivitySync(ctx context.Context, itemKey string, activityId string, sync ActivitySync) (bool, error)
+	CloseDb(ctx context.Context) error
+}
+
+// StorageError is a repository operation which failed in the storage, or was cancelled, rather than on its input
+type StorageError struct {
+	Repo string
+	Op   string
+	Err  error
+}
+
+func NewStorageError(repo string, op string, err error) *StorageError {
+	return &StorageError{Repo: repo, Op: op, Err: err}
+}
+
+func (e *StorageError) Error() string {
+	return e.Repo + ": " + e.Op + ": " + e.Err.Error()
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
+// IsStorageError tells whether err, or an error it wraps, is a StorageError
+func IsStorageError(err error) bool {
+	var storageErr *StorageError
+
+	return errors.As(err, &storageErr)
+}
+
+// CheckContext returns a StorageError when ctx is already done, adapters call it before touching the storage
+func CheckContext(ctx context.Context, repo string, op string) error {
+	if err := ctx.Err(); err != nil {
+		return NewStorageError(repo, op, err)
+	}
+
+	return nil
+}
+
+// RunQuery runs query over the items of repo, for adapters which have no query engine of their own
+func RunQuery(ctx context.Context, repo RepositoryInterfaceV2, query Query) (QueryResult, error) {
+	items, err := repo.Items(ctx)
+
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	var activitiesErr error
+
+	result, err := query.Run(items, func(itemKey string) *ItemActivities {
+		if activitiesErr != nil {
+			return nil
+		}
+
+		activities, err := repo.GetItemActivities(ctx, itemKey)
+		activitiesErr = err
+
+		return activities
+	})
+
+	if activitiesErr != nil {
+		return QueryResult{}, activitiesErr
+	}
+
+	return result, err
+}
+
+// CheckRepoName refuses the names storages keep for themselves
+func CheckRepoName(name string) error {
+	if strings.HasPrefix(name, ReservedPrefix) {
+		return fmt.Errorf("repository %q: %w", name, ErrReservedName)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package scanItem
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Retention modes, what is left of the personal data of a source once the event is over
+const (
+	// RetentionPurge empties the data of every item, keys and activities are kept
+	RetentionPurge = "purge"
+	// RetentionAnonymise rewrites the configured fields only
+	RetentionAnonymise = "anonymise"
+)
+
+// Treatments of a field by RetentionAnonymise
+const (
+	// FieldHash replaces the value by its salted SHA-256, equal values stay equal
+	FieldHash = "hash"
+	// FieldRedact replaces the value by Redacted
+	FieldRedact = "redact"
+)
+
+const (
+	Redacted   = "[redacted]"
+	hashPrefix = "sha256:"
+)
+
+var retentionDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// RetentionPolicy tells when and how the personal data of a source is removed.
+// A zero After never runs on its own, the policy then only runs on demand.
+type RetentionPolicy struct {
+	After  time.Time
+	Mode   string
+	Fields map[string]string
+	Salt   string
+}
+
+func NewRetentionPolicy(after string, mode string, fields map[string]string, salt string) (RetentionPolicy, error) {
+	policy := RetentionPolicy{
+		Mode:   strings.ToLower(strings.TrimSpace(mode)),
+		Fields: make(map[string]string, len(fields)),
+		Salt:   salt,
+	}
+
+	if after = strings.TrimSpace(after); "" != after {
+		if policy.After = parseRetentionDate(after); policy.After.IsZero() {
+			return RetentionPolicy{}, fmt.Errorf("retention date %q is not a date", after)
+		}
+	}
+
+	for field, treatment := range fields {
+		policy.Fields[field] = strings.ToLower(treatment)
+
+		if FieldHash != policy.Fields[field] && FieldRedact != policy.Fields[field] {
+			return RetentionPolicy{}, fmt.Errorf("retention of field %s must be %s or %s", field, FieldHash, FieldRedact)
+		}
+	}
+
+	switch policy.Mode {
+	case "":
+		if !policy.After.IsZero() || len(policy.Fields) > 0 {
+			return RetentionPolicy{}, fmt.Errorf("retention mode must be %s or %s", RetentionPurge, RetentionAnonymise)
+		}
+	case RetentionPurge:
+	case RetentionAnonymise:
+		if 0 == len(policy.Fields) {
+			return RetentionPolicy{}, fmt.Errorf("retention mode %s needs fields", RetentionAnonymise)
+		}
+	default:
+		return RetentionPolicy{}, fmt.Errorf("retention mode must be %s or %s", RetentionPurge, RetentionAnonymise)
+	}
+
+	return policy, nil
+}
+
+func parseRetentionDate(value string) time.Time {
+	for _, layout := range retentionDateLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); nil == err {
+			return parsed
+		}
+	}
+
+	return time.Time{}
+}
+
+// Enabled tells whether a mode is configured
+func (p RetentionPolicy) Enabled() bool {
+	return "" != p.Mode
+}
+
+// Due tells whether the configured date is past
+func (p RetentionPolicy) Due(now time.Time) bool {
+	return p.Enabled() && !p.After.IsZero() && !now.Before(p.After)
+}
+
+// Anonymise builds the item left by the policy, changed is false when the item holds no personal data anymore.
+// Local fields get the treatment of the data field they override.
+func (i *ScanItem) Anonymise(policy RetentionPolicy) (anonymised *ScanItem, changed bool) {
+	anonymised = &ScanItem{Key: i.Key, Data: make(map[string]string, len(i.Data))}
+
+	if RetentionPurge == policy.Mode {
+		return anonymised, len(i.Data) > 0 || len(i.Local) > 0
+	}
+
+	for field, value := range i.Data {
+		anonymised.Data[field] = policy.anonymiseField(field, value)
+		changed = changed || anonymised.Data[field] != value
+	}
+
+	for field, local := range i.Local {
+		anonymised.SetLocalField(field, policy.anonymiseField(field, local.Value), local.Updated)
+		changed = changed || anonymised.Local[field].Value != local.Value
+	}
+
+	return anonymised, changed
+}
+
+// anonymiseField is idempotent, a value hashed or redacted already is left as is
+func (p RetentionPolicy) anonymiseField(field string, value string) string {
+	switch p.Fields[field] {
+	case FieldRedact:
+		return Redacted
+	case FieldHash:
+		if "" == value || strings.HasPrefix(value, hashPrefix) {
+			return value
+		}
+
+		mac := hmac.New(sha256.New, []byte(p.Salt))
+		mac.Write([]byte(value))
+
+		return hashPrefix + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	return value
+}
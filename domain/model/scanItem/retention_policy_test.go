@@ -0,0 +1,79 @@
+package scanItem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewRetentionPolicy__Given__InvalidSettings__Expect__Error(t *testing.T) {
+	tests := []struct {
+		name   string
+		after  string
+		mode   string
+		fields map[string]string
+	}{
+		{name: "__Given__UnknownMode", mode: "shred"},
+		{name: "__Given__DateWithoutMode", after: "2019-10-01"},
+		{name: "__Given__NotADate", after: "after the event", mode: RetentionPurge},
+		{name: "__Given__AnonymiseWithoutFields", mode: RetentionAnonymise},
+		{name: "__Given__UnknownFieldTreatment", mode: RetentionAnonymise, fields: map[string]string{"email": "encrypt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRetentionPolicy(tt.after, tt.mode, tt.fields, "")
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestRetentionPolicy_Due__Given__Date__Expect__DueFromThatDateOn(t *testing.T) {
+	policy, err := NewRetentionPolicy("2019-10-01", "Purge", nil, "")
+	assert.Nil(t, err)
+
+	assert.False(t, policy.Due(time.Date(2019, 9, 30, 23, 59, 0, 0, time.Local)))
+	assert.True(t, policy.Due(time.Date(2019, 10, 1, 0, 0, 0, 0, time.Local)))
+
+	onDemand, _ := NewRetentionPolicy("", RetentionPurge, nil, "")
+	assert.True(t, onDemand.Enabled())
+	assert.False(t, onDemand.Due(time.Now()))
+}
+
+func TestScanItem_Anonymise__Given__Purge__Expect__DataAndLocalFieldsEmptied(t *testing.T) {
+	item, _ := NewScanItem("A1", map[string]string{"name": "Jane Doe"})
+	item.SetLocalField("seat", "A12", time.Now())
+
+	policy, _ := NewRetentionPolicy("", RetentionPurge, nil, "")
+	anonymised, changed := item.Anonymise(policy)
+
+	assert.True(t, changed)
+	assert.Equal(t, "A1", anonymised.Key)
+	assert.Empty(t, anonymised.Data)
+	assert.Empty(t, anonymised.Local)
+
+	_, changed = anonymised.Anonymise(policy)
+	assert.False(t, changed)
+}
+
+func TestScanItem_Anonymise__Given__FieldTreatments__Expect__OnlyConfiguredFieldsRewritten(t *testing.T) {
+	item, _ := NewScanItem("A1", map[string]string{"name": "Jane Doe", "email": "jane@example.com", "ticket": "VIP"})
+	item.SetLocalField("phone", "0901", time.Now())
+
+	policy, _ := NewRetentionPolicy("", RetentionAnonymise, map[string]string{"email": "hash", "name": "redact", "phone": "redact"}, "salt")
+	anonymised, changed := item.Anonymise(policy)
+
+	assert.True(t, changed)
+	assert.Equal(t, Redacted, anonymised.Data["name"])
+	assert.Equal(t, "VIP", anonymised.Data["ticket"])
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", anonymised.Data["email"])
+	assert.Equal(t, Redacted, anonymised.Local["phone"].Value)
+
+	other, _ := NewScanItem("B2", map[string]string{"email": "jane@example.com"})
+	hashed, _ := other.Anonymise(policy)
+	assert.Equal(t, anonymised.Data["email"], hashed.Data["email"], "equal values keep equal hashes")
+
+	again, changed := anonymised.Anonymise(policy)
+	assert.False(t, changed)
+	assert.Equal(t, anonymised.Data, again.Data)
+}
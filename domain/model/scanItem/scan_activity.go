@@ -1,6 +1,21 @@
 package scanItem
 
-import "time"
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Sync statuses of an activity toward its upstream source.
+// A push which failed with attempts left is retrying, it is failed once the keeper gives up.
+const (
+	SyncPending  = "pending"
+	SyncSent     = "sent"
+	SyncRetrying = "retrying"
+	SyncFailed   = "failed"
+)
+
+var activitySequence uint64
 
 type ItemActivities struct {
 	Key        string
@@ -8,9 +23,28 @@ type ItemActivities struct {
 }
 
 type ItemActivity struct {
+	Id      string
 	Action  string
 	Data    map[string]string
 	Created time.Time
+	Sync    ActivitySync
+}
+
+// ActivitySync tells whether an activity has reached the upstream source
+type ActivitySync struct {
+	Status    string
+	Attempts  int
+	LastError string
+	Updated   time.Time
+}
+
+// SyncSummary counts activities of a repository by sync status
+type SyncSummary struct {
+	Pending  int
+	Sent     int
+	Retrying int
+	Failed   int
+	Unsynced int
 }
 
 type ItemDetail struct {
@@ -19,9 +53,53 @@ type ItemDetail struct {
 }
 
 func NewActivity(action string, properties map[string]string ) ItemActivity {
+	now := time.Now()
+
 	return ItemActivity{
+		Id:      newActivityId(now),
 		Action:  action,
 		Data:    properties,
-		Created: time.Now(),
+		Created: now,
+		Sync: ActivitySync{
+			Status:  SyncPending,
+			Updated: now,
+		},
+	}
+}
+
+func newActivityId(now time.Time) string {
+	seq := atomic.AddUint64(&activitySequence, 1)
+
+	return strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.FormatUint(seq, 36)
+}
+
+// IsSynced reports whether upstream has acknowledged the activity
+func (a ItemActivity) IsSynced() bool {
+	return a.Sync.Status == SyncSent
+}
+
+// Add counts the activity into the summary, activities stored before sync tracking are ignored
+func (s *SyncSummary) Add(activity ItemActivity) {
+	switch activity.Sync.Status {
+	case SyncPending:
+		s.Pending += 1
+		s.Unsynced += 1
+	case SyncRetrying:
+		s.Retrying += 1
+		s.Unsynced += 1
+	case SyncFailed:
+		s.Failed += 1
+		s.Unsynced += 1
+	case SyncSent:
+		s.Sent += 1
 	}
-}
\ No newline at end of file
+}
+
+// WithId returns the activity with an id, activities stored before ids existed get one derived from their creation time
+func (a ItemActivity) WithId() ItemActivity {
+	if "" == a.Id {
+		a.Id = newActivityId(a.Created)
+	}
+
+	return a
+}
@@ -0,0 +1,28 @@
+package scanItem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewActivity_Expect_PendingSync_And_UniqueId(t *testing.T) {
+	activity1 := NewActivity("checkin", nil)
+	activity2 := NewActivity("checkin", nil)
+
+	assert.Exactly(t, SyncPending, activity1.Sync.Status)
+	assert.NotEmpty(t, activity1.Id)
+	assert.NotEqual(t, activity1.Id, activity2.Id)
+	assert.False(t, activity1.IsSynced())
+}
+
+func TestSyncSummary_Add_Given_MixedStatuses_Expect_CorrectCounts(t *testing.T) {
+	summary := SyncSummary{}
+
+	for _, status := range []string{SyncPending, SyncRetrying, SyncFailed, SyncSent, SyncSent, ""} {
+		activity := NewActivity("checkin", nil)
+		activity.Sync.Status = status
+		summary.Add(activity)
+	}
+
+	assert.Exactly(t, SyncSummary{Pending: 1, Sent: 2, Retrying: 1, Failed: 1, Unsynced: 3}, summary)
+}
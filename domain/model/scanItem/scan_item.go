@@ -4,6 +4,7 @@ package scanItem
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 type ScanItemInterface interface {
@@ -15,9 +16,12 @@ type ScanItemInterface interface {
 	//AddActivity(action string, properties map[string]string)
 }
 
+// ScanItem.Data is the effective view: imported fields overlaid with locally owned fields,
+// which are kept in Local until the merge policy hands them back to upstream
 type ScanItem struct {
-	Key  string
-	Data map[string]string
+	Key   string
+	Data  map[string]string
+	Local map[string]LocalField `json:",omitempty"`
 }
 
 func NewScanItem(key string, data map[string]string) (*ScanItem, error) {
@@ -77,6 +81,52 @@ func (i *ScanItem) SetFields(fields map[string]string) {
 	}
 }
 
+// Clone copies the item with its data and local fields, an edit of the copy leaves the item as it is
+func (i *ScanItem) Clone() *ScanItem {
+	clone := &ScanItem{Key: i.Key, Data: make(map[string]string, len(i.Data))}
+
+	for field, value := range i.Data {
+		clone.Data[field] = value
+	}
+
+	if nil != i.Local {
+		clone.Local = make(map[string]LocalField, len(i.Local))
+
+		for field, local := range i.Local {
+			clone.Local[field] = local
+		}
+	}
+
+	return clone
+}
+
+// SetLocalField sets a locally owned field, which survives imports according to the merge policy
+func (i *ScanItem) SetLocalField(field string, data string, updated time.Time) {
+	if nil == i.Local {
+		i.Local = make(map[string]LocalField)
+	}
+
+	i.Local[field] = LocalField{Value: data, Updated: updated}
+	i.SetField(field, data)
+}
+
+// Merge builds the item resulting of an import of upstream data
+func (i *ScanItem) Merge(upstream map[string]string, policy MergePolicy) *ScanItem {
+	merged := &ScanItem{Key: i.Key, Data: make(map[string]string, len(upstream))}
+
+	for field, value := range upstream {
+		merged.Data[field] = value
+	}
+
+	for field, local := range i.Local {
+		if policy.keepLocal(field, local, upstream) {
+			merged.SetLocalField(field, local.Value, local.Updated)
+		}
+	}
+
+	return merged
+}
+
 //
 //func (i *ScanItem) AddActivity(action string, properties map[string]string) {
 //
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestCreateTestScanItem_Expect_Return1a(t *testing.T) {
@@ -177,4 +178,16 @@ func TestScanItem_SetFields_Given_FieldIsAlreadySet_Expect_FieldDataIsOverwrite(
 
 	assert.Exactly(t, "data1", actualData1)
 	assert.Exactly(t, "data2", actualData2)
-}
\ No newline at end of file
+}
+func TestScanItem_Clone_Given_LocalEditOfClone_Expect_ItemUnchanged(t *testing.T) {
+	item, _ := NewScanItem("1", map[string]string{"seat": "A1"})
+	item.SetLocalField("ticket", "Standard", time.Time{})
+
+	clone := item.Clone()
+	clone.SetLocalField("seat", "B2", time.Time{})
+
+	assert.Equal(t, item.Key, clone.Key)
+	assert.Equal(t, map[string]string{"seat": "A1", "ticket": "Standard"}, item.Data)
+	assert.Equal(t, map[string]LocalField{"ticket": {Value: "Standard"}}, item.Local)
+	assert.Equal(t, "B2", clone.Local["seat"].Value)
+}
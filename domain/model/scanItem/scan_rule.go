@@ -0,0 +1,274 @@
+package scanItem
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Verdicts of a scan, only an allowed scan records its activity
+const (
+	VerdictAllowed     = "allowed"
+	VerdictAlreadyDone = "already_done"
+	VerdictDenied      = "denied"
+)
+
+// Rules a scan can break
+const (
+	RuleOnce     = "once"
+	RuleInterval = "interval"
+	RuleGateway  = "gateway"
+	RuleWindow   = "window"
+	RuleField    = "field"
+)
+
+// GatewayField is the scan property naming where the badge was scanned
+const GatewayField = "gateway"
+
+const clockLayout = "15:04"
+
+// Verdict tells whether a scan was recorded and why not.
+// At is the time of the earlier scan an already done verdict refers to.
+type Verdict struct {
+	Status string
+	Rule   string
+	Reason string
+	At     time.Time
+}
+
+func Allowed() Verdict {
+	return Verdict{Status: VerdictAllowed}
+}
+
+func (v Verdict) Allowed() bool {
+	return VerdictAllowed == v.Status
+}
+
+// TimeWindow is a period scans are open. A daily window repeats From and To clock times every day,
+// it spans midnight when To is before From.
+type TimeWindow struct {
+	From  time.Time
+	To    time.Time
+	Daily bool
+}
+
+// NewTimeWindow reads a window of two clock times (15:04) or of two dates, as retention dates are written
+func NewTimeWindow(from string, to string) (TimeWindow, error) {
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	fromClock, fromErr := time.ParseInLocation(clockLayout, from, time.Local)
+	toClock, toErr := time.ParseInLocation(clockLayout, to, time.Local)
+
+	if nil == fromErr && nil == toErr {
+		return TimeWindow{From: fromClock, To: toClock, Daily: true}, nil
+	}
+
+	window := TimeWindow{From: parseRetentionDate(from), To: parseRetentionDate(to)}
+
+	if window.From.IsZero() || window.To.IsZero() || !window.From.Before(window.To) {
+		return TimeWindow{}, fmt.Errorf("time window %q - %q must be two clock times or two increasing dates", from, to)
+	}
+
+	return window, nil
+}
+
+// Contains tells whether now is in the window, From included and To excluded
+func (w TimeWindow) Contains(now time.Time) bool {
+	if !w.Daily {
+		return !now.Before(w.From) && now.Before(w.To)
+	}
+
+	clock := now.Hour()*60 + now.Minute()
+	from := w.From.Hour()*60 + w.From.Minute()
+	to := w.To.Hour()*60 + w.To.Minute()
+
+	if from <= to {
+		return clock >= from && clock < to
+	}
+
+	return clock >= from || clock < to
+}
+
+// ScanRule is a set of conditions a scan must meet, zero values check nothing.
+// Require maps an item field to its accepted values, no value accepts any non empty one.
+type ScanRule struct {
+	Once        bool
+	MinInterval time.Duration
+	Gateways    []string
+	Windows     []TimeWindow
+	Require     map[string][]string
+}
+
+func NewScanRule(once bool, minInterval string, gateways []string, require map[string][]string, windows ...TimeWindow) (ScanRule, error) {
+	rule := ScanRule{Once: once, Windows: windows, Require: require}
+
+	if minInterval = strings.TrimSpace(minInterval); "" != minInterval {
+		interval, err := time.ParseDuration(minInterval)
+
+		if err != nil || interval < 0 {
+			return ScanRule{}, fmt.Errorf("minimum interval %q is not a duration", minInterval)
+		}
+
+		rule.MinInterval = interval
+	}
+
+	for _, gateway := range gateways {
+		if gateway = strings.TrimSpace(gateway); "" != gateway {
+			rule.Gateways = append(rule.Gateways, gateway)
+		}
+	}
+
+	return rule, nil
+}
+
+func (r ScanRule) empty() bool {
+	return !r.Once && 0 == r.MinInterval && 0 == len(r.Gateways) && 0 == len(r.Windows) && 0 == len(r.Require)
+}
+
+// ScanRules are the rules of a source: Source applies to every action, Activities add the rules of one action
+// and Entitlements restrict actions by ticket type. The capacity of Zones depends on every item,
+// it is judged by the keeper of their occupancy.
+type ScanRules struct {
+	Source       ScanRule
+	Activities   map[string]ScanRule
+	Entitlements EntitlementMatrix
+	Zones        []Zone
+}
+
+// Empty tells whether the rules let every scan through
+func (r ScanRules) Empty() bool {
+	if !r.Source.empty() || r.Entitlements.Enabled() || len(r.Zones) > 0 {
+		return false
+	}
+
+	for _, rule := range r.Activities {
+		if !rule.empty() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check judges a scan of item for action, activities are the activities the item already has.
+// Denials are checked before duplicates, so a scan at a wrong gateway is denied even when already done.
+func (r ScanRules) Check(item *ScanItem, activities []ItemActivity, action string, properties map[string]string, now time.Time) Verdict {
+	rules := []ScanRule{r.Source, r.Activities[action]}
+
+	for _, rule := range rules {
+		if verdict := rule.deny(item, action, properties, now); !verdict.Allowed() {
+			return verdict
+		}
+	}
+
+	if verdict := r.Entitlements.Check(item, activities, action, now); !verdict.Allowed() {
+		return verdict
+	}
+
+	for _, rule := range rules {
+		if verdict := rule.duplicate(activities, action, now); !verdict.Allowed() {
+			return verdict
+		}
+	}
+
+	return Allowed()
+}
+
+func (r ScanRule) deny(item *ScanItem, action string, properties map[string]string, now time.Time) Verdict {
+	if len(r.Gateways) > 0 && !containsFold(r.Gateways, properties[GatewayField]) {
+		return denied(RuleGateway, fmt.Sprintf("%s is not allowed at gateway %q", action, properties[GatewayField]))
+	}
+
+	if len(r.Windows) > 0 && !r.open(now) {
+		return denied(RuleWindow, fmt.Sprintf("%s is closed at %s", action, now.Format("02 Jan 15:04")))
+	}
+
+	fields := make([]string, 0, len(r.Require))
+	for field := range r.Require {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		accepted := r.Require[field]
+		value := strings.TrimSpace(item.Data[field])
+
+		if 0 == len(accepted) && "" == value {
+			return denied(RuleField, fmt.Sprintf("%s requires field %s", action, field))
+		}
+
+		if len(accepted) > 0 && !containsFold(accepted, value) {
+			return denied(RuleField, fmt.Sprintf("%s requires field %s to be %s", action, field, strings.Join(accepted, " or ")))
+		}
+	}
+
+	return Allowed()
+}
+
+func (r ScanRule) open(now time.Time) bool {
+	for _, window := range r.Windows {
+		if window.Contains(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// duplicate compares the scan with the earlier activities of the same action, which are latest first
+func (r ScanRule) duplicate(activities []ItemActivity, action string, now time.Time) Verdict {
+	var first, last *ItemActivity
+
+	for idx := range activities {
+		if activities[idx].Action != action {
+			continue
+		}
+
+		if nil == last {
+			last = &activities[idx]
+		}
+
+		first = &activities[idx]
+	}
+
+	if nil == last {
+		return Allowed()
+	}
+
+	if r.Once {
+		return Verdict{
+			Status: VerdictAlreadyDone,
+			Rule:   RuleOnce,
+			Reason: fmt.Sprintf("%s already done at %s", action, first.Created.Format("02 Jan 15:04:05")),
+			At:     first.Created,
+		}
+	}
+
+	if r.MinInterval > 0 && now.Sub(last.Created) < r.MinInterval {
+		return Verdict{
+			Status: VerdictAlreadyDone,
+			Rule:   RuleInterval,
+			Reason: fmt.Sprintf("%s already done at %s, scans must be %s apart", action, last.Created.Format("02 Jan 15:04:05"), r.MinInterval),
+			At:     last.Created,
+		}
+	}
+
+	return Allowed()
+}
+
+func denied(rule string, reason string) Verdict {
+	return Verdict{Status: VerdictDenied, Rule: rule, Reason: reason}
+}
+
+func containsFold(values []string, value string) bool {
+	value = strings.TrimSpace(value)
+
+	for _, candidate := range values {
+		if strings.EqualFold(strings.TrimSpace(candidate), value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package scanItem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestScanRules_Check__Given__Once__Expect__SecondScanAlreadyDoneAtFirstScan(t *testing.T) {
+	rules := ScanRules{Activities: map[string]ScanRule{"checkin": {Once: true}}}
+	item, _ := NewScanItem("A1", map[string]string{})
+	first := NewActivity("checkin", nil)
+	second := NewActivity("checkin", nil)
+	second.Created = first.Created.Add(time.Minute)
+
+	assert.True(t, rules.Check(item, nil, "checkin", nil, time.Now()).Allowed())
+	assert.True(t, rules.Check(item, []ItemActivity{first}, "lunch", nil, time.Now()).Allowed())
+
+	verdict := rules.Check(item, []ItemActivity{second, first}, "checkin", nil, time.Now())
+	assert.Equal(t, VerdictAlreadyDone, verdict.Status)
+	assert.Equal(t, RuleOnce, verdict.Rule)
+	assert.Equal(t, first.Created, verdict.At)
+}
+
+func TestScanRules_Check__Given__MinInterval__Expect__ScanAllowedOnceIntervalIsOver(t *testing.T) {
+	rule, err := NewScanRule(false, "10m", nil, nil)
+	assert.Nil(t, err)
+
+	rules := ScanRules{Source: rule}
+	item, _ := NewScanItem("A1", map[string]string{})
+	last := NewActivity("lunch", nil)
+
+	verdict := rules.Check(item, []ItemActivity{last}, "lunch", nil, last.Created.Add(5*time.Minute))
+	assert.Equal(t, VerdictAlreadyDone, verdict.Status)
+	assert.Equal(t, RuleInterval, verdict.Rule)
+	assert.Equal(t, last.Created, verdict.At)
+
+	assert.True(t, rules.Check(item, []ItemActivity{last}, "lunch", nil, last.Created.Add(10*time.Minute)).Allowed())
+}
+
+func TestScanRules_Check__Given__DenyingRules__Expect__DeniedWithReason(t *testing.T) {
+	morning, _ := NewTimeWindow("08:00", "12:00")
+	gates, _ := NewScanRule(true, "", []string{"North", " south "}, nil)
+	require, _ := NewScanRule(false, "", nil, map[string][]string{"paid": {"yes"}, "seat": nil})
+	open, _ := NewScanRule(false, "", nil, nil, morning)
+
+	item, _ := NewScanItem("A1", map[string]string{"paid": "YES"})
+	nine := time.Date(2019, 10, 1, 9, 0, 0, 0, time.Local)
+	done := []ItemActivity{NewActivity("checkin", nil)}
+
+	tests := []struct {
+		name  string
+		rules ScanRules
+		gate  string
+		now   time.Time
+		want  string
+	}{
+		{name: "__Given__OtherGateway", rules: ScanRules{Source: gates}, gate: "east", now: nine, want: RuleGateway},
+		{name: "__Given__MissingField", rules: ScanRules{Source: require}, gate: "north", now: nine, want: RuleField},
+		{name: "__Given__ClosedWindow", rules: ScanRules{Source: open}, gate: "north", now: nine.Add(4 * time.Hour), want: RuleWindow},
+		{name: "__Given__AllowedGatewayAlreadyDone", rules: ScanRules{Source: gates}, gate: "SOUTH", now: nine, want: RuleOnce},
+		{name: "__Given__OpenWindow", rules: ScanRules{Source: open}, gate: "north", now: nine, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := tt.rules.Check(item, done, "checkin", map[string]string{GatewayField: tt.gate}, tt.now)
+
+			assert.Equal(t, tt.want, verdict.Rule)
+			assert.Equal(t, "" == tt.want, verdict.Allowed())
+
+			if RuleGateway == tt.want || RuleField == tt.want || RuleWindow == tt.want {
+				assert.Equal(t, VerdictDenied, verdict.Status)
+				assert.NotEmpty(t, verdict.Reason)
+			}
+		})
+	}
+}
+
+func TestNewTimeWindow__Given__ClockTimesOverMidnight__Expect__DailyWindowSpanningMidnight(t *testing.T) {
+	night, err := NewTimeWindow("22:00", "02:00")
+	assert.Nil(t, err)
+	assert.True(t, night.Daily)
+
+	assert.True(t, night.Contains(time.Date(2019, 10, 1, 23, 30, 0, 0, time.Local)))
+	assert.True(t, night.Contains(time.Date(2019, 10, 2, 1, 59, 0, 0, time.Local)))
+	assert.False(t, night.Contains(time.Date(2019, 10, 2, 2, 0, 0, 0, time.Local)))
+
+	day, err := NewTimeWindow("2019-10-01", "2019-10-02")
+	assert.Nil(t, err)
+	assert.False(t, day.Daily)
+	assert.True(t, day.Contains(time.Date(2019, 10, 1, 23, 30, 0, 0, time.Local)))
+	assert.False(t, day.Contains(time.Date(2019, 10, 2, 0, 0, 0, 0, time.Local)))
+
+	_, err = NewTimeWindow("2019-10-02", "2019-10-01")
+	assert.NotNil(t, err)
+	_, err = NewScanRule(false, "often", nil, nil)
+	assert.NotNil(t, err)
+}
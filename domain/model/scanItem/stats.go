@@ -0,0 +1,94 @@
+package scanItem
+
+import "context"
+
+// DefaultStatsGroup is the field stats are grouped by when none is asked
+const DefaultStatsGroup = "gateway"
+
+// Stats summarises a repository.
+// Actions counts the items with at least one activity of each action, Groups does the same for each value
+// of the GroupBy field, read from the data of the activity then from the data of its item.
+type Stats struct {
+	Items   int
+	Actions map[string]int
+	GroupBy string
+	Groups  map[string]map[string]int
+	Sync    SyncSummary
+}
+
+// StatsRepository is implemented by repositories which compute their stats without reading items one by one
+type StatsRepository interface {
+	Stats(ctx context.Context, groupBy string) (Stats, error)
+}
+
+func NewStats(groupBy string) Stats {
+	return Stats{
+		Actions: map[string]int{},
+		GroupBy: groupBy,
+		Groups:  map[string]map[string]int{},
+	}
+}
+
+// Add counts an item and its activities, an item is counted once per action and group whatever its number of scans
+func (s *Stats) Add(item *ScanItem, activities []ItemActivity) {
+	s.Items += 1
+
+	actions := map[string]bool{}
+	groups := map[string]bool{}
+
+	for _, activity := range activities {
+		s.Sync.Add(activity)
+
+		if !actions[activity.Action] {
+			actions[activity.Action] = true
+			s.Actions[activity.Action] += 1
+		}
+
+		if "" == s.GroupBy {
+			continue
+		}
+
+		group := activity.Data[s.GroupBy]
+		if "" == group && nil != item {
+			group = item.Data[s.GroupBy]
+		}
+
+		if "" == group || groups[group+"\x00"+activity.Action] {
+			continue
+		}
+
+		groups[group+"\x00"+activity.Action] = true
+
+		if nil == s.Groups[group] {
+			s.Groups[group] = map[string]int{}
+		}
+
+		s.Groups[group][activity.Action] += 1
+	}
+}
+
+// CollectStats computes the stats of repo from its items, for adapters which have no faster way
+func CollectStats(ctx context.Context, repo RepositoryInterfaceV2, groupBy string) (Stats, error) {
+	stats := NewStats(groupBy)
+	items, err := repo.Items(ctx)
+
+	if err != nil {
+		return stats, err
+	}
+
+	for _, item := range items {
+		activities, err := repo.GetItemActivities(ctx, item.Key)
+
+		if err != nil {
+			return stats, err
+		}
+
+		if nil == activities {
+			stats.Add(item, nil)
+		} else {
+			stats.Add(item, activities.Activities)
+		}
+	}
+
+	return stats, nil
+}
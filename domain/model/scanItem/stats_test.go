@@ -0,0 +1,35 @@
+package scanItem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStats_Add__Given__RepeatedScans__Expect__ItemCountedOncePerActionAndGroup(t *testing.T) {
+	stats := NewStats("gateway")
+	item, _ := NewScanItem("A1", map[string]string{"gateway": "north"})
+
+	stats.Add(item, []ItemActivity{
+		NewActivity("checkin", nil),
+		NewActivity("checkin", map[string]string{"gateway": "south"}),
+		NewActivity("checkin", nil),
+	})
+
+	other, _ := NewScanItem("B2", map[string]string{})
+	stats.Add(other, nil)
+
+	assert.Equal(t, 2, stats.Items)
+	assert.Equal(t, map[string]int{"checkin": 1}, stats.Actions)
+	assert.Equal(t, map[string]map[string]int{"north": {"checkin": 1}, "south": {"checkin": 1}}, stats.Groups)
+	assert.Equal(t, 3, stats.Sync.Unsynced)
+}
+
+func TestStats_Add__Given__NoGroupField__Expect__NoGroups(t *testing.T) {
+	stats := NewStats("")
+	item, _ := NewScanItem("A1", map[string]string{"gateway": "north"})
+
+	stats.Add(item, []ItemActivity{NewActivity("checkin", nil)})
+
+	assert.Equal(t, map[string]int{"checkin": 1}, stats.Actions)
+	assert.Empty(t, stats.Groups)
+}
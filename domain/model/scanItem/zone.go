@@ -0,0 +1,175 @@
+package scanItem
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// VerdictFull refuses a scan entering a zone at capacity
+const VerdictFull = "full"
+
+// Rules of a zone a scan can break
+const (
+	// RuleCapacity is broken by a scan entering a zone at capacity
+	RuleCapacity = "capacity"
+	// RulePassback is broken by a second entry without exit into an anti-passback zone
+	RulePassback = "passback"
+)
+
+// Zone is a place items enter and leave by scans of its Entry and Exit actions.
+// Capacity bounds how many items are inside at once, 0 is unbounded.
+// A zone without exit action keeps every item which entered it, it caps its entry actions and tracks no presence.
+type Zone struct {
+	Name         string
+	Capacity     int
+	Entry        []string
+	Exit         []string
+	AntiPassback bool
+}
+
+// Presence tells whether an item is inside a zone and since when.
+// Stay is how long the current visit lasts, Dwell sums every visit including the current one.
+type Presence struct {
+	Zone   string
+	Inside bool
+	Since  time.Time
+	Stay   time.Duration
+	Dwell  time.Duration
+	Visits int
+}
+
+// ZoneOccupancy is how many items are inside a zone and how many more it admits
+type ZoneOccupancy struct {
+	Zone      string
+	Capacity  int
+	Occupancy int
+	Remaining int
+	Unlimited bool
+}
+
+func NewZone(name string, capacity int, entry []string, exit []string, antiPassback bool) (Zone, error) {
+	zone := Zone{Name: strings.TrimSpace(name), Capacity: capacity, Entry: entry, Exit: exit, AntiPassback: antiPassback}
+
+	if "" == zone.Name {
+		return Zone{}, errors.New("zone must have a name")
+	}
+
+	if capacity < 0 {
+		return Zone{}, fmt.Errorf("capacity of zone %s must not be negative", zone.Name)
+	}
+
+	if 0 == len(entry) {
+		return Zone{}, fmt.Errorf("zone %s needs an entry activity", zone.Name)
+	}
+
+	for _, action := range exit {
+		if zone.Enters(action) {
+			return Zone{}, fmt.Errorf("%s can not be both the entry and the exit of zone %s", action, zone.Name)
+		}
+	}
+
+	return zone, nil
+}
+
+func (z Zone) Enters(action string) bool {
+	return contains(z.Entry, action)
+}
+
+func (z Zone) Leaves(action string) bool {
+	return contains(z.Exit, action)
+}
+
+// TracksPresence tells whether items can leave the zone
+func (z Zone) TracksPresence() bool {
+	return len(z.Exit) > 0
+}
+
+// Inside tells whether the latest entry or exit of the zone among activities, which are latest first, is an entry.
+// since is when the visit started: entries repeated without exit continue it.
+func (z Zone) Inside(activities []ItemActivity) (inside bool, since time.Time) {
+	for _, activity := range activities {
+		if z.Leaves(activity.Action) {
+			break
+		}
+
+		if z.Enters(activity.Action) {
+			inside, since = true, activity.Created
+		}
+	}
+
+	return inside, since
+}
+
+// Presence pairs the entries and exits of the zone among activities, which are latest first.
+// An exit without entry is ignored.
+func (z Zone) Presence(activities []ItemActivity, now time.Time) Presence {
+	presence := Presence{Zone: z.Name}
+
+	for idx := len(activities) - 1; idx >= 0; idx-- {
+		activity := activities[idx]
+
+		if z.Enters(activity.Action) && !presence.Inside {
+			presence.Inside, presence.Since = true, activity.Created
+			presence.Visits += 1
+		} else if z.Leaves(activity.Action) && presence.Inside {
+			presence.Dwell += activity.Created.Sub(presence.Since)
+			presence.Inside, presence.Since = false, time.Time{}
+		}
+	}
+
+	if presence.Inside {
+		presence.Stay = now.Sub(presence.Since)
+		presence.Dwell += presence.Stay
+	}
+
+	return presence
+}
+
+// Reenter judges an entry of an item inside the zone since since, anti-passback refuses it
+func (z Zone) Reenter(since time.Time) Verdict {
+	if !z.AntiPassback {
+		return Allowed()
+	}
+
+	return Verdict{
+		Status: VerdictAlreadyDone,
+		Rule:   RulePassback,
+		Reason: fmt.Sprintf("already inside %s since %s, exit first", z.Name, since.Format("02 Jan 15:04:05")),
+		At:     since,
+	}
+}
+
+// Admit judges an item entering the zone while occupancy items are inside
+func (z Zone) Admit(occupancy int) Verdict {
+	if z.Capacity > 0 && occupancy >= z.Capacity {
+		return Verdict{
+			Status: VerdictFull,
+			Rule:   RuleCapacity,
+			Reason: fmt.Sprintf("%s is full, %d of %d inside", z.Name, occupancy, z.Capacity),
+		}
+	}
+
+	return Allowed()
+}
+
+func (z Zone) Occupancy(occupancy int) ZoneOccupancy {
+	result := ZoneOccupancy{Zone: z.Name, Capacity: z.Capacity, Occupancy: occupancy, Unlimited: 0 == z.Capacity}
+
+	if !result.Unlimited && occupancy < z.Capacity {
+		result.Remaining = z.Capacity - occupancy
+	}
+
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
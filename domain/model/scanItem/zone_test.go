@@ -0,0 +1,104 @@
+package scanItem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestZone_Inside__Given__LatestEntryOrExit__Expect__PresenceOfTheLatest(t *testing.T) {
+	zone, err := NewZone("workshop", 2, []string{"workshop-in"}, []string{"workshop-out"}, false)
+	assert.Nil(t, err)
+
+	entry := NewActivity("workshop-in", nil)
+	exit := NewActivity("workshop-out", nil)
+	lunch := NewActivity("lunch", nil)
+
+	inside, since := zone.Inside([]ItemActivity{lunch, entry, exit})
+	assert.True(t, inside)
+	assert.Equal(t, entry.Created, since)
+
+	inside, _ = zone.Inside([]ItemActivity{exit, entry})
+	assert.False(t, inside)
+
+	inside, _ = zone.Inside([]ItemActivity{lunch})
+	assert.False(t, inside)
+}
+
+func TestZone_Admit__Given__Capacity__Expect__FullOnceReached(t *testing.T) {
+	zone, _ := NewZone("workshop", 2, []string{"workshop-in"}, nil, false)
+
+	assert.True(t, zone.Admit(1).Allowed())
+
+	verdict := zone.Admit(2)
+	assert.Equal(t, VerdictFull, verdict.Status)
+	assert.Equal(t, RuleCapacity, verdict.Rule)
+
+	assert.Equal(t, ZoneOccupancy{Zone: "workshop", Capacity: 2, Occupancy: 1, Remaining: 1}, zone.Occupancy(1))
+
+	unbounded, _ := NewZone("hall", 0, []string{"checkin"}, nil, false)
+	assert.True(t, unbounded.Admit(1000).Allowed())
+	assert.True(t, unbounded.Occupancy(1000).Unlimited)
+}
+
+func TestNewZone__Given__InvalidSettings__Expect__Error(t *testing.T) {
+	_, err := NewZone("workshop", -1, []string{"in"}, nil, false)
+	assert.NotNil(t, err)
+
+	_, err = NewZone("workshop", 80, nil, []string{"out"}, false)
+	assert.NotNil(t, err)
+
+	_, err = NewZone("workshop", 80, []string{"door"}, []string{"door"}, false)
+	assert.NotNil(t, err)
+}
+
+func TestZone_Presence__Given__PairedEntriesAndExits__Expect__DwellOfEveryVisit(t *testing.T) {
+	zone, _ := NewZone("workshop", 0, []string{"workshop-in"}, []string{"workshop-out"}, false)
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.Local)
+	at := func(action string, minutes int) ItemActivity {
+		return ItemActivity{Action: action, Created: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+
+	// latest first: in 9:00, in again 9:10, out 9:30, stray out 9:40, in 10:00
+	activities := []ItemActivity{at("workshop-in", 60), at("workshop-out", 40), at("workshop-out", 30), at("workshop-in", 10), at("workshop-in", 0)}
+
+	presence := zone.Presence(activities, start.Add(75*time.Minute))
+	assert.Equal(t, Presence{
+		Zone:   "workshop",
+		Inside: true,
+		Since:  start.Add(60 * time.Minute),
+		Stay:   15 * time.Minute,
+		Dwell:  45 * time.Minute,
+		Visits: 2,
+	}, presence)
+
+	inside, since := zone.Inside(activities[1:])
+	assert.False(t, inside)
+	assert.True(t, since.IsZero())
+
+	inside, since = zone.Inside(activities[3:])
+	assert.True(t, inside)
+	assert.Equal(t, start, since)
+
+	presence = zone.Presence(activities[1:], start.Add(75*time.Minute))
+	assert.False(t, presence.Inside)
+	assert.Equal(t, time.Duration(0), presence.Stay)
+	assert.Equal(t, 30*time.Minute, presence.Dwell)
+}
+
+func TestZone_Reenter__Given__AntiPassback__Expect__AlreadyDone(t *testing.T) {
+	since := time.Date(2024, 5, 6, 9, 0, 0, 0, time.Local)
+
+	open, _ := NewZone("workshop", 0, []string{"workshop-in"}, []string{"workshop-out"}, false)
+	assert.True(t, open.Reenter(since).Allowed())
+
+	guarded, _ := NewZone("workshop", 0, []string{"workshop-in"}, []string{"workshop-out"}, true)
+	verdict := guarded.Reenter(since)
+	assert.Equal(t, VerdictAlreadyDone, verdict.Status)
+	assert.Equal(t, RulePassback, verdict.Rule)
+	assert.Equal(t, since, verdict.At)
+	assert.True(t, guarded.TracksPresence())
+
+	keynote, _ := NewZone("keynote", 0, []string{"keynote"}, nil, true)
+	assert.False(t, keynote.TracksPresence())
+}
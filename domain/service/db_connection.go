@@ -1,7 +1,10 @@
 package service
 
-import "git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+)
 
 type DbConnectionInterface interface {
-	InitRepository(name string) (scanItem.RepositoryInterface, error)
+	OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error)
 }
@@ -1,45 +1,202 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"go.uber.org/zap"
+	"sync"
 )
 
+var ErrRemovedWhileOpening = errors.New("repository was removed while it was opening")
+
 type RepositoryRegistryInterface interface {
+	// GetRepository returns the repository adapted for callers of RepositoryInterface
 	GetRepository(name string) (scanItem.RepositoryInterface, error)
-	initRepository(name string) (scanItem.RepositoryInterface, error)
+	GetRepositoryV2(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error)
+	SetConnection(name string, db DbConnectionInterface)
+	RemoveRepository(name string) bool
+	initRepository(ctx context.Context, name string) (*openRepository, error)
 	Shutdown()
 }
 
+// openRepository keeps the legacy adapter of a repository, callers of GetRepository all get the same one
+type openRepository struct {
+	repo   scanItem.RepositoryInterfaceV2
+	legacy scanItem.RepositoryInterface
+}
+
+// pendingOpen is a repository being opened, concurrent callers wait for done instead of opening it again
+type pendingOpen struct {
+	done chan struct{}
+	open *openRepository
+	err  error
+}
+
+// repoRegistry is safe for concurrent use, a repository is opened once even when requested concurrently.
+// Opening happens outside the lock so a slow storage does not hold up the repositories already open.
 type repoRegistry struct {
-	repositories map[string]scanItem.RepositoryInterface
+	mu           sync.RWMutex
+	repositories map[string]*openRepository
+	opening      map[string]*pendingOpen
+	connections  map[string]DbConnectionInterface
 	db           DbConnectionInterface
+	logger       *zap.Logger
 }
 
 func NewRepositoryRegistry(db DbConnectionInterface) *repoRegistry {
 	return &repoRegistry{
-		repositories: make(map[string]scanItem.RepositoryInterface),
+		repositories: make(map[string]*openRepository),
+		opening:      make(map[string]*pendingOpen),
+		connections:  make(map[string]DbConnectionInterface),
 		db:           db,
+		logger:       zap.NewNop(),
 	}
 }
 
+// SetLogger sets where the storage errors no caller gets back are reported, by default nowhere
+func (m *repoRegistry) SetLogger(logger *zap.Logger) *repoRegistry {
+	m.logger = logger
+
+	return m
+}
+
+// reportStorageError logs an error of a repository its caller could not be returned
+func (m *repoRegistry) reportStorageError(repoName string, op string, err error) {
+	m.logger.Error("registry: storage error", zap.String("dbName", repoName), zap.String("op", op), zap.Error(err))
+}
+
 func (m *repoRegistry) GetRepository(name string) (scanItem.RepositoryInterface, error) {
-	if r, ok := m.repositories[name]; ok {
-		return r, nil
+	open, err := m.getOpen(context.Background(), name)
+
+	if err != nil {
+		return nil, err
 	}
 
-	return m.initRepository(name)
+	return open.legacy, nil
+}
+
+func (m *repoRegistry) GetRepositoryV2(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	open, err := m.getOpen(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return open.repo, nil
+}
+
+func (m *repoRegistry) getOpen(ctx context.Context, name string) (*openRepository, error) {
+	m.mu.RLock()
+	open, ok := m.repositories[name]
+	m.mu.RUnlock()
+
+	if ok {
+		return open, nil
+	}
+
+	return m.initRepository(ctx, name)
+}
+
+// SetConnection stores the named repository in another storage than the default one
+func (m *repoRegistry) SetConnection(name string, db DbConnectionInterface) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.connections[name] = db
+}
+
+// RemoveRepository closes the named repository and forgets its storage, false when it was not open
+func (m *repoRegistry) RemoveRepository(name string) bool {
+	m.mu.Lock()
+	open, found := m.repositories[name]
+	delete(m.repositories, name)
+	delete(m.opening, name)
+	delete(m.connections, name)
+	m.mu.Unlock()
+
+	if found {
+		m.closeRepository(open.repo)
+	}
+
+	return found
 }
 
 func (m *repoRegistry) Shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for repoName, _ := range m.repositories {
-		m.repositories[repoName].CloseDb()
+		m.closeRepository(m.repositories[repoName].repo)
+	}
+
+	m.repositories = make(map[string]*openRepository)
+	m.opening = make(map[string]*pendingOpen)
+}
+
+func (m *repoRegistry) closeRepository(repo scanItem.RepositoryInterfaceV2) {
+	if err := repo.CloseDb(context.Background()); err != nil {
+		m.reportStorageError(repo.GetRepoName(), "close", err)
 	}
 }
 
-func (m *repoRegistry) initRepository(name string) (scanItem.RepositoryInterface, error) {
-	var err error
+// initRepository opens a repository in its own storage when one was set, a repository which fails to open is not kept.
+// A repository removed while it was opening is closed again and ErrRemovedWhileOpening is returned.
+func (m *repoRegistry) initRepository(ctx context.Context, name string) (*openRepository, error) {
+	m.mu.Lock()
+
+	// opened by another caller while this one was waiting for the lock
+	if open, found := m.repositories[name]; found {
+		m.mu.Unlock()
+		return open, nil
+	}
+
+	if pending, found := m.opening[name]; found {
+		m.mu.Unlock()
+		return pending.wait(ctx)
+	}
+
+	db, found := m.connections[name]
+
+	if !found {
+		db = m.db
+	}
+
+	pending := &pendingOpen{done: make(chan struct{})}
+	m.opening[name] = pending
+	m.mu.Unlock()
+
+	repo, err := db.OpenRepository(ctx, name)
+
+	m.mu.Lock()
+	current := m.opening[name]
 
-	m.repositories[name], err = m.db.InitRepository(name)
+	if current == pending {
+		delete(m.opening, name)
+	}
+
+	if nil == err && current != pending {
+		err = ErrRemovedWhileOpening
+		defer m.closeRepository(repo)
+	}
 
-	return m.repositories[name], err
+	if nil == err {
+		pending.open = &openRepository{repo: repo, legacy: scanItem.LegacyReporting(repo, m.reportStorageError)}
+		m.repositories[name] = pending.open
+	}
+
+	pending.err = err
+	close(pending.done)
+	m.mu.Unlock()
+
+	return pending.open, pending.err
+}
+
+func (p *pendingOpen) wait(ctx context.Context) (*openRepository, error) {
+	select {
+	case <-p.done:
+		return p.open, p.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
@@ -1,12 +1,27 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/boltDb"
 	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/sqliteDb"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
 	"testing"
 )
 
+func testFolder() string {
+	folder, _ := ioutil.TempDir("", "registry")
+	return folder
+}
+
 func TestRepositoryManager_GetDB__Given__Repo_NotExist__Expect__Return_RepositoryObject(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -40,7 +55,7 @@ func TestRepositoryManager_GetDB__Given__Repo_NotExist__Expect__Return_Repositor
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			sut := NewRepositoryRegistry(memDb.NewMemDbConnection())
+			sut := NewRepositoryRegistry(memDb.NewMemDbConnection(testFolder()))
 			got, err := sut.GetRepository(tt.givenRepoName)
 
 			assert.Exactly(t, tt.wantErr, err)
@@ -82,7 +97,7 @@ func TestRepositoryManager_GetDB__Given__Repo_Exist__Expect__Return_TheSameRepos
 	}
 
 	// ARRANGE
-	sut := NewRepositoryRegistry(memDb.NewMemDbConnection())
+	sut := NewRepositoryRegistry(memDb.NewMemDbConnection(testFolder()))
 
 	// call GetRepository the first time => repo object will be created
 	for i, tt := range tests {
@@ -101,4 +116,233 @@ func TestRepositoryManager_GetDB__Given__Repo_Exist__Expect__Return_TheSameRepos
 		assert.Exactly(t, tt.wantObject, got)
 		assert.Exactly(t, tt.wantRepoName, got.GetRepoName())
 	}
-}
\ No newline at end of file
+}
+
+// recordingConnection remembers which repositories were opened through it
+type recordingConnection struct {
+	DbConnectionInterface
+	mu     sync.Mutex
+	opened []string
+}
+
+func (c *recordingConnection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	c.mu.Lock()
+	c.opened = append(c.opened, name)
+	c.mu.Unlock()
+
+	return c.DbConnectionInterface.OpenRepository(ctx, name)
+}
+
+// slowConnection holds every open until release is closed
+type slowConnection struct {
+	DbConnectionInterface
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *slowConnection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	close(c.started)
+	<-c.release
+
+	return c.DbConnectionInterface.OpenRepository(ctx, name)
+}
+
+func TestRepositoryManager_GetDB__Given__Repo_WithOwnConnection__Expect__Opened_InThatConnection(t *testing.T) {
+	own := &recordingConnection{DbConnectionInterface: memDb.NewMemDbConnection(testFolder())}
+	shared := &recordingConnection{DbConnectionInterface: memDb.NewMemDbConnection(testFolder())}
+
+	sut := NewRepositoryRegistry(shared)
+	sut.SetConnection("vip", own)
+
+	got, err := sut.GetRepository("vip")
+	_, _ = sut.GetRepository("guests")
+
+	assert.Nil(t, err)
+	assert.Exactly(t, "vip", got.GetRepoName())
+	assert.Exactly(t, []string{"vip"}, own.opened)
+	assert.Exactly(t, []string{"guests"}, shared.opened)
+}
+
+func TestRepositoryManager_GetDB__Given__ConcurrentCalls__Expect__Opened_Once(t *testing.T) {
+	connection := &recordingConnection{DbConnectionInterface: memDb.NewMemDbConnection(testFolder())}
+	sut := NewRepositoryRegistry(connection)
+
+	var wg sync.WaitGroup
+	got := make([]scanItem.RepositoryInterface, 20)
+
+	for idx := range got {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			got[idx], _ = sut.GetRepository("guests")
+		}(idx)
+	}
+
+	wg.Wait()
+
+	assert.Exactly(t, []string{"guests"}, connection.opened)
+	for _, repo := range got {
+		assert.Exactly(t, got[0], repo)
+	}
+}
+
+func TestRepositoryManager_RemoveRepository__Expect__Reopened_OnNextGet(t *testing.T) {
+	connection := &recordingConnection{DbConnectionInterface: memDb.NewMemDbConnection(testFolder())}
+	sut := NewRepositoryRegistry(connection)
+
+	first, _ := sut.GetRepository("guests")
+
+	assert.True(t, sut.RemoveRepository("guests"))
+	assert.False(t, sut.RemoveRepository("guests"))
+
+	second, err := sut.GetRepository("guests")
+
+	assert.Nil(t, err)
+	assert.NotSame(t, first, second)
+	assert.Exactly(t, []string{"guests", "guests"}, connection.opened)
+}
+
+// bow runs in its own specs, badger trips checkptr under -race
+func TestDbConnection_OpenRepository__Given__ConcurrentNames__Expect__Opened_OnEveryAdapter(t *testing.T) {
+	adapters := map[string]func(folder string) (DbConnectionInterface, error){
+		"mem": func(folder string) (DbConnectionInterface, error) { return memDb.NewMemDbConnection(folder), nil },
+		"bolt": func(folder string) (DbConnectionInterface, error) {
+			return boltDb.NewBoltDbConnection(folder)
+		},
+		"sqlite": func(folder string) (DbConnectionInterface, error) {
+			return sqliteDb.NewSqliteDbConnection(folder)
+		},
+	}
+
+	for adapter, open := range adapters {
+		t.Run(adapter, func(t *testing.T) {
+			ctx := context.Background()
+			folder := testFolder()
+			defer func() { _ = os.RemoveAll(folder) }()
+
+			connection, err := open(folder)
+			assert.Nil(t, err)
+
+			// the registry opens the repositories of different sources outside its lock
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			repos := make([]scanItem.RepositoryInterfaceV2, 32)
+			errs := make([]error, len(repos))
+
+			for idx := range repos {
+				wg.Add(1)
+				go func(idx int) {
+					defer wg.Done()
+					<-start
+					repos[idx], errs[idx] = connection.OpenRepository(ctx, "source"+strconv.Itoa(idx))
+				}(idx)
+			}
+
+			close(start)
+			wg.Wait()
+
+			for idx, err := range errs {
+				assert.Nil(t, err)
+
+				if nil == err {
+					assert.Nil(t, repos[idx].CloseDb(ctx))
+				}
+			}
+		})
+	}
+}
+
+func TestRepositoryManager_GetDB__Given__SlowOpen__Expect__OpenRepositories_NotBlocked(t *testing.T) {
+	slow := &slowConnection{
+		DbConnectionInterface: memDb.NewMemDbConnection(testFolder()),
+		started:               make(chan struct{}),
+		release:               make(chan struct{}),
+	}
+
+	sut := NewRepositoryRegistry(memDb.NewMemDbConnection(testFolder()))
+	sut.SetConnection("vip", slow)
+	guests, _ := sut.GetRepository("guests")
+
+	opened := make(chan scanItem.RepositoryInterface)
+	go func() {
+		repo, _ := sut.GetRepository("vip")
+		opened <- repo
+	}()
+
+	<-slow.started
+
+	got, err := sut.GetRepository("guests")
+
+	assert.Nil(t, err)
+	assert.Exactly(t, guests, got)
+
+	close(slow.release)
+	assert.Exactly(t, "vip", (<-opened).GetRepoName())
+}
+
+func TestRepositoryManager_RemoveRepository__Given__OpenInFlight__Expect__NotKept(t *testing.T) {
+	slow := &slowConnection{
+		DbConnectionInterface: memDb.NewMemDbConnection(testFolder()),
+		started:               make(chan struct{}),
+		release:               make(chan struct{}),
+	}
+
+	sut := NewRepositoryRegistry(slow)
+
+	result := make(chan error)
+	go func() {
+		_, err := sut.GetRepository("guests")
+		result <- err
+	}()
+
+	<-slow.started
+	sut.RemoveRepository("guests")
+	close(slow.release)
+
+	assert.Exactly(t, ErrRemovedWhileOpening, <-result)
+
+	sut.mu.RLock()
+	_, found := sut.repositories["guests"]
+	sut.mu.RUnlock()
+
+	assert.False(t, found)
+}
+
+// unclosableConnection opens repositories which fail to close
+type unclosableConnection struct {
+	DbConnectionInterface
+}
+
+type unclosableRepository struct {
+	scanItem.RepositoryInterfaceV2
+}
+
+func (r *unclosableRepository) CloseDb(ctx context.Context) error {
+	return errors.New("disk gone")
+}
+
+func (c *unclosableConnection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	repo, err := c.DbConnectionInterface.OpenRepository(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &unclosableRepository{RepositoryInterfaceV2: repo}, nil
+}
+
+func TestRepositoryManager_RemoveRepository__Given__CloseFails__Expect__Logged(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	sut := NewRepositoryRegistry(&unclosableConnection{DbConnectionInterface: memDb.NewMemDbConnection(testFolder())}).
+		SetLogger(zap.New(core))
+
+	_, _ = sut.GetRepository("guests")
+	sut.RemoveRepository("guests")
+
+	entries := logs.All()
+
+	assert.Len(t, entries, 1)
+	assert.Exactly(t, "guests", entries[0].ContextMap()["dbName"])
+	assert.Exactly(t, "close", entries[0].ContextMap()["op"])
+	assert.Exactly(t, "disk gone", entries[0].ContextMap()["error"])
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StorageDriver opens connections of one storage adapter.
+// NewOptions returns a pointer to the adapter's own options struct, which is filled from the storage settings
+// before it is handed to Open.
+// Rekey, when the adapter encrypts at rest, seals the closed store of options with key, a nil key decrypts it.
+type StorageDriver struct {
+	Name       string
+	NewOptions func() interface{}
+	Open       func(options interface{}) (DbConnectionInterface, error)
+	Rekey      func(options interface{}, key []byte) error
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]StorageDriver)
+)
+
+// RegisterStorageDriver makes a storage adapter available by name, registering a name twice panics
+func RegisterStorageDriver(driver StorageDriver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if "" == driver.Name || nil == driver.NewOptions || nil == driver.Open {
+		panic("storage driver must have a name, options and an opener")
+	}
+
+	if _, exist := drivers[driver.Name]; exist {
+		panic("storage driver " + driver.Name + " registered twice")
+	}
+
+	drivers[driver.Name] = driver
+}
+
+// StorageDrivers lists the registered adapter names
+func StorageDrivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func GetStorageDriver(name string) (StorageDriver, error) {
+	driversMu.RLock()
+	driver, found := drivers[name]
+	driversMu.RUnlock()
+
+	if !found {
+		return StorageDriver{}, fmt.Errorf("unknown storage adapter %q, available adapters: %s", name, strings.Join(StorageDrivers(), ", "))
+	}
+
+	return driver, nil
+}
+
+// DecodeStorageOptions validates settings against the adapter's options struct,
+// unknown adapters and unknown option names are errors
+func DecodeStorageOptions(adapter string, settings map[string]interface{}) (interface{}, error) {
+	driver, err := GetStorageDriver(adapter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	options := driver.NewOptions()
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		Result:           options,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decoder.Decode(settings); err != nil {
+		return nil, fmt.Errorf("storage adapter %q: %s", adapter, err.Error())
+	}
+
+	return options, nil
+}
+
+// OpenStorage opens a connection of the named adapter
+func OpenStorage(adapter string, settings map[string]interface{}) (DbConnectionInterface, error) {
+	options, err := DecodeStorageOptions(adapter, settings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	driver, _ := GetStorageDriver(adapter)
+
+	return driver.Open(options)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type fakeStorageOptions struct {
+	Folder   string
+	Interval time.Duration
+}
+
+func init() {
+	RegisterStorageDriver(StorageDriver{
+		Name:       "fake",
+		NewOptions: func() interface{} { return &fakeStorageOptions{} },
+		Open: func(options interface{}) (DbConnectionInterface, error) {
+			return nil, nil
+		},
+	})
+}
+
+func TestDecodeStorageOptions__Given__KnownAdapter__Expect__TypedOptions(t *testing.T) {
+	got, err := DecodeStorageOptions("fake", map[string]interface{}{"folder": "./data", "interval": "2m"})
+
+	assert.Nil(t, err)
+	assert.Exactly(t, &fakeStorageOptions{Folder: "./data", Interval: 2 * time.Minute}, got)
+}
+
+func TestDecodeStorageOptions__Given__UnknownAdapter__Expect__ErrorListingAdapters(t *testing.T) {
+	_, err := DecodeStorageOptions("bwo", nil)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unknown storage adapter "bwo"`)
+		assert.Contains(t, err.Error(), "fake")
+	}
+}
+
+func TestDecodeStorageOptions__Given__UnknownOption__Expect__Error(t *testing.T) {
+	_, err := DecodeStorageOptions("fake", map[string]interface{}{"foldr": "./data"})
+
+	assert.Error(t, err)
+}
+
+func TestRegisterStorageDriver__Given__NameRegisteredTwice__Expect__Panic(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterStorageDriver(StorageDriver{
+			Name:       "fake",
+			NewOptions: func() interface{} { return &fakeStorageOptions{} },
+			Open:       func(options interface{}) (DbConnectionInterface, error) { return nil, nil },
+		})
+	})
+}
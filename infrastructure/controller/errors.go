@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"errors"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// errorStatus is the status answering err: 503 when the storage failed or timed out, so scanners retry,
+// 404 for an unknown source or zone, 422 for a scan no session of the agenda resolves and fallback for any other error
+func errorStatus(err error, fallback int) int {
+	switch {
+	case scanItem.IsStorageError(err):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, sourceKeeper.ErrUnknownSource), errors.Is(err, sourceKeeper.ErrUnknownZone):
+		return http.StatusNotFound
+	case errors.Is(err, sourceKeeper.ErrNoSession):
+		return http.StatusUnprocessableEntity
+	}
+
+	return fallback
+}
+
+// respondError answers err as JSON, its message is the reason
+func respondError(c *gin.Context, err error, fallback int) {
+	c.JSON(errorStatus(err, fallback), gin.H{"error": err.Error()})
+}
+
+// respondErrorHTML answers err with the page of a scan which could not be checked
+func respondErrorHTML(c *gin.Context, err error, itemKey string) {
+	c.HTML(errorStatus(err, http.StatusInternalServerError), "not_found.tmpl", gin.H{
+		"key":   itemKey,
+		"error": err.Error(),
+	})
+}
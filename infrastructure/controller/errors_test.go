@@ -0,0 +1,93 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/controller"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+)
+
+// fullDiskConnection opens repositories which can be read but refuse to store activities
+type fullDiskConnection struct {
+	service.DbConnectionInterface
+}
+
+func (c fullDiskConnection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	repo, err := c.DbConnectionInterface.OpenRepository(ctx, name)
+
+	return fullDiskRepository{repo}, err
+}
+
+type fullDiskRepository struct {
+	scanItem.RepositoryInterfaceV2
+}
+
+func (r fullDiskRepository) AddItemActivity(ctx context.Context, itemKey string, activity scanItem.ItemActivity) (*scanItem.ItemActivities, error) {
+	return nil, scanItem.NewStorageError(r.GetRepoName(), "add item activity", errors.New("no space left on device"))
+}
+
+var _ = Describe("Storage errors\n", func() {
+	var folder string
+	var router *gin.Engine
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "controller")
+
+		connection := memDb.NewMemDbConnection(folder)
+		stored, _ := connection.InitRepository("guests")
+		_, _ = stored.NewItem("101", map[string]string{"code": "101"})
+		stored.CloseDb()
+
+		registry := service.NewRepositoryRegistry(fullDiskConnection{memDb.NewMemDbConnection(folder)})
+		keeper := sourceKeeper.NewSourceKeeper(nil, registry, zap.NewNop())
+
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		router.GET("/api/item/:dbName/:itemKey", func(c *gin.Context) { controller.ShowItemDetailJSON(c, keeper) })
+		router.GET("/api/qr-check/:dbName/:itemKey", func(c *gin.Context) { controller.ScanCheckJSON(c, keeper) })
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	get := func(url string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, url, nil)
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	It("a scan the storage refuses is answered 503 with the reason\n", func() {
+		response := get("/api/qr-check/guests/101?activityName=checkin")
+		Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+
+		var body map[string]string
+		Expect(json.Unmarshal(response.Body.Bytes(), &body)).To(Succeed())
+		Expect(body["error"]).To(Equal("guests: add item activity: no space left on device"))
+	})
+
+	It("reads still work, without the refused activity\n", func() {
+		_ = get("/api/qr-check/guests/101?activityName=checkin")
+
+		response := get("/api/item/guests/101")
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		var item scanItem.ItemDetail
+		Expect(json.Unmarshal(response.Body.Bytes(), &item)).To(Succeed())
+		Expect(item.Activities).To(BeEmpty())
+	})
+})
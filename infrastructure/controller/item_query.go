@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"errors"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// isPagedQuery tells whether the client asked for a page, the whole list is returned otherwise
+func isPagedQuery(c *gin.Context) bool {
+	for _, param := range []string{"page", "size", "limit", "cursor"} {
+		if _, exist := c.GetQuery(param); exist {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseItemQuery reads a scanItem.Query from the query string:
+// eq[field]=, prefix[field]=, contains[field]=, activity=, from=, to= (RFC3339),
+// sort=field or sort=-field, limit= and cursor=, or page= and size= as sent by Tabulator
+func parseItemQuery(c *gin.Context) (scanItem.Query, error) {
+	query := scanItem.Query{
+		HasActivity: c.Query("activity"),
+	}
+
+	for _, op := range []string{scanItem.FilterEquals, scanItem.FilterPrefix, scanItem.FilterContains} {
+		for field, value := range c.QueryMap(op) {
+			query.Fields = append(query.Fields, scanItem.FieldFilter{Field: field, Op: op, Value: value})
+		}
+	}
+
+	var err error
+
+	if query.From, err = parseQueryTime(c, "from"); err != nil {
+		return query, err
+	}
+
+	if query.To, err = parseQueryTime(c, "to"); err != nil {
+		return query, err
+	}
+
+	if sortField := c.Query("sort"); strings.HasPrefix(sortField, "-") {
+		query.SortField, query.SortDesc = sortField[1:], true
+	} else {
+		query.SortField = sortField
+	}
+
+	if query.Limit, err = parseQueryInt(c, "limit"); err != nil {
+		return query, err
+	}
+
+	query.Cursor = c.Query("cursor")
+
+	if _, paged := c.GetQuery("page"); paged {
+		page, err := parseQueryInt(c, "page")
+
+		if err != nil {
+			return query, err
+		}
+
+		if query.Limit, err = parseQueryInt(c, "size"); err != nil {
+			return query, err
+		}
+
+		if page > 1 {
+			query.Offset = (page - 1) * query.Limit
+		}
+	}
+
+	return query, nil
+}
+
+func parseQueryTime(c *gin.Context, param string) (time.Time, error) {
+	value := c.Query(param)
+
+	if "" == value {
+		return time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+
+	if err != nil {
+		return parsed, errors.New(param + " must be a RFC3339 time")
+	}
+
+	return parsed, nil
+}
+
+func parseQueryInt(c *gin.Context, param string) (int, error) {
+	value := c.Query(param)
+
+	if "" == value {
+		return 0, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+
+	if err != nil || parsed < 0 {
+		return 0, errors.New(param + " must be a positive number")
+	}
+
+	return parsed, nil
+}
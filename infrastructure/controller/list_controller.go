@@ -1,10 +1,12 @@
 package controller
 
 import (
+	"git.anphabe.net/event/anphabe-event-hub/app/eventLog"
 	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
 	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"time"
 )
 
 type ListController struct {
@@ -28,10 +30,12 @@ func ShowItemDetailJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
 	repoName := c.Param("dbName")
 	itemKey := c.Param("itemKey")
 
-	item, found := sourceKeeper.GetItemDetail(repoName, itemKey)
+	item, found, err := sourceKeeper.GetItemDetail(c.Request.Context(), repoName, itemKey)
 
 	c.Header("Content-Type", "application/json")
-	if found {
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else if found {
 		c.JSON(http.StatusOK, item)
 	} else {
 		c.JSON(http.StatusNotFound, nil)
@@ -39,36 +43,261 @@ func ShowItemDetailJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
 
 }
 
+// url: POST /api/item/:dbName/:itemKey/fields
+// body: {"badge_printed": "yes", "seat": "A12"}
+func SetItemFieldsJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	fields := map[string]string{}
+
+	if err := c.ShouldBindJSON(&fields); err != nil || 0 == len(fields) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body must be a non empty json object of fields"})
+		return
+	}
+
+	if item, found, err := sourceKeeper.SetLocalFields(c.Request.Context(), c.Param("dbName"), c.Param("itemKey"), fields); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else if found {
+		c.JSON(http.StatusOK, item)
+	} else {
+		c.JSON(http.StatusNotFound, nil)
+	}
+}
+
 func ShowItemDetailHTML(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
 	repoName := c.Param("dbName")
 	itemKey := c.Param("itemKey")
 
-	item, found := sourceKeeper.GetItemDetail(repoName, itemKey)
+	item, found, err := sourceKeeper.GetItemDetail(c.Request.Context(), repoName, itemKey)
+
+	if err != nil {
+		respondErrorHTML(c, err, itemKey)
+	} else if found {
+		history, _, _ := sourceKeeper.GetItemHistory(c.Request.Context(), repoName, itemKey)
 
-	if found {
 		c.HTML(http.StatusOK, "found.tmpl", gin.H{
-			"item": item,
+			"item":    item,
+			"history": history,
 		})
 	} else {
 		c.HTML(http.StatusNotFound, "not_found.tmpl", nil)
 	}
 }
 
+func ShowItemHistoryJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	repoName := c.Param("dbName")
+	itemKey := c.Param("itemKey")
+
+	c.Header("Content-Type", "application/json")
+
+	if _, found, err := sourceKeeper.GetItemDetail(c.Request.Context(), repoName, itemKey); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else if !found {
+		c.JSON(http.StatusNotFound, nil)
+	} else if history, kept, err := sourceKeeper.GetItemHistory(c.Request.Context(), repoName, itemKey); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else if kept {
+		c.JSON(http.StatusOK, history)
+	} else {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "item history is not enabled on " + repoName})
+	}
+}
+
 
 
 func ShowRepositoryJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
 	repoName := c.Param("dbName")
 	responses := []map[string]string{}
 
-	for _, item := range sourceKeeper.GetItems(repoName) {
-		responseItem := item.Data
+	query, err := parseItemQuery(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := sourceKeeper.QueryItems(c.Request.Context(), repoName, query)
+
+	if err != nil {
+		respondError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	for _, item := range result.Items {
+		responseItem := make(map[string]string, len(item.Data)+1)
+
+		for field, value := range item.Data {
+			responseItem[field] = value
+		}
+
 		responseItem["Key"] = item.Key
 
 		responses = append(responses, responseItem)
 	}
 
 	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, responses)
+
+	if !isPagedQuery(c) {
+		c.JSON(http.StatusOK, responses)
+		return
+	}
+
+	lastPage := 1
+	if query.Limit > 0 && result.Total > query.Limit {
+		lastPage = (result.Total + query.Limit - 1) / query.Limit
+	}
+
+	// last_page is the name Tabulator's remote pagination reads
+	c.JSON(http.StatusOK, gin.H{
+		"data":        responses,
+		"total":       result.Total,
+		"last_page":   lastPage,
+		"next_cursor": result.NextCursor,
+	})
+}
+
+// DefaultSearchLimit is the number of hits returned when the limit parameter is missing
+const DefaultSearchLimit = 20
+
+func SearchItemsJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	repoName := c.Param("dbName")
+
+	limit, err := parseQueryInt(c, "limit")
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if 0 == limit {
+		limit = DefaultSearchLimit
+	}
+
+	c.Header("Content-Type", "application/json")
+
+	if hits, searchable, err := sourceKeeper.Search(c.Request.Context(), repoName, c.Query("q"), limit); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else if searchable {
+		c.JSON(http.StatusOK, hits)
+	} else {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "search is not enabled on " + repoName})
+	}
+}
+
+// ShowEventsJSON lists recorded activities: after= (sequence), from=, to= (RFC3339), action=, data[field]= and limit=
+func ShowEventsJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	repoName := c.Param("dbName")
+	query := eventLog.Query{
+		Action: c.Query("action"),
+		Data:   c.QueryMap("data"),
+	}
+
+	var err error
+	var after int
+
+	if after, err = parseQueryInt(c, "after"); nil == err {
+		query.After = uint64(after)
+		query.From, err = parseQueryTime(c, "from")
+	}
+
+	if nil == err {
+		query.To, err = parseQueryTime(c, "to")
+	}
+
+	if nil == err {
+		query.Limit, err = parseQueryInt(c, "limit")
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+
+	if events, lastSeq, logged, err := sourceKeeper.GetEvents(c.Request.Context(), repoName, query); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else if logged {
+		c.JSON(http.StatusOK, gin.H{"events": events, "last_seq": lastSeq})
+	} else {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "event log is not enabled on " + repoName})
+	}
+}
+
+func ShowSyncSummaryJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	repoName := c.Param("dbName")
+
+	c.Header("Content-Type", "application/json")
+
+	if summary, err := sourceKeeper.GetSyncSummary(c.Request.Context(), repoName); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else {
+		c.JSON(http.StatusOK, summary)
+	}
+}
+
+// ShowStatsJSON summarises a repository, ?group=field groups the counts by another field than gateway
+func ShowStatsJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	repoName := c.Param("dbName")
+
+	c.Header("Content-Type", "application/json")
+
+	if stats, err := sourceKeeper.GetStats(c.Request.Context(), repoName, c.Query("group")); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else {
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// ShowOccupancyJSON lists the zones of a repository with how many attendees are inside and the seats left
+func ShowOccupancyJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	repoName := c.Param("dbName")
+
+	c.Header("Content-Type", "application/json")
+
+	if occupancy, err := sourceKeeper.GetOccupancy(c.Request.Context(), repoName); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else {
+		c.JSON(http.StatusOK, occupancy)
+	}
+}
+
+// ShowPresentJSON lists the attendees inside the zones of a repository, ?zone=name lists one zone
+func ShowPresentJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	repoName := c.Param("dbName")
+
+	c.Header("Content-Type", "application/json")
+
+	if present, err := sourceKeeper.GetPresent(c.Request.Context(), repoName, c.Query("zone")); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else {
+		c.JSON(http.StatusOK, present)
+	}
+}
+
+// ShowItemPresenceJSON tells in which zones an attendee is and how long they stayed in each
+func ShowItemPresenceJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	repoName := c.Param("dbName")
+	itemKey := c.Param("itemKey")
+
+	c.Header("Content-Type", "application/json")
+
+	if presence, found, err := sourceKeeper.GetPresence(c.Request.Context(), repoName, itemKey); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else if !found {
+		c.JSON(http.StatusNotFound, nil)
+	} else {
+		c.JSON(http.StatusOK, presence)
+	}
+}
+
+func ShowSourceStatusJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	repoName := c.Param("dbName")
+
+	c.Header("Content-Type", "application/json")
+	if status, found := sourceKeeper.GetSourceStatus(repoName); found {
+		c.JSON(http.StatusOK, status)
+	} else {
+		c.JSON(http.StatusNotFound, nil)
+	}
 }
 
 func ShowRepositoryHTML(c *gin.Context) {
@@ -83,7 +312,7 @@ func ShowRepositoryHTML(c *gin.Context) {
 // url: /admin/qr-check/:dbName?Key=%qrData%&activity=asdfadsf
 // params:
 //	Key: unique id
-//  ActivityName: xyz
+//  ActivityName: xyz, the session of the agenda running at the gateway when omitted
 //  extra_fields: key1=val1,key2=val2
 func ScanCheckJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
 	params := map[string]string{}
@@ -111,20 +340,35 @@ func ScanCheckJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
 	delete(params, "activityName")
 
 	c.Header("Content-Type", "application/json")
-	if item, found := sourceKeeper.ScanItem(repoName, itemKey, activityName, params); found {
-		c.JSON(http.StatusOK, item)
+	if result, found, err := sourceKeeper.Scan(c.Request.Context(), repoName, itemKey, activityName, params); err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else if found {
+		c.JSON(verdictStatus(result.Verdict), result)
 	} else {
 		c.JSON(http.StatusNotFound, nil)
 	}
 }
 
+// verdictStatus answers a scan already done or entering a full zone with 409 and a denied one with 403,
+// the body still holds the item
+func verdictStatus(verdict scanItem.Verdict) int {
+	switch verdict.Status {
+	case scanItem.VerdictAlreadyDone, scanItem.VerdictFull:
+		return http.StatusConflict
+	case scanItem.VerdictDenied:
+		return http.StatusForbidden
+	}
+
+	return http.StatusOK
+}
+
 //
 // url: /admin/qr-check/:dbName/:itemKey?activity=asdfadsf
 // url: /admin/qr-check/:dbName?Key=%qrData%&activity=asdfadsf
 // params:
 //	itemKey: unique id (barcode)
 // 	Key: unique id (barcode)
-//  ActivityName: xyz
+//  ActivityName: xyz, the session of the agenda running at the gateway when omitted
 //  extra_fields: key1=val1,key2=val2
 func ScanCheckHTML(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
 	params := map[string]string{}
@@ -151,10 +395,26 @@ func ScanCheckHTML(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
 	delete(params, "itemKey")
 	delete(params, "activityName")
 
-	item, found := sourceKeeper.ScanItem(repoName, itemKey, activityName, params)
-
-	if found {
-		c.HTML(http.StatusOK, "found.tmpl", extractMap(item))
+	result, found, err := sourceKeeper.Scan(c.Request.Context(), repoName, itemKey, activityName, params)
+
+	if err != nil {
+		respondErrorHTML(c, err, itemKey)
+	} else if found && !result.Verdict.Allowed() {
+		page := extractMap(result.ItemDetail)
+		page["verdict"] = result.Verdict
+		page["entitlements"] = result.Entitlements
+		page["occupancy"] = result.Occupancy
+		page["session"] = result.Session
+
+		c.HTML(verdictStatus(result.Verdict), "refused.tmpl", page)
+	} else if found {
+		page := extractMap(result.ItemDetail)
+		page["entitlements"] = result.Entitlements
+		page["occupancy"] = result.Occupancy
+		page["session"] = result.Session
+		page["history"], _, _ = sourceKeeper.GetItemHistory(c.Request.Context(), repoName, itemKey)
+
+		c.HTML(http.StatusOK, "found.tmpl", page)
 	} else {
 		c.HTML(http.StatusNotFound, "not_found.tmpl", gin.H{ "key" : itemKey})
 	}
@@ -171,6 +431,32 @@ func StartImport(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
 	}
 }
 
+func BackupArchive(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	fileName := "event-hub-" + time.Now().Format("20060102-150405") + ".jsonl.gz"
+
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", `attachment; filename="`+fileName+`"`)
+
+	_, err := sourceKeeper.Backup(c.Request.Context(), c.Writer)
+
+	// headers are already sent when streaming fails, the truncated archive lacks its end marker
+	if err != nil && !c.Writer.Written() {
+		c.Header("Content-Disposition", "")
+		respondError(c, err, http.StatusInternalServerError)
+	}
+}
+
+func RestoreArchive(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	stats, err := sourceKeeper.Restore(c.Request.Context(), c.Request.Body)
+
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error(), "restored": stats})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
 func extractMap(item *scanItem.ItemDetail) map[string]interface{} {
 	result := make(map[string]interface{})
 
@@ -0,0 +1,93 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/controller"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+)
+
+var _ = Describe("Scan verdicts\n", func() {
+	var folder string
+	var router *gin.Engine
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "controller")
+
+		connection := memDb.NewMemDbConnection(folder)
+		stored, _ := connection.InitRepository("guests")
+		_, _ = stored.NewItem("101", map[string]string{"code": "101"})
+		stored.CloseDb()
+
+		registry := service.NewRepositoryRegistry(memDb.NewMemDbConnection(folder))
+		keeper := sourceKeeper.NewSourceKeeper(nil, registry, zap.NewNop())
+		Expect(keeper.AddSource(config.DbSource{
+			Name:    "guests",
+			IdField: "code",
+			Rules: config.ScanRules{
+				Source:     config.ScanRule{Gateways: []string{"north"}},
+				Activities: map[string]config.ScanRule{"checkin": {Once: true}},
+			},
+			Agenda: []config.Session{
+				{Name: "Registration", Activity: "checkin", Gateways: []string{"north"}, From: "2000-01-01", To: "2100-01-01"},
+			},
+		})).To(Succeed())
+
+		gin.SetMode(gin.TestMode)
+		router = gin.New()
+		router.GET("/api/qr-check/:dbName/:itemKey", func(c *gin.Context) { controller.ScanCheckJSON(c, keeper) })
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	scan := func(url string) (int, sourceKeeper.ScanResult) {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, url, nil)
+		router.ServeHTTP(recorder, request)
+
+		var result sourceKeeper.ScanResult
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &result)).To(Succeed())
+
+		return recorder.Code, result
+	}
+
+	It("answers the verdict with the item, 409 when already done and 403 when denied\n", func() {
+		status, result := scan("/api/qr-check/guests/101?activityName=checkin&gateway=north")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(result.Key).To(Equal("101"))
+		Expect(result.Verdict.Status).To(Equal(scanItem.VerdictAllowed))
+
+		status, result = scan("/api/qr-check/guests/101?activityName=checkin&gateway=north")
+		Expect(status).To(Equal(http.StatusConflict))
+		Expect(result.Verdict.Status).To(Equal(scanItem.VerdictAlreadyDone))
+		Expect(result.Verdict.At.IsZero()).To(BeFalse())
+		Expect(result.Activities).To(HaveLen(1))
+
+		status, result = scan("/api/qr-check/guests/101?activityName=lunch&gateway=east")
+		Expect(status).To(Equal(http.StatusForbidden))
+		Expect(result.Verdict.Rule).To(Equal(scanItem.RuleGateway))
+	})
+
+	It("resolves an omitted activity from the agenda, 422 when no session runs at the gateway\n", func() {
+		status, result := scan("/api/qr-check/guests/101?gateway=north")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(result.Session.Name).To(Equal("Registration"))
+		Expect(result.Activities[0].Action).To(Equal("checkin"))
+
+		status, _ = scan("/api/qr-check/guests/101?gateway=east")
+		Expect(status).To(Equal(http.StatusUnprocessableEntity))
+	})
+})
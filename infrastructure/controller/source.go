@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func ListSourcesJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	c.JSON(http.StatusOK, sourceKeeper.ListSources())
+}
+
+// url: POST /api/sources
+// body: a dbsource as in config.yaml, e.g. {"name": "guests", "idfield": "code", "fetchingurl": "..."}
+func AddSourceJSON(c *gin.Context, keeper *sourceKeeper.Keeper) {
+	var source config.DbSource
+
+	if err := c.ShouldBindJSON(&source); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := keeper.AddSource(source); err == sourceKeeper.ErrSourceExists {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	} else if err != nil {
+		respondError(c, err, http.StatusBadRequest)
+	} else {
+		c.JSON(http.StatusCreated, "ok")
+	}
+}
+
+func RemoveSourceJSON(c *gin.Context, keeper *sourceKeeper.Keeper) {
+	unpushed, err := keeper.RemoveSource(c.Param("name"), sourceKeeper.DrainTimeout)
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unpushed": unpushed})
+}
+
+func PauseSourceJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	if err := sourceKeeper.PauseSource(c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusOK, "ok")
+	}
+}
+
+func ResumeSourceJSON(c *gin.Context, sourceKeeper *sourceKeeper.Keeper) {
+	if err := sourceKeeper.ResumeSource(c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusOK, "ok")
+	}
+}
+
+// url: POST /api/sources/:name/retention
+// removes the personal data of the source now, as its retention policy says
+func ApplyRetentionJSON(c *gin.Context, keeper *sourceKeeper.Keeper) {
+	report, err := keeper.ApplyRetention(c.Request.Context(), c.Param("name"), sourceKeeper.RetentionOnDemand)
+
+	if err == sourceKeeper.ErrNoRetention {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	} else if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+	} else {
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// url: GET /api/admin/audit?source=guests
+func ShowAuditLogJSON(c *gin.Context, keeper *sourceKeeper.Keeper) {
+	c.JSON(http.StatusOK, keeper.GetAuditLog(c.Query("source")))
+}
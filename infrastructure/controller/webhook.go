@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"git.anphabe.net/event/anphabe-event-hub/app/webhook"
+	"git.anphabe.net/event/anphabe-event-hub/config"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
@@ -20,3 +22,38 @@ func Hello(c *gin.Context) {
 		"job_title": "Chief Tumlum Tala officer",
 	})
 }
+
+func ListWebhooksJSON(c *gin.Context, dispatcher *webhook.Dispatcher) {
+	targets := dispatcher.Targets()
+
+	// never give secrets back
+	for idx := range targets {
+		targets[idx].Secret = ""
+	}
+
+	c.JSON(http.StatusOK, targets)
+}
+
+func AddWebhookJSON(c *gin.Context, dispatcher *webhook.Dispatcher) {
+	var target config.Webhook
+
+	if err := c.ShouldBindJSON(&target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := dispatcher.AddTarget(target); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, "ok")
+}
+
+func RemoveWebhookJSON(c *gin.Context, dispatcher *webhook.Dispatcher) {
+	if dispatcher.RemoveTarget(c.Param("name")) {
+		c.JSON(http.StatusOK, "ok")
+	} else {
+		c.JSON(http.StatusNotFound, nil)
+	}
+}
@@ -0,0 +1,272 @@
+package injection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/app/archive"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"go.uber.org/zap"
+	"os"
+)
+
+const commandUsage = `commands:
+  backup  <archive.jsonl.gz>          write every repository into an archive
+  restore <archive.jsonl.gz>          load an archive into the configured storage
+  migrate <adapter> <folder>          copy every repository of another storage into the configured storage
+  keygen  <key-file>                  write a new encryption key
+  rekey   <key-file|none>             re-encrypt the stopped storages with another key, none decrypts them;
+                                      the storage options have to name the new key afterwards`
+
+// RunCommand runs a maintenance subcommand instead of the server, handled is false when args name no command
+func RunCommand(args []string) (handled bool, err error) {
+	if 0 == len(args) {
+		return false, nil
+	}
+
+	cfg := InitConfig()
+	log := InitLogger(cfg)
+
+	var stats archive.Stats
+
+	switch args[0] {
+	case "backup":
+		if len(args) != 2 {
+			return true, errors.New(commandUsage)
+		}
+
+		var repos []scanItem.RepositoryInterfaceV2
+
+		if repos, err = repositories(cfg, InitRepositoryRegistry(cfg)); nil == err {
+			stats, err = backupToFile(args[1], repos)
+		}
+
+	case "restore":
+		if len(args) != 2 {
+			return true, errors.New(commandUsage)
+		}
+
+		stats, err = restoreFromFile(args[1], InitRepositoryRegistry(cfg))
+
+	case "migrate":
+		if len(args) != 3 {
+			return true, errors.New(commandUsage)
+		}
+
+		stats, err = migrate(cfg, args[1], args[2])
+
+	case "keygen":
+		if len(args) != 2 {
+			return true, errors.New(commandUsage)
+		}
+
+		err = keygen(args[1])
+
+	case "rekey":
+		if len(args) != 2 {
+			return true, errors.New(commandUsage)
+		}
+
+		err = rekey(cfg, log, args[1])
+
+	default:
+		return true, fmt.Errorf("unknown command %q\n%s", args[0], commandUsage)
+	}
+
+	// keygen and rekey work on closed storages, they never open the registry
+	if nil != repoRegistry {
+		repoRegistry.Shutdown()
+	}
+
+	if nil == err {
+		log.Info("Command finished",
+			zap.String("command", args[0]),
+			zap.Int("repositories", stats.Repositories),
+			zap.Int("items", stats.Items),
+			zap.Int("activities", stats.Activities))
+	}
+
+	return true, err
+}
+
+// repositories opens the repository of every configured source, a backup missing one of them would look complete
+func repositories(cfg *config.ConfigurationInfo, registry service.RepositoryRegistryInterface) ([]scanItem.RepositoryInterfaceV2, error) {
+	var repos []scanItem.RepositoryInterfaceV2
+
+	for _, source := range cfg.DbSources {
+		repo, err := registry.GetRepositoryV2(context.Background(), source.Name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		repos = append(repos, repo)
+	}
+
+	return repos, nil
+}
+
+// backupToFile writes the archive next to fileName then renames it, a failed backup never replaces a good one
+func backupToFile(fileName string, repos []scanItem.RepositoryInterfaceV2) (archive.Stats, error) {
+	tmpName := fileName + ".tmp"
+	fp, err := os.Create(tmpName)
+
+	if err != nil {
+		return archive.Stats{}, err
+	}
+
+	stats, err := archive.Export(context.Background(), fp, repos)
+
+	if nil == err {
+		err = fp.Sync()
+	}
+
+	if closeErr := fp.Close(); nil == err {
+		err = closeErr
+	}
+
+	if nil != err {
+		_ = os.Remove(tmpName)
+		return stats, err
+	}
+
+	return stats, os.Rename(tmpName, fileName)
+}
+
+func restoreFromFile(fileName string, registry service.RepositoryRegistryInterface) (archive.Stats, error) {
+	fp, err := os.Open(fileName)
+
+	if err != nil {
+		return archive.Stats{}, err
+	}
+
+	defer func() { _ = fp.Close() }()
+
+	return archive.Import(context.Background(), fp, registry.GetRepositoryV2)
+}
+
+func migrate(cfg *config.ConfigurationInfo, adapter string, folder string) (archive.Stats, error) {
+	source, err := service.OpenStorage(adapter, map[string]interface{}{"folder": folder})
+
+	if err != nil {
+		return archive.Stats{}, err
+	}
+
+	sourceRegistry := service.NewRepositoryRegistry(withLogger(source, InitLogger(cfg))).SetLogger(InitLogger(cfg))
+	defer sourceRegistry.Shutdown()
+
+	ctx := context.Background()
+	target := InitRepositoryRegistry(cfg)
+	total := archive.Stats{}
+
+	for _, dbSource := range cfg.DbSources {
+		src, err := sourceRegistry.GetRepositoryV2(ctx, dbSource.Name)
+
+		if err != nil {
+			return total, err
+		}
+
+		dst, err := target.GetRepositoryV2(ctx, dbSource.Name)
+
+		if err != nil {
+			return total, err
+		}
+
+		stats, err := archive.Migrate(ctx, src, dst)
+		total.Repositories += stats.Repositories
+		total.Items += stats.Items
+		total.Activities += stats.Activities
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// keygen writes a new key readable by its owner only, an existing key file is never replaced
+func keygen(fileName string) error {
+	key, err := atRest.GenerateKey()
+
+	if err != nil {
+		return err
+	}
+
+	fp, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fp.WriteString(key + "\n")
+
+	if closeErr := fp.Close(); nil == err {
+		err = closeErr
+	}
+
+	return err
+}
+
+// rekey seals the default storage and every per-source storage with the key in keyFile, "none" decrypts them
+func rekey(cfg *config.ConfigurationInfo, log *zap.Logger, keyFile string) error {
+	var key []byte
+
+	if "none" != keyFile {
+		var err error
+
+		if key, err = atRest.ReadKeyFile(keyFile); err != nil {
+			return err
+		}
+	}
+
+	storages := []config.Storage{cfg.Storage}
+
+	for _, source := range cfg.DbSources {
+		if nil != source.Storage {
+			storages = append(storages, *source.Storage)
+		}
+	}
+
+	done := make(map[string]bool)
+
+	for _, storageCfg := range storages {
+		adapter := storageAdapter(storageCfg)
+
+		if done[adapter+":"+storageCfg.Folder] {
+			continue
+		}
+
+		done[adapter+":"+storageCfg.Folder] = true
+
+		driver, err := service.GetStorageDriver(adapter)
+
+		if err != nil {
+			return err
+		}
+
+		options, err := service.DecodeStorageOptions(adapter, storageSettings(storageCfg))
+
+		if err != nil {
+			return err
+		}
+
+		if nil == driver.Rekey {
+			log.Warn("Storage adapter does not encrypt at rest, storage left as is",
+				zap.String("adapter", adapter),
+				zap.String("folder", storageCfg.Folder))
+			continue
+		}
+
+		if err := driver.Rekey(options, key); err != nil {
+			return fmt.Errorf("%s storage %s: %s", adapter, storageCfg.Folder, err.Error())
+		}
+
+		log.Info("Storage re-encrypted", zap.String("adapter", adapter), zap.String("folder", storageCfg.Folder))
+	}
+
+	return nil
+}
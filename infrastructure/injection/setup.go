@@ -2,13 +2,17 @@ package injection
 
 import (
 	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/app/auditLog"
+	"git.anphabe.net/event/anphabe-event-hub/app/eventLog"
+	"git.anphabe.net/event/anphabe-event-hub/app/itemHistory"
+	"git.anphabe.net/event/anphabe-event-hub/app/search"
 	"git.anphabe.net/event/anphabe-event-hub/app/sourceKeeper"
+	"git.anphabe.net/event/anphabe-event-hub/app/webhook"
 	"git.anphabe.net/event/anphabe-event-hub/config"
 	"git.anphabe.net/event/anphabe-event-hub/domain/service"
 	"git.anphabe.net/event/anphabe-event-hub/infrastructure/assets"
 	"git.anphabe.net/event/anphabe-event-hub/infrastructure/controller"
-	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/bowDb"
-	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/skip2/go-qrcode"
@@ -23,6 +27,7 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
@@ -35,6 +40,8 @@ var (
 	dbConnection service.DbConnectionInterface
 	repoRegistry service.RepositoryRegistryInterface
 	keeper       *sourceKeeper.Keeper
+	dispatcher   *webhook.Dispatcher
+	audit        *auditLog.Store
 	logger       *zap.Logger
 	outboundAddr string
 )
@@ -47,6 +54,11 @@ func init() {
 func InitConfig() *config.ConfigurationInfo {
 	if nil == conf {
 		conf = config.NewConfig().GetConfig()
+
+		if err := ValidateStorage(conf); err != nil {
+			panic(err.Error())
+		}
+
 		GetOutboundAddress(conf)
 	}
 
@@ -126,20 +138,57 @@ func InitSourceKeeper() *sourceKeeper.Keeper {
 	if nil == keeper {
 		cfg := InitConfig()
 		keeper = sourceKeeper.NewSourceKeeper(cfg.DbSources, InitRepositoryRegistry(cfg), InitLogger(cfg))
+		keeper.SetConnectionFactory(func(source config.DbSource) (service.DbConnectionInterface, error) {
+			return sourceConnection(cfg, source)
+		})
+		keeper.AddScanListener(InitWebhookDispatcher(cfg))
+		keeper.SetAuditLog(InitAuditLog(cfg))
 	}
 
 	return keeper
 }
 
+// InitAuditLog opens the audit log kept in the default storage folder
+func InitAuditLog(cfg *config.ConfigurationInfo) *auditLog.Store {
+	if nil == audit {
+		if err := os.MkdirAll(cfg.Storage.Folder, 0755); err != nil {
+			panic("could not create storage folder: " + err.Error())
+		}
+
+		store, err := auditLog.OpenStore(filepath.Join(cfg.Storage.Folder, "audit.log"))
+
+		if err != nil {
+			panic("could not open audit log: " + err.Error())
+		}
+
+		audit = store
+	}
+
+	return audit
+}
+
+func InitWebhookDispatcher(cfg *config.ConfigurationInfo) *webhook.Dispatcher {
+	if nil == dispatcher {
+		if nil == cfg {
+			cfg = InitConfig()
+		}
+
+		dispatcher = webhook.NewDispatcher(cfg.Webhooks, InitLogger(cfg))
+		dispatcher.Start(2)
+	}
+
+	return dispatcher
+}
+
 func InitDBConnection(cfg *config.ConfigurationInfo) service.DbConnectionInterface {
 	if nil == dbConnection {
-		storageCfg := cfg.Storage
-		switch storageCfg.Adapter {
-		case "mem":
-			dbConnection = memDb.NewMemDbConnection(storageCfg.Folder)
-		default:
-			dbConnection = bowDb.NewBowDbConnection(storageCfg.Folder)
+		connection, err := openStorage(cfg.Storage, InitLogger(cfg))
+
+		if err != nil {
+			panic("could not open storage: " + err.Error())
 		}
+
+		dbConnection = connection
 	}
 
 	return dbConnection
@@ -151,12 +200,53 @@ func InitRepositoryRegistry(cfg *config.ConfigurationInfo) service.RepositoryReg
 			cfg = InitConfig()
 		}
 
-		repoRegistry = service.NewRepositoryRegistry(InitDBConnection(cfg))
+		repoRegistry = service.NewRepositoryRegistry(InitDBConnection(cfg)).SetLogger(InitLogger(cfg))
+
+		for _, source := range cfg.DbSources {
+			connection, err := sourceConnection(cfg, source)
+
+			if err != nil {
+				panic("could not open storage of " + source.Name + ": " + err.Error())
+			}
+
+			repoRegistry.SetConnection(source.Name, connection)
+		}
 	}
 
 	return repoRegistry
 }
 
+// sourceConnection opens the storage of a source: its own or the default one.
+// Every source records its activities in an event log and keeps a search index over its items,
+// the history is the outermost layer as the keeper hands it the import run of the items it sets.
+func sourceConnection(cfg *config.ConfigurationInfo, source config.DbSource) (service.DbConnectionInterface, error) {
+	connection := InitDBConnection(cfg)
+	folder := cfg.Storage.Folder
+
+	if nil != source.Storage {
+		var err error
+
+		if _, err = service.DecodeStorageOptions(storageAdapter(*source.Storage), storageSettings(*source.Storage)); err != nil {
+			return nil, err
+		}
+
+		if connection, err = openStorage(*source.Storage, InitLogger(cfg)); err != nil {
+			return nil, err
+		}
+
+		folder = source.Storage.Folder
+	}
+
+	// the event log and the history are sealed like the storage they sit next to
+	cipher := atRest.CipherOf(connection)
+	logger := InitLogger(cfg)
+
+	connection = eventLog.NewLoggedConnection(connection, filepath.Join(folder, eventsFolder), cipher, logger)
+	connection = search.NewIndexedConnection(connection, source.SearchFields)
+
+	return itemHistory.NewHistoryConnection(connection, filepath.Join(folder, historyFolder), source.HistoryDepth, cipher, logger), nil
+}
+
 func SignalsHandle() <-chan struct{} {
 	quit := make(chan struct{})
 
@@ -225,7 +315,33 @@ func InitGin() *gin.Engine {
 		api.GET("/qr-check/:dbName/:itemKey", func(c *gin.Context) {controller.ScanCheckJSON(c, keeper)})
 		api.GET("/db/:dbName", func(c *gin.Context) {controller.ShowRepositoryJSON(c, keeper)})
 		api.GET("/db/:dbName/import", func(c *gin.Context) {controller.StartImport(c, keeper)})
+		api.GET("/db/:dbName/sync", func(c *gin.Context) {controller.ShowSyncSummaryJSON(c, keeper)})
+		api.GET("/db/:dbName/status", func(c *gin.Context) {controller.ShowSourceStatusJSON(c, keeper)})
+		api.GET("/db/:dbName/stats", func(c *gin.Context) {controller.ShowStatsJSON(c, keeper)})
+		api.GET("/db/:dbName/occupancy", func(c *gin.Context) {controller.ShowOccupancyJSON(c, keeper)})
+		api.GET("/db/:dbName/present", func(c *gin.Context) {controller.ShowPresentJSON(c, keeper)})
+		api.GET("/db/:dbName/search", func(c *gin.Context) {controller.SearchItemsJSON(c, keeper)})
+		api.GET("/db/:dbName/events", func(c *gin.Context) {controller.ShowEventsJSON(c, keeper)})
 		api.GET("/item/:dbName/:itemKey", func(c *gin.Context) {controller.ShowItemDetailJSON(c, keeper)})
+		api.POST("/item/:dbName/:itemKey/fields", func(c *gin.Context) {controller.SetItemFieldsJSON(c, keeper)})
+		api.GET("/item/:dbName/:itemKey/history", func(c *gin.Context) {controller.ShowItemHistoryJSON(c, keeper)})
+		api.GET("/item/:dbName/:itemKey/presence", func(c *gin.Context) {controller.ShowItemPresenceJSON(c, keeper)})
+
+		hooks := InitWebhookDispatcher(nil)
+		api.GET("/webhooks", func(c *gin.Context) {controller.ListWebhooksJSON(c, hooks)})
+		api.POST("/webhooks", func(c *gin.Context) {controller.AddWebhookJSON(c, hooks)})
+		api.DELETE("/webhooks/:name", func(c *gin.Context) {controller.RemoveWebhookJSON(c, hooks)})
+
+		api.GET("/sources", func(c *gin.Context) {controller.ListSourcesJSON(c, keeper)})
+		api.POST("/sources", func(c *gin.Context) {controller.AddSourceJSON(c, keeper)})
+		api.DELETE("/sources/:name", func(c *gin.Context) {controller.RemoveSourceJSON(c, keeper)})
+		api.POST("/sources/:name/pause", func(c *gin.Context) {controller.PauseSourceJSON(c, keeper)})
+		api.POST("/sources/:name/resume", func(c *gin.Context) {controller.ResumeSourceJSON(c, keeper)})
+		api.POST("/sources/:name/retention", func(c *gin.Context) {controller.ApplyRetentionJSON(c, keeper)})
+
+		api.GET("/admin/backup", func(c *gin.Context) {controller.BackupArchive(c, keeper)})
+		api.POST("/admin/restore", func(c *gin.Context) {controller.RestoreArchive(c, keeper)})
+		api.GET("/admin/audit", func(c *gin.Context) {controller.ShowAuditLogJSON(c, keeper)})
 	}
 
 	return router
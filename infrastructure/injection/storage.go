@@ -0,0 +1,210 @@
+package injection
+
+import (
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/app/eventLog"
+	"git.anphabe.net/event/anphabe-event-hub/app/itemHistory"
+	"git.anphabe.net/event/anphabe-event-hub/config"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/boltDb"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/bowDb"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/sqliteDb"
+	"go.uber.org/zap"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultStorageAdapter is used when storage.adapter is not set
+const DefaultStorageAdapter = "bow"
+
+// folders of a storage holding the event logs and the item histories of its sources
+const (
+	eventsFolder  = "events"
+	historyFolder = "history"
+)
+
+// opened connections by adapter and folder, sources sharing a storage share its connection.
+// Sources can be added at runtime, storageMu guards the cache.
+var (
+	storageMu          sync.Mutex
+	storageConnections = make(map[string]service.DbConnectionInterface)
+)
+
+func init() {
+	service.RegisterStorageDriver(service.StorageDriver{
+		Name:       "mem",
+		NewOptions: func() interface{} { return &memDb.Options{} },
+		Open: func(options interface{}) (service.DbConnectionInterface, error) {
+			opts := options.(*memDb.Options)
+			cipher, err := atRest.LoadKey(opts.EncryptionKeyFile, opts.EncryptionKeyEnv)
+
+			if err != nil {
+				return nil, err
+			}
+
+			connection := memDb.NewMemDbConnection(opts.Folder).SetSnapshotInterval(opts.SnapshotInterval)
+
+			if err := connection.SetCipher(cipher); err != nil {
+				return nil, err
+			}
+
+			return connection, nil
+		},
+		Rekey: func(options interface{}, key []byte) error {
+			opts := options.(*memDb.Options)
+			from, to, err := rekeyCiphers(opts.EncryptionKeyFile, opts.EncryptionKeyEnv, key)
+
+			if err != nil {
+				return err
+			}
+
+			if err := memDb.Rekey(opts.Folder, from, to); err != nil {
+				return err
+			}
+
+			return rekeyLogs(opts.Folder, from, to)
+		},
+	})
+
+	service.RegisterStorageDriver(service.StorageDriver{
+		Name:       "bow",
+		NewOptions: func() interface{} { return &bowDb.Options{} },
+		Open: func(options interface{}) (service.DbConnectionInterface, error) {
+			opts := options.(*bowDb.Options)
+			cipher, err := atRest.LoadKey(opts.EncryptionKeyFile, opts.EncryptionKeyEnv)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return bowDb.NewEncryptedBowDbConnection(opts.Folder, cipher)
+		},
+		Rekey: func(options interface{}, key []byte) error {
+			opts := options.(*bowDb.Options)
+			from, to, err := rekeyCiphers(opts.EncryptionKeyFile, opts.EncryptionKeyEnv, key)
+
+			if err != nil {
+				return err
+			}
+
+			if err := bowDb.Rekey(opts.Folder, from, to); err != nil {
+				return err
+			}
+
+			return rekeyLogs(opts.Folder, from, to)
+		},
+	})
+
+	service.RegisterStorageDriver(service.StorageDriver{
+		Name:       "bolt",
+		NewOptions: func() interface{} { return &boltDb.Options{} },
+		Open: func(options interface{}) (service.DbConnectionInterface, error) {
+			return boltDb.NewBoltDbConnection(options.(*boltDb.Options).Folder)
+		},
+	})
+
+	service.RegisterStorageDriver(service.StorageDriver{
+		Name:       "sqlite",
+		NewOptions: func() interface{} { return &sqliteDb.Options{} },
+		Open: func(options interface{}) (service.DbConnectionInterface, error) {
+			return sqliteDb.NewSqliteDbConnection(options.(*sqliteDb.Options).Folder)
+		},
+	})
+}
+
+// ValidateStorage checks the default storage and every per-source override against the registered adapters
+func ValidateStorage(cfg *config.ConfigurationInfo) error {
+	if _, err := service.DecodeStorageOptions(storageAdapter(cfg.Storage), storageSettings(cfg.Storage)); err != nil {
+		return fmt.Errorf("storage: %s", err.Error())
+	}
+
+	for _, source := range cfg.DbSources {
+		if nil != source.Storage {
+			if _, err := service.DecodeStorageOptions(storageAdapter(*source.Storage), storageSettings(*source.Storage)); err != nil {
+				return fmt.Errorf("dbsource %s storage: %s", source.Name, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+func openStorage(storageCfg config.Storage, logger *zap.Logger) (service.DbConnectionInterface, error) {
+	adapter := storageAdapter(storageCfg)
+	cacheKey := adapter + ":" + storageCfg.Folder
+
+	storageMu.Lock()
+	defer storageMu.Unlock()
+
+	if connection, found := storageConnections[cacheKey]; found {
+		return connection, nil
+	}
+
+	connection, err := service.OpenStorage(adapter, storageSettings(storageCfg))
+
+	if err != nil {
+		return nil, err
+	}
+
+	storageConnections[cacheKey] = withLogger(connection, logger)
+
+	return connection, nil
+}
+
+// withLogger hands logger to the connections which fail in the background
+func withLogger(connection service.DbConnectionInterface, logger *zap.Logger) service.DbConnectionInterface {
+	if mem, ok := connection.(*memDb.Connection); ok {
+		mem.SetLogger(logger)
+	}
+
+	return connection
+}
+
+// rekeyLogs seals the event logs and the item histories kept in folder with to
+func rekeyLogs(folder string, from *atRest.Cipher, to *atRest.Cipher) error {
+	if err := eventLog.Rekey(filepath.Join(folder, eventsFolder), from, to); err != nil {
+		return err
+	}
+
+	return itemHistory.Rekey(filepath.Join(folder, historyFolder), from, to)
+}
+
+// rekeyCiphers returns the cipher of the configured key and the cipher of the new one
+func rekeyCiphers(keyFile string, keyEnv string, key []byte) (from *atRest.Cipher, to *atRest.Cipher, err error) {
+	if from, err = atRest.LoadKey(keyFile, keyEnv); err != nil {
+		return nil, nil, err
+	}
+
+	if nil != key {
+		to, err = atRest.NewCipher(key)
+	}
+
+	return from, to, err
+}
+
+func storageAdapter(storageCfg config.Storage) string {
+	if adapter := strings.TrimSpace(storageCfg.Adapter); "" != adapter {
+		return strings.ToLower(adapter)
+	}
+
+	return DefaultStorageAdapter
+}
+
+func storageSettings(storageCfg config.Storage) map[string]interface{} {
+	settings := map[string]interface{}{
+		"folder": storageCfg.Folder,
+	}
+
+	if storageCfg.SnapshotInterval > 0 {
+		settings["snapshotinterval"] = storageCfg.SnapshotInterval
+	}
+
+	for key, value := range storageCfg.Options {
+		settings[key] = value
+	}
+
+	return settings
+}
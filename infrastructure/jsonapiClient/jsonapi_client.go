@@ -1,6 +1,7 @@
 package jsonapiClient
 
 import (
+	"fmt"
 	"github.com/buger/jsonparser"
 	"github.com/nahid/gohttp"
 	"io/ioutil"
@@ -29,7 +30,28 @@ func Get(url string) (*JsonAPIResponse, error) {
 	return json, nil
 }
 
+// GetRaw returns the unparsed response body, any non-2xx status is an error
+func GetRaw(url string) ([]byte, error) {
+	body, status, err := doRequest(url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		return body, fmt.Errorf("unexpected http status %d", status)
+	}
+
+	return body, nil
+}
+
 func doGet(url string) ([]byte, error) {
+	body, _, err := doRequest(url)
+
+	return body, err
+}
+
+func doRequest(url string) ([]byte, int, error) {
 	//github.com/nahid/gohttp
 	var httpClient = &http.Client{
 		Timeout: time.Second * 10,
@@ -38,7 +60,7 @@ func doGet(url string) ([]byte, error) {
 	resp, err := httpClient.Get(url)
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	defer func() { _ = resp.Body.Close() }()
@@ -46,10 +68,10 @@ func doGet(url string) ([]byte, error) {
 	body, err := ioutil.ReadAll(resp.Body)
 
 	if err != nil {
-		return nil, err
+		return nil, resp.StatusCode, err
 	}
 
-	return body, nil
+	return body, resp.StatusCode, nil
 }
 
 func doGetAsync(url string) ([]byte, error) {
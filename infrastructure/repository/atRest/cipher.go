@@ -0,0 +1,125 @@
+package atRest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+// KeySize is the length of an encryption key, AES-256
+const KeySize = 32
+
+var sealMagic = []byte("EVHUBSEAL1")
+
+var (
+	ErrKeyRequired = errors.New("the store is encrypted and no encryption key is configured")
+	ErrWrongKey    = errors.New("the encryption key does not open the store")
+	ErrNotSealed   = errors.New("data is not encrypted")
+)
+
+// Cipher seals data with AES-256-GCM.
+// A nil Cipher leaves data in the clear and refuses to open sealed data.
+type Cipher struct {
+	seal      cipher.AEAD
+	open      []cipher.AEAD
+	sealPlain bool
+	openPlain bool
+}
+
+func NewCipher(key []byte) (*Cipher, error) {
+	aead, err := newAEAD(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{seal: aead, open: []cipher.AEAD{aead}}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("encryption key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Rekeying opens data sealed with either key or left in the clear, and seals it like to.
+// It lets an interrupted re-encryption be run again over data it already converted.
+func Rekeying(from *Cipher, to *Cipher) *Cipher {
+	rekeying := &Cipher{openPlain: true}
+
+	if nil == to {
+		rekeying.sealPlain = true
+	} else {
+		rekeying.seal = to.seal
+		rekeying.open = append(rekeying.open, to.open...)
+	}
+
+	if nil != from {
+		rekeying.open = append(rekeying.open, from.open...)
+	}
+
+	return rekeying
+}
+
+// IsSealed tells whether data was written by Seal
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, sealMagic)
+}
+
+// Seal returns the encrypted form of plain, prefixed with a marker and a random nonce
+func (c *Cipher) Seal(plain []byte) []byte {
+	if nil == c || c.sealPlain {
+		return plain
+	}
+
+	nonce := make([]byte, c.seal.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		panic("atRest: no randomness for a nonce: " + err.Error())
+	}
+
+	sealed := make([]byte, 0, len(sealMagic)+len(nonce)+len(plain)+c.seal.Overhead())
+	sealed = append(sealed, sealMagic...)
+	sealed = append(sealed, nonce...)
+
+	return c.seal.Seal(sealed, nonce, plain, sealMagic)
+}
+
+// Open returns the plain form of data written by Seal
+func (c *Cipher) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		if nil == c || c.openPlain {
+			return data, nil
+		}
+
+		return nil, ErrNotSealed
+	}
+
+	if nil == c || 0 == len(c.open) {
+		return nil, ErrKeyRequired
+	}
+
+	for _, aead := range c.open {
+		rest := data[len(sealMagic):]
+
+		if len(rest) < aead.NonceSize() {
+			return nil, ErrWrongKey
+		}
+
+		if plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], sealMagic); nil == err {
+			return plain, nil
+		}
+	}
+
+	return nil, ErrWrongKey
+}
@@ -0,0 +1,111 @@
+package atRest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func testCipher(t *testing.T) *Cipher {
+	text, _ := GenerateKey()
+	key, err := ParseKey(text)
+	assert.Nil(t, err)
+
+	cipher, err := NewCipher(key)
+	assert.Nil(t, err)
+
+	return cipher
+}
+
+func TestCipher__Given__SealedData__Expect__OpenedWithTheSameKeyOnly(t *testing.T) {
+	cipher := testCipher(t)
+	sealed := cipher.Seal([]byte("Jane Doe"))
+
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, string(sealed), "Jane Doe")
+
+	plain, err := cipher.Open(sealed)
+	assert.Nil(t, err)
+	assert.Equal(t, "Jane Doe", string(plain))
+
+	_, err = testCipher(t).Open(sealed)
+	assert.Equal(t, ErrWrongKey, err)
+}
+
+func TestCipher__Given__NoCipher__Expect__ClearDataAndSealedDataRefused(t *testing.T) {
+	var none *Cipher
+
+	assert.Equal(t, []byte("Jane Doe"), none.Seal([]byte("Jane Doe")))
+
+	_, err := none.Open(testCipher(t).Seal([]byte("Jane Doe")))
+	assert.Equal(t, ErrKeyRequired, err)
+
+	_, err = testCipher(t).Open([]byte("Jane Doe"))
+	assert.Equal(t, ErrNotSealed, err)
+}
+
+func TestRekeying__Given__DataOfEitherKeyOrClear__Expect__SealedWithTheNewKey(t *testing.T) {
+	from, to := testCipher(t), testCipher(t)
+	rekeying := Rekeying(from, to)
+
+	for _, data := range [][]byte{from.Seal([]byte("a")), to.Seal([]byte("a")), []byte("a")} {
+		plain, err := rekeying.Open(data)
+		assert.Nil(t, err)
+
+		resealed, _ := to.Open(rekeying.Seal(plain))
+		assert.Equal(t, "a", string(resealed))
+	}
+
+	assert.Equal(t, []byte("a"), Rekeying(from, nil).Seal([]byte("a")))
+}
+
+func TestParseKey__Given__HexBase64OrShortKey__Expect__OnlyFullKeysAccepted(t *testing.T) {
+	_, err := ParseKey("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f\n")
+	assert.Nil(t, err)
+
+	_, err = ParseKey("AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=")
+	assert.Nil(t, err)
+
+	_, err = ParseKey("secret")
+	assert.NotNil(t, err)
+}
+
+func TestLoadKey__Given__KeyInEnvironment__Expect__Cipher(t *testing.T) {
+	text, _ := GenerateKey()
+	_ = os.Setenv("EVHUB_TEST_KEY", text)
+	defer os.Unsetenv("EVHUB_TEST_KEY")
+
+	cipher, err := LoadKey("", "EVHUB_TEST_KEY")
+	assert.Nil(t, err)
+	assert.NotNil(t, cipher)
+
+	cipher, err = LoadKey("", "")
+	assert.Nil(t, err)
+	assert.Nil(t, cipher)
+
+	_, err = LoadKey("", "EVHUB_TEST_MISSING_KEY")
+	assert.NotNil(t, err)
+}
+
+func TestGuard__Given__SealedStore__Expect__MissingOrWrongKeyRefused(t *testing.T) {
+	folder, _ := ioutil.TempDir("", "atRest")
+	defer os.RemoveAll(folder)
+
+	cipher := testCipher(t)
+
+	assert.Equal(t, ErrPlainStore, Check(folder, cipher))
+	assert.Nil(t, Guard(folder, cipher, false))
+	assert.Nil(t, Guard(folder, cipher, true))
+
+	assert.ErrorIs(t, Guard(folder, nil, true), ErrKeyRequired)
+	assert.ErrorIs(t, Guard(folder, testCipher(t), true), ErrWrongKey)
+}
+
+func TestGuard__Given__ClearStoreWithData__Expect__KeyRefused(t *testing.T) {
+	folder, _ := ioutil.TempDir("", "atRest")
+	defer os.RemoveAll(folder)
+
+	assert.Nil(t, Guard(folder, nil, true))
+	assert.ErrorIs(t, Guard(folder, testCipher(t), true), ErrPlainStore)
+}
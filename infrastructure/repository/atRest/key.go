@@ -0,0 +1,153 @@
+package atRest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MarkerFile is kept in the folder of an encrypted store, it tells which key the store was sealed with
+const MarkerFile = "encryption.check"
+
+var markerText = []byte("event hub at-rest encryption")
+
+// ErrPlainStore is returned when a key is configured for a store which already holds unencrypted data
+var ErrPlainStore = errors.New("the store holds unencrypted data, run the rekey command to encrypt it")
+
+// ParseKey reads a key written as 64 hexadecimal characters or as base64
+func ParseKey(text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+
+	if key, err := hex.DecodeString(text); nil == err && len(key) == KeySize {
+		return key, nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(text); nil == err && len(key) == KeySize {
+		return key, nil
+	}
+
+	return nil, errors.New("encryption key must be 32 bytes written as hex or base64")
+}
+
+// GenerateKey returns a new random key written as hex
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(key), nil
+}
+
+func ReadKeyFile(fileName string) ([]byte, error) {
+	text, err := ioutil.ReadFile(fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParseKey(string(text))
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fileName, err.Error())
+	}
+
+	return key, nil
+}
+
+// LoadKey returns the cipher of the key in keyFile, or else in the environment variable keyEnv.
+// Neither set means no encryption, a variable which is named but empty is an error.
+func LoadKey(keyFile string, keyEnv string) (*Cipher, error) {
+	var key []byte
+	var err error
+
+	if "" != keyFile {
+		key, err = ReadKeyFile(keyFile)
+	} else if "" != keyEnv {
+		if text := os.Getenv(keyEnv); "" == text {
+			err = fmt.Errorf("environment variable %s holds no encryption key", keyEnv)
+		} else if key, err = ParseKey(text); err != nil {
+			err = fmt.Errorf("%s: %s", keyEnv, err.Error())
+		}
+	} else {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCipher(key)
+}
+
+// Check compares c with the key the store in folder was sealed with.
+// A store without marker is unencrypted, it checks only without key.
+func Check(folder string, c *Cipher) error {
+	marker, err := ioutil.ReadFile(filepath.Join(folder, MarkerFile))
+
+	if os.IsNotExist(err) {
+		if nil == c {
+			return nil
+		}
+
+		return ErrPlainStore
+	} else if err != nil {
+		return err
+	}
+
+	if nil == c {
+		return ErrKeyRequired
+	}
+
+	_, err = c.Open(marker)
+
+	return err
+}
+
+// Guard refuses to open the store in folder with a key it was not sealed with.
+// A key configured for an empty store seals it from now on.
+func Guard(folder string, c *Cipher, hasData bool) error {
+	err := Check(folder, c)
+
+	if ErrPlainStore == err && !hasData {
+		err = WriteMarker(folder, c)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", folder, err)
+	}
+
+	return nil
+}
+
+// WriteMarker records the key the store in folder is sealed with, no key removes the marker
+func WriteMarker(folder string, c *Cipher) error {
+	fileName := filepath.Join(folder, MarkerFile)
+
+	if nil == c {
+		if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+
+	tmpName := fileName + ".tmp"
+
+	if err := ioutil.WriteFile(tmpName, c.Seal(markerText), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, fileName)
+}
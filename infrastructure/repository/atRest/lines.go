@@ -0,0 +1,108 @@
+package atRest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"os"
+)
+
+// Encrypted is implemented by connections which seal their store, files kept next to the store are sealed alike
+type Encrypted interface {
+	Cipher() *Cipher
+}
+
+// CipherOf returns the cipher of connection, nil when it does not encrypt at rest
+func CipherOf(connection interface{}) *Cipher {
+	if encrypted, ok := connection.(Encrypted); ok {
+		return encrypted.Cipher()
+	}
+
+	return nil
+}
+
+// SealLine seals plain for a file of lines, sealed data is written as base64 so it holds no newline
+func (c *Cipher) SealLine(plain []byte) []byte {
+	if nil == c || c.sealPlain {
+		return plain
+	}
+
+	return []byte(base64.StdEncoding.EncodeToString(c.Seal(plain)))
+}
+
+// OpenLine returns the plain form of a line written by SealLine.
+// A clear line is refused when c has a key, the file has to be rekeyed first.
+func (c *Cipher) OpenLine(line []byte) ([]byte, error) {
+	if sealed, err := base64.StdEncoding.DecodeString(string(line)); nil == err && IsSealed(sealed) {
+		return c.Open(sealed)
+	}
+
+	plain, err := c.Open(line)
+
+	if ErrNotSealed == err {
+		return nil, ErrPlainStore
+	}
+
+	return plain, err
+}
+
+// RekeyLines rewrites the file of lines fileName sealed like to, a line it can not open stops it before the file is replaced.
+// Lines sealed with from, with to or in the clear are opened, so an interrupted rekey can be run again.
+// A line torn by a crash at the end of the file is dropped.
+func RekeyLines(fileName string, from *Cipher, to *Cipher) error {
+	rekeying := Rekeying(from, to)
+
+	fp, err := os.Open(fileName)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = fp.Close() }()
+
+	tmpName := fileName + ".rekey"
+	out, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(fp)
+	writer := bufio.NewWriter(out)
+
+	for nil == err {
+		var line []byte
+
+		if line, err = reader.ReadBytes('\n'); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			break
+		}
+
+		var plain []byte
+
+		if plain, err = rekeying.OpenLine(line[:len(line)-1]); nil == err {
+			_, err = writer.Write(append(rekeying.SealLine(plain), '\n'))
+		}
+	}
+
+	if nil == err {
+		err = writer.Flush()
+	}
+
+	if nil == err {
+		err = out.Sync()
+	}
+
+	if closeErr := out.Close(); nil == err {
+		err = closeErr
+	}
+
+	if nil != err {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, fileName)
+}
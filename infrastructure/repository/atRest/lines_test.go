@@ -0,0 +1,60 @@
+package atRest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSealLine__Given__Cipher__Expect__OneLineOpenedWithTheSameKeyOnly(t *testing.T) {
+	cipher := testCipher(t)
+	line := cipher.SealLine([]byte(`{"name":"Jane Doe"}`))
+
+	assert.NotContains(t, string(line), "\n")
+	assert.NotContains(t, string(line), "Jane Doe")
+
+	plain, err := cipher.OpenLine(line)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"Jane Doe"}`, string(plain))
+
+	_, err = testCipher(t).OpenLine(line)
+	assert.Equal(t, ErrWrongKey, err)
+
+	_, err = cipher.OpenLine([]byte(`{"name":"Jane Doe"}`))
+	assert.Equal(t, ErrPlainStore, err)
+
+	var none *Cipher
+	plain, err = none.OpenLine([]byte(`{"name":"Jane Doe"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"Jane Doe"}`, string(plain))
+}
+
+func TestRekeyLines__Given__ClearAndSealedLines__Expect__AllSealedWithTheNewKey(t *testing.T) {
+	folder, _ := ioutil.TempDir("", "rekeyLines")
+	defer func() { _ = os.RemoveAll(folder) }()
+
+	from, to := testCipher(t), testCipher(t)
+	fileName := filepath.Join(folder, "guests.events")
+	content := "{\"seq\":1}\n" + string(from.SealLine([]byte(`{"seq":2}`))) + "\n" + "{\"seq\":3"
+	assert.Nil(t, ioutil.WriteFile(fileName, []byte(content), 0600))
+
+	assert.Nil(t, RekeyLines(fileName, from, to))
+
+	rekeyed, _ := ioutil.ReadFile(fileName)
+	lines := strings.Split(strings.TrimSuffix(string(rekeyed), "\n"), "\n")
+	assert.Len(t, lines, 2)
+
+	for idx, line := range lines {
+		plain, err := to.OpenLine([]byte(line))
+		assert.Nil(t, err)
+		assert.Equal(t, `{"seq":`+string(rune('1'+idx))+`}`, string(plain))
+	}
+
+	info, _ := os.Stat(fileName)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	assert.Equal(t, ErrWrongKey, RekeyLines(fileName, testCipher(t), testCipher(t)))
+}
@@ -0,0 +1,164 @@
+package boltDb
+
+import (
+	"context"
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/dbFormat"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const FileName = "event-hub.bolt"
+
+var (
+	formatBucket = []byte("_format")
+	formatKey    = []byte("version")
+)
+
+// Options of the "bolt" storage adapter
+type Options struct {
+	Folder string
+}
+
+// Connection keeps every repository in a single bbolt file, each repository owns an item and an activity bucket
+type Connection struct {
+	dbFile string
+	db     *bbolt.DB
+	mu     sync.Mutex
+	open   int
+}
+
+func NewBoltDbConnection(dbFolder string) (*Connection, error) {
+	if err := os.MkdirAll(dbFolder, 0755); err != nil {
+		return nil, err
+	}
+
+	dbFile := filepath.Join(dbFolder, FileName)
+	db, err := openDb(dbFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Connection{
+		dbFile: dbFile,
+		db:     db,
+	}, nil
+}
+
+// openDb opens the file and checks its format version
+func openDb(dbFile string) (*bbolt.DB, error) {
+	// the timeout fails fast when another hub process holds the file
+	db, err := bbolt.Open(dbFile, 0600, &bbolt.Options{Timeout: 2 * time.Second})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkFormat(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// checkFormat stamps a new file with the current format version and refuses files written by a newer hub
+func checkFormat(db *bbolt.DB) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(formatBucket)
+
+		if err != nil {
+			return err
+		}
+
+		if stored := bucket.Get(formatKey); nil != stored {
+			version, err := strconv.Atoi(string(stored))
+
+			if err != nil {
+				return fmt.Errorf("boltDb: unreadable format version %q", stored)
+			}
+
+			if _, err := dbFormat.Pending(version); err != nil {
+				return fmt.Errorf("boltDb: %s", err.Error())
+			}
+
+			// the bolt layout has not changed since versioning, nothing to migrate yet
+			if version == dbFormat.CurrentVersion {
+				return nil
+			}
+		}
+
+		return bucket.Put(formatKey, []byte(strconv.Itoa(dbFormat.CurrentVersion)))
+	})
+}
+
+func (c *Connection) InitRepository(name string) (scanItem.RepositoryInterface, error) {
+	return scanItem.LegacyOpen(c.OpenRepository(context.Background(), name))
+}
+
+func (c *Connection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	if err := scanItem.CheckContext(ctx, name, "open"); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the file was closed with the last repository, a later open starts it again
+	if nil == c.db {
+		db, err := openDb(c.dbFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.db = db
+	}
+
+	repo := &ScanItemRepository{
+		repoName:       name,
+		itemBucket:     []byte(name + "_item"),
+		activityBucket: []byte(name + "_activity"),
+		conn:           c,
+		db:             c.db,
+	}
+
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(repo.itemBucket); err != nil {
+			return err
+		}
+
+		_, err := tx.CreateBucketIfNotExists(repo.activityBucket)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.open += 1
+
+	return repo, nil
+}
+
+// release closes the file once the last repository is closed
+func (c *Connection) release() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.open -= 1; c.open > 0 {
+		return nil
+	}
+
+	db := c.db
+	c.db = nil
+
+	return db.Close()
+}
@@ -0,0 +1,404 @@
+package boltDb
+
+import (
+	"context"
+	"encoding/json"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"go.etcd.io/bbolt"
+	"sync"
+)
+
+type ScanItemRepository struct {
+	repoName       string
+	itemBucket     []byte
+	activityBucket []byte
+	conn           *Connection
+	db             *bbolt.DB
+	closeOnce      sync.Once
+}
+
+type boltItem struct {
+	Data  map[string]string
+	Local map[string]scanItem.LocalField `json:",omitempty"`
+}
+
+func (r *ScanItemRepository) NewItem(ctx context.Context, itemKey string, data map[string]string) (*scanItem.ScanItem, error) {
+	item, err := scanItem.NewScanItem(itemKey, data)
+
+	if nil != err {
+		return nil, err
+	}
+
+	if err := r.SetItem(ctx, item); nil != err {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (r *ScanItemRepository) SetItem(ctx context.Context, item *scanItem.ScanItem) error {
+	if err := scanItem.CheckContext(ctx, r.repoName, "SetItem"); nil != err {
+		return err
+	}
+
+	value, err := json.Marshal(boltItem{Data: item.GetData(), Local: item.Local})
+
+	if nil == err {
+		err = r.db.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket(r.itemBucket).Put([]byte(item.GetKey()), value)
+		})
+	}
+
+	return r.storageError("SetItem", err)
+}
+
+func (r *ScanItemRepository) GetItem(ctx context.Context, key string) (*scanItem.ScanItem, bool, error) {
+	if err := scanItem.CheckContext(ctx, r.repoName, "GetItem"); nil != err {
+		return nil, false, err
+	}
+
+	var item *scanItem.ScanItem
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		item, err = r.readItem(tx, key)
+		return err
+	})
+
+	if nil != err {
+		return nil, false, r.storageError("GetItem", err)
+	}
+
+	return item, nil != item, nil
+}
+
+func (r *ScanItemRepository) GetItemDetail(ctx context.Context, itemKey string) (*scanItem.ItemDetail, bool, error) {
+	if err := scanItem.CheckContext(ctx, r.repoName, "GetItemDetail"); nil != err {
+		return nil, false, err
+	}
+
+	var detail *scanItem.ItemDetail
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		item, err := r.readItem(tx, itemKey)
+
+		if nil != err || nil == item {
+			return err
+		}
+
+		activities, err := r.readActivities(tx, itemKey)
+		detail = &scanItem.ItemDetail{ScanItem: *item, Activities: activities}
+
+		return err
+	})
+
+	if nil != err {
+		return nil, false, r.storageError("GetItemDetail", err)
+	}
+
+	return detail, nil != detail, nil
+}
+
+func (r *ScanItemRepository) Items(ctx context.Context) ([]*scanItem.ScanItem, error) {
+	var result []*scanItem.ScanItem
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(r.itemBucket).ForEach(func(key []byte, value []byte) error {
+			if err := ctx.Err(); nil != err {
+				return err
+			}
+
+			item, err := decodeItem(key, value)
+
+			if nil == err {
+				result = append(result, item)
+			}
+
+			return err
+		})
+	})
+
+	if nil != err {
+		return nil, r.storageError("Items", err)
+	}
+
+	return result, nil
+}
+
+// Query walks the item bucket in key order from the cursor and stops once the page is full, when the query sorts by key.
+// Filters still need every item to count the total, other sorts load every item.
+func (r *ScanItemRepository) Query(ctx context.Context, query scanItem.Query) (scanItem.QueryResult, error) {
+	if !query.KeyOrdered() {
+		return scanItem.RunQuery(ctx, r, query)
+	}
+
+	if err := scanItem.CheckContext(ctx, r.repoName, "Query"); nil != err {
+		return scanItem.QueryResult{}, err
+	}
+
+	pager, err := scanItem.NewKeyPager(query)
+
+	if nil != err {
+		return scanItem.QueryResult{}, err
+	}
+
+	seek := ""
+
+	// items before the cursor are counted by a filtered query
+	if !query.Filters() {
+		seek, _ = query.SeekKey()
+	}
+
+	var count int
+
+	err = r.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(r.itemBucket)
+
+		if !query.Filters() {
+			count = bucket.Stats().KeyN
+		}
+
+		cursor := bucket.Cursor()
+
+		for key, value := seekItem(cursor, seek, query.SortDesc); nil != key; key, value = nextItem(cursor, query.SortDesc) {
+			if err := ctx.Err(); nil != err {
+				return err
+			}
+
+			item, err := decodeItem(key, value)
+
+			if nil != err {
+				return err
+			}
+
+			more, err := pager.Offer(item, func(itemKey string) (*scanItem.ItemActivities, error) {
+				activities, err := r.readActivities(tx, itemKey)
+				return &scanItem.ItemActivities{Key: itemKey, Activities: activities}, err
+			})
+
+			if nil != err || !more {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if nil != err {
+		return scanItem.QueryResult{}, r.storageError("Query", err)
+	}
+
+	return pager.Result(count), nil
+}
+
+// seekItem positions cursor on the first item after the key after, in descending order when desc
+func seekItem(cursor *bbolt.Cursor, after string, desc bool) ([]byte, []byte) {
+	if "" == after && desc {
+		return cursor.Last()
+	} else if "" == after {
+		return cursor.First()
+	}
+
+	key, value := cursor.Seek([]byte(after))
+
+	switch {
+	case desc && nil == key:
+		return cursor.Last()
+	case desc:
+		return cursor.Prev()
+	case nil != key && string(key) == after:
+		return cursor.Next()
+	}
+
+	return key, value
+}
+
+func nextItem(cursor *bbolt.Cursor, desc bool) ([]byte, []byte) {
+	if desc {
+		return cursor.Prev()
+	}
+
+	return cursor.Next()
+}
+
+func (r *ScanItemRepository) Len(ctx context.Context) (int, error) {
+	if err := scanItem.CheckContext(ctx, r.repoName, "Len"); nil != err {
+		return 0, err
+	}
+
+	var count int
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		count = tx.Bucket(r.itemBucket).Stats().KeyN
+		return nil
+	})
+
+	return count, r.storageError("Len", err)
+}
+
+//////////////////////
+
+// AddItemActivity reads and writes the activities in one transaction,
+// so concurrent scans of the same key can not lose an activity
+func (r *ScanItemRepository) AddItemActivity(ctx context.Context, itemKey string, activity scanItem.ItemActivity) (*scanItem.ItemActivities, error) {
+	if err := scanItem.CheckContext(ctx, r.repoName, "AddItemActivity"); nil != err {
+		return nil, err
+	}
+
+	var result *scanItem.ItemActivities
+
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		if item, err := r.readItem(tx, itemKey); nil != err || nil == item {
+			return err
+		}
+
+		activities, err := r.readActivities(tx, itemKey)
+
+		if nil != err {
+			return err
+		}
+
+		activities = append([]scanItem.ItemActivity{activity}, activities...)
+
+		if err := r.writeActivities(tx, itemKey, activities); nil != err {
+			return err
+		}
+
+		result = &scanItem.ItemActivities{Key: itemKey, Activities: activities}
+
+		return nil
+	})
+
+	if nil != err {
+		return nil, r.storageError("AddItemActivity", err)
+	}
+
+	return result, nil
+}
+
+func (r *ScanItemRepository) SetActivitySync(ctx context.Context, itemKey string, activityId string, sync scanItem.ActivitySync) (bool, error) {
+	if err := scanItem.CheckContext(ctx, r.repoName, "SetActivitySync"); nil != err {
+		return false, err
+	}
+
+	updated := false
+
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		activities, err := r.readActivities(tx, itemKey)
+
+		if nil != err {
+			return err
+		}
+
+		for idx := range activities {
+			if activities[idx].Id == activityId {
+				activities[idx].Sync = sync
+				updated = true
+
+				return r.writeActivities(tx, itemKey, activities)
+			}
+		}
+
+		return nil
+	})
+
+	if nil != err {
+		return false, r.storageError("SetActivitySync", err)
+	}
+
+	return updated, nil
+}
+
+func (r *ScanItemRepository) GetItemActivities(ctx context.Context, itemKey string) (*scanItem.ItemActivities, error) {
+	if err := scanItem.CheckContext(ctx, r.repoName, "GetItemActivities"); nil != err {
+		return nil, err
+	}
+
+	var result *scanItem.ItemActivities
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		if item, err := r.readItem(tx, itemKey); nil != err || nil == item {
+			return err
+		}
+
+		activities, err := r.readActivities(tx, itemKey)
+		result = &scanItem.ItemActivities{Key: itemKey, Activities: activities}
+
+		return err
+	})
+
+	if nil != err {
+		return nil, r.storageError("GetItemActivities", err)
+	}
+
+	return result, nil
+}
+
+func (r *ScanItemRepository) GetRepoName() string {
+	return r.repoName
+}
+
+func (r *ScanItemRepository) CloseDb(ctx context.Context) error {
+	var err error
+
+	r.closeOnce.Do(func() {
+		err = r.storageError("CloseDb", r.conn.release())
+	})
+
+	return err
+}
+
+func (r *ScanItemRepository) readItem(tx *bbolt.Tx, key string) (*scanItem.ScanItem, error) {
+	value := tx.Bucket(r.itemBucket).Get([]byte(key))
+
+	if nil == value {
+		return nil, nil
+	}
+
+	return decodeItem([]byte(key), value)
+}
+
+func (r *ScanItemRepository) readActivities(tx *bbolt.Tx, itemKey string) ([]scanItem.ItemActivity, error) {
+	var activities []scanItem.ItemActivity
+
+	if value := tx.Bucket(r.activityBucket).Get([]byte(itemKey)); nil != value {
+		if err := json.Unmarshal(value, &activities); nil != err {
+			return nil, err
+		}
+	}
+
+	return activities, nil
+}
+
+func (r *ScanItemRepository) writeActivities(tx *bbolt.Tx, itemKey string, activities []scanItem.ItemActivity) error {
+	value, err := json.Marshal(activities)
+
+	if nil != err {
+		return err
+	}
+
+	return tx.Bucket(r.activityBucket).Put([]byte(itemKey), value)
+}
+
+// storageError wraps an error of bbolt or of decoding, nil stays nil
+func (r *ScanItemRepository) storageError(operation string, err error) error {
+	if nil == err {
+		return nil
+	}
+
+	return scanItem.NewStorageError(r.repoName, operation, err)
+}
+
+func decodeItem(key []byte, value []byte) (*scanItem.ScanItem, error) {
+	var stored boltItem
+
+	if err := json.Unmarshal(value, &stored); nil != err {
+		return nil, err
+	}
+
+	if nil == stored.Data {
+		stored.Data = make(map[string]string)
+	}
+
+	return &scanItem.ScanItem{Key: string(key), Data: stored.Data, Local: stored.Local}, nil
+}
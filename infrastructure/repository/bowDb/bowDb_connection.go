@@ -1,23 +1,37 @@
 package bowDb
 
 import (
+	"context"
 	"fmt"
 	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
 	"github.com/dgraph-io/badger"
 	"github.com/zippoxer/bow"
+	"sync"
 )
 
+// Options of the "bow" storage adapter
+type Options struct {
+	Folder            string
+	EncryptionKeyFile string
+	EncryptionKeyEnv  string
+}
+
+// Connection shares one badger database between the repositories of a folder,
+// it is closed with the last repository and opened again by the next one
 type Connection struct {
 	dbFolder string
 	conn     *bow.DB
+	options  []bow.Option
+	cipher   *atRest.Cipher
+	mu       sync.Mutex
+	open     int
+	// activity lists are read, changed and written back, the writers of every repository take turns like bolt's
+	activityLock sync.Mutex
 }
 
 func NewBowDbConnection(dbFolder string) *Connection {
-	// Open database under directory "test".
-	retryOpts := badger.DefaultOptions(dbFolder)
-	retryOpts.Truncate = true
-
-	conn, err := bow.Open(dbFolder, bow.SetBadgerOptions(retryOpts))
+	conn, err := openBow(dbFolder)
 
 	if err != nil {
 		fmt.Println(err.Error())
@@ -30,9 +44,72 @@ func NewBowDbConnection(dbFolder string) *Connection {
 	}
 }
 
+func openBow(dbFolder string, options ...bow.Option) (*bow.DB, error) {
+	retryOpts := badger.DefaultOptions(dbFolder)
+	retryOpts.Truncate = true
+
+	return bow.Open(dbFolder, append([]bow.Option{bow.SetBadgerOptions(retryOpts)}, options...)...)
+}
+
 func (c *Connection) InitRepository(name string) (scanItem.RepositoryInterface, error) {
-	return &ScanItemRepository{
-		repoName: name,
-		conn:     c.conn,
-	}, nil
+	return scanItem.LegacyOpen(c.OpenRepository(context.Background(), name))
+}
+
+func (c *Connection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	if err := scanItem.CheckContext(ctx, name, "open"); err != nil {
+		return nil, err
+	}
+
+	if err := scanItem.CheckRepoName(name); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the database was closed with the last repository, a later open starts it again
+	if nil == c.conn {
+		conn, err := openBow(c.dbFolder, c.options...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.conn = conn
+	}
+
+	repo := &ScanItemRepository{
+		repoName:     name,
+		conn:         c.conn,
+		connection:   c,
+		activityLock: &c.activityLock,
+	}
+
+	if err := repo.migrate(); err != nil {
+		if 0 == c.open {
+			_ = c.conn.Close()
+			c.conn = nil
+		}
+
+		return nil, err
+	}
+
+	c.open += 1
+
+	return repo, nil
+}
+
+// release closes the database once the last repository is closed
+func (c *Connection) release() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.open -= 1; c.open > 0 {
+		return nil
+	}
+
+	conn := c.conn
+	c.conn = nil
+
+	return conn.Close()
 }
\ No newline at end of file
@@ -1,11 +1,15 @@
 package bowDb_test
 
 import (
+	"context"
+	"errors"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
 	"git.anphabe.net/event/anphabe-event-hub/domain/service"
 
-	"math/rand"
+	"io/ioutil"
+	"os"
 	"strconv"
-	"time"
+	"sync"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -15,8 +19,22 @@ import (
 
 var _ = Describe("BowDbConnection", func() {
 
+	var folder string
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "bowDb")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
 	Context("Call to NewBowDbConnection() to create a *bowDb.Connection", func() {
-		connection := setupDb()
+		var connection *Connection
+
+		BeforeEach(func() {
+			connection = NewBowDbConnection(folder)
+		})
 
 		It("connection must be *bowDb.Connection", func() {
 			Expect(connection).To(BeAssignableToTypeOf((*Connection)(nil)))
@@ -28,12 +46,24 @@ var _ = Describe("BowDbConnection", func() {
 	})
 
 	Context("given *bowDb.Connection created", func() {
-		sut := setupDb()
+		ctx := context.Background()
 		repoName := "testRepo"
+		var sut *Connection
+
+		BeforeEach(func() {
+			sut = NewBowDbConnection(folder)
+		})
 
-		Context("calling to InitRepository("+`"`+repoName+`"`+") to create a *ScanItemRepository", func() {
+		Context("calling to OpenRepository("+`"`+repoName+`"`+") to create a *ScanItemRepository", func() {
+			var repository scanItem.RepositoryInterfaceV2
+
+			BeforeEach(func() {
+				repository, _ = sut.OpenRepository(ctx, repoName)
+			})
 
-			repository, _ := sut.InitRepository(repoName)
+			AfterEach(func() {
+				_ = repository.CloseDb(ctx)
+			})
 
 			It("repository must be *bowDb.ScanItemRepository", func() {
 				Expect(repository).To(BeAssignableToTypeOf((*ScanItemRepository)(nil)))
@@ -43,11 +73,105 @@ var _ = Describe("BowDbConnection", func() {
 				Expect(repository.GetRepoName()).To(Equal(repoName))
 			})
 		})
+
+		Context("calling to OpenRepository() with a reserved name", func() {
+			It("the repository is refused", func() {
+				_, err := sut.OpenRepository(ctx, scanItem.ReservedPrefix+"format")
+
+				Expect(errors.Is(err, scanItem.ErrReservedName)).To(BeTrue())
+			})
+		})
+
+		Context("calling to OpenRepository(\"format\") next to other repositories", func() {
+			It("the repository holds its items only", func() {
+				format, _ := sut.OpenRepository(ctx, "format")
+				guests, _ := sut.OpenRepository(ctx, "guests")
+				_, _ = guests.NewItem(ctx, "1", nil)
+
+				Expect(format.Len(ctx)).To(Equal(0))
+
+				_ = guests.CloseDb(ctx)
+				_ = format.CloseDb(ctx)
+			})
+		})
+	})
+
+	Context("given sources sharing the folder through a registry", func() {
+		ctx := context.Background()
+		var registry service.RepositoryRegistryInterface
+
+		BeforeEach(func() {
+			registry = service.NewRepositoryRegistry(NewBowDbConnection(folder))
+		})
+
+		AfterEach(func() {
+			registry.Shutdown()
+		})
+
+		It("different repositories open concurrently", func() {
+			connection := NewBowDbConnection(folder + "/concurrent")
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			errs := make([]error, 32)
+
+			for idx := range errs {
+				wg.Add(1)
+				go func(idx int) {
+					defer wg.Done()
+					<-start
+
+					repo, err := connection.OpenRepository(ctx, "source"+strconv.Itoa(idx))
+
+					if nil == err {
+						err = repo.CloseDb(ctx)
+					}
+
+					errs[idx] = err
+				}(idx)
+			}
+
+			close(start)
+			wg.Wait()
+
+			for _, err := range errs {
+				Expect(err).To(BeNil())
+			}
+		})
+
+		It("removing one source leaves the others writable and readable", func() {
+			guests, err := registry.GetRepositoryV2(ctx, "guests")
+			Expect(err).To(BeNil())
+			_, err = registry.GetRepositoryV2(ctx, "vip")
+			Expect(err).To(BeNil())
+
+			Expect(registry.RemoveRepository("vip")).To(BeTrue())
+
+			_, err = guests.NewItem(ctx, "1", map[string]string{"name": "Jane"})
+			Expect(err).To(BeNil())
+
+			item, found, err := guests.GetItem(ctx, "1")
+			Expect(err).To(BeNil())
+			Expect(found).To(BeTrue())
+			Expect(item.Data["name"]).To(Equal("Jane"))
+
+			By("the database closed with the last repository is opened again by the next one")
+			Expect(registry.RemoveRepository("guests")).To(BeTrue())
+
+			reopened, err := registry.GetRepositoryV2(ctx, "guests")
+			Expect(err).To(BeNil())
+
+			item, found, err = reopened.GetItem(ctx, "1")
+			Expect(err).To(BeNil())
+			Expect(found).To(BeTrue())
+			Expect(item.Data["name"]).To(Equal("Jane"))
+		})
 	})
 })
 
+// setupDb opens a store in a folder of its own. The specs building their repositories
+// while the spec tree is built can not clean up after each spec, their folders go with the suite.
 func setupDb() *Connection {
-	rand.Seed(time.Now().UnixNano())
+	folder, _ := ioutil.TempDir(suiteFolder, "bow_data_")
 
-	return NewBowDbConnection("./bow_data_" + strconv.Itoa(rand.Int()))
-}
\ No newline at end of file
+	return NewBowDbConnection(folder)
+}
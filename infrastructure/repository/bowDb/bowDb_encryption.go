@@ -0,0 +1,231 @@
+package bowDb
+
+import (
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"github.com/dgraph-io/badger"
+	"github.com/zippoxer/bow"
+	"github.com/zippoxer/bow/codec"
+	jsoncodec "github.com/zippoxer/bow/codec/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// sealedCodec encrypts the records bow writes, keys stay in the clear
+type sealedCodec struct {
+	codec  codec.Codec
+	cipher *atRest.Cipher
+}
+
+func (c sealedCodec) Marshal(v interface{}, in []byte) ([]byte, error) {
+	data, err := c.codec.Marshal(v, in)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c.cipher.Seal(data), nil
+}
+
+func (c sealedCodec) Unmarshal(data []byte, v interface{}) error {
+	plain, err := c.cipher.Open(data)
+
+	if err != nil {
+		return err
+	}
+
+	return c.codec.Unmarshal(plain, v)
+}
+
+func (c sealedCodec) Format() codec.Format {
+	return c.codec.Format()
+}
+
+// NewEncryptedBowDbConnection opens a store whose records are sealed with cipher, no cipher opens it in the clear.
+// A store sealed with another key, or with a key while none is given, is refused.
+func NewEncryptedBowDbConnection(dbFolder string, cipher *atRest.Cipher) (*Connection, error) {
+	if err := atRest.Guard(dbFolder, cipher, hasData(dbFolder)); err != nil {
+		return nil, fmt.Errorf("bowDb: %w", err)
+	}
+
+	options := []bow.Option{bow.SetCodec(sealedCodec{codec: jsoncodec.Codec{}, cipher: cipher})}
+	conn, err := openBow(dbFolder, options...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Connection{
+		dbFolder: dbFolder,
+		conn:     conn,
+		options:  options,
+		cipher:   cipher,
+	}, nil
+}
+
+// Cipher is the cipher the records are sealed with, nil when they are kept in the clear
+func (c *Connection) Cipher() *atRest.Cipher {
+	return c.cipher
+}
+
+// hasData tells whether the folder holds a badger database
+func hasData(dbFolder string) bool {
+	_, err := os.Stat(filepath.Join(dbFolder, badger.ManifestFilename))
+
+	return nil == err
+}
+
+// Rekey seals every record of the store in dbFolder with to, no key leaves them in the clear.
+// The store must be sealed with from, or with to when an interrupted rekey is run again.
+// Badger keeps overwritten values in its logs, so the records are copied into a new database
+// which then replaces the files of the old one.
+func Rekey(dbFolder string, from *atRest.Cipher, to *atRest.Cipher) error {
+	if err := atRest.Check(dbFolder, from); err != nil && atRest.Check(dbFolder, to) != nil {
+		return fmt.Errorf("bowDb: %s: %w", dbFolder, err)
+	}
+
+	dbFolder = filepath.Clean(dbFolder)
+	copyFolder, oldFolder := dbFolder+".rekey", dbFolder+".old"
+
+	if _, err := os.Stat(filepath.Join(copyFolder, copyDone)); err != nil {
+		if err := rollbackSwap(dbFolder, copyFolder, oldFolder); err != nil {
+			return err
+		}
+
+		if err := copyRecords(dbFolder, copyFolder, atRest.Rekeying(from, to)); err != nil {
+			return fmt.Errorf("bowDb: %s: %w", dbFolder, err)
+		}
+	}
+
+	if err := moveFiles(dbFolder, oldFolder); err != nil {
+		return err
+	}
+
+	if err := moveFiles(copyFolder, dbFolder); err != nil {
+		return err
+	}
+
+	if err := atRest.WriteMarker(dbFolder, to); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(oldFolder); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(copyFolder)
+}
+
+// copyDone is written once the new database is complete, from then on a rerun only finishes the swap
+const copyDone = "REKEY_DONE"
+
+// rollbackSwap puts back the files of a rekey interrupted before its copy was complete
+func rollbackSwap(dbFolder string, copyFolder string, oldFolder string) error {
+	if err := os.RemoveAll(copyFolder); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(oldFolder); os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := moveFiles(oldFolder, dbFolder); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(oldFolder)
+}
+
+// copyRecords writes every key of the database in dbFolder into a new database in copyFolder,
+// the values of bucket records are resealed, the keys bow reserves for itself start with a zero byte
+func copyRecords(dbFolder string, copyFolder string, rekeying *atRest.Cipher) error {
+	source, err := openBow(dbFolder)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = source.Close() }()
+
+	target, err := badger.Open(badger.DefaultOptions(copyFolder))
+
+	if err != nil {
+		return err
+	}
+
+	batch := target.NewWriteBatch()
+	defer batch.Cancel()
+
+	err = source.Badger().View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			item := iter.Item()
+			value, err := item.ValueCopy(nil)
+
+			if err != nil {
+				return err
+			}
+
+			if 0 != item.Key()[0] {
+				plain, err := rekeying.Open(value)
+
+				if err != nil {
+					return err
+				}
+
+				value = rekeying.Seal(plain)
+			}
+
+			if err := batch.Set(item.KeyCopy(nil), value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if nil == err {
+		err = batch.Flush()
+	}
+
+	if closeErr := target.Close(); nil == err {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(copyFolder, copyDone), nil, 0600)
+}
+
+// moveFiles moves the database files of from into to.
+// Sub folders, such as the event logs kept next to the database, and the key marker stay where they are.
+func moveFiles(from string, to string) error {
+	files, err := ioutil.ReadDir(from)
+
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(to, 0755); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || atRest.MarkerFile == file.Name() || copyDone == file.Name() {
+			continue
+		}
+
+		if err := os.Rename(filepath.Join(from, file.Name()), filepath.Join(to, file.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
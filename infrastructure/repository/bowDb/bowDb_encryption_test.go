@@ -0,0 +1,118 @@
+package bowDb_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/bowDb"
+)
+
+func newTestCipher() *atRest.Cipher {
+	text, _ := atRest.GenerateKey()
+	key, _ := atRest.ParseKey(text)
+	cipher, _ := atRest.NewCipher(key)
+
+	return cipher
+}
+
+var _ = Describe("BowDb at-rest encryption", func() {
+	var folder string
+	var cipher *atRest.Cipher
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "bowDbEncryption")
+		cipher = newTestCipher()
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	storeGuest := func(cipher *atRest.Cipher) {
+		connection, err := NewEncryptedBowDbConnection(folder, cipher)
+		Expect(err).To(BeNil())
+
+		repo, err := connection.OpenRepository(context.Background(), "guests")
+		Expect(err).To(BeNil())
+		defer repo.CloseDb(context.Background())
+
+		item, _ := scanItem.NewScanItem("1a", map[string]string{"name": "Jane Doe"})
+		Expect(repo.SetItem(context.Background(), item)).To(Succeed())
+		_, err = repo.AddItemActivity(context.Background(), "1a", scanItem.NewActivity("checkin", map[string]string{"gateway": "gate1"}))
+		Expect(err).To(BeNil())
+	}
+
+	expectGuest := func(cipher *atRest.Cipher) {
+		connection, err := NewEncryptedBowDbConnection(folder, cipher)
+		Expect(err).To(BeNil())
+
+		repo, err := connection.OpenRepository(context.Background(), "guests")
+		Expect(err).To(BeNil())
+		defer repo.CloseDb(context.Background())
+
+		detail, found, err := repo.GetItemDetail(context.Background(), "1a")
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(detail.Data["name"]).To(Equal("Jane Doe"))
+		Expect(detail.Activities).To(HaveLen(1))
+	}
+
+	clearData := func() bool {
+		found := false
+
+		_ = filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+			if nil == err && !info.IsDir() {
+				content, _ := ioutil.ReadFile(path)
+				found = found || strings.Contains(string(content), "Jane Doe")
+			}
+
+			return nil
+		})
+
+		return found
+	}
+
+	Context("given a store opened with a key", func() {
+		It("does not hold the records in the clear and opens with its key only", func() {
+			storeGuest(cipher)
+
+			Expect(clearData()).To(BeFalse())
+			expectGuest(cipher)
+
+			_, err := NewEncryptedBowDbConnection(folder, nil)
+			Expect(err).To(MatchError(ContainSubstring(atRest.ErrKeyRequired.Error())))
+
+			_, err = NewEncryptedBowDbConnection(folder, newTestCipher())
+			Expect(err).To(MatchError(ContainSubstring(atRest.ErrWrongKey.Error())))
+		})
+	})
+
+	Context("given a store holding records in the clear", func() {
+		It("is encrypted by Rekey then rotated to another key", func() {
+			storeGuest(nil)
+
+			_, err := NewEncryptedBowDbConnection(folder, cipher)
+			Expect(err).To(MatchError(ContainSubstring(atRest.ErrPlainStore.Error())))
+
+			Expect(Rekey(folder, nil, cipher)).To(Succeed())
+			Expect(clearData()).To(BeFalse())
+			expectGuest(cipher)
+
+			rotated := newTestCipher()
+			Expect(Rekey(folder, cipher, rotated)).To(Succeed())
+			Expect(Rekey(folder, cipher, rotated)).To(Succeed())
+
+			_, err = NewEncryptedBowDbConnection(folder, cipher)
+			Expect(err).NotTo(BeNil())
+			expectGuest(rotated)
+		})
+	})
+})
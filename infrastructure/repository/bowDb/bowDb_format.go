@@ -0,0 +1,142 @@
+package bowDb
+
+import (
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/dbFormat"
+	"github.com/zippoxer/bow"
+)
+
+// formatBucket keeps the format version of every repository of the connection, its name is reserved so no repository shares it.
+// Versions written before were kept in legacyFormatBucket, which a repository named "format" shared.
+const (
+	formatBucket       = scanItem.ReservedPrefix + "format"
+	legacyFormatBucket = "format"
+)
+
+type bowFormat struct {
+	Key     string `bow:"key"`
+	Version int
+}
+
+// storedVersion returns the format version of the repository.
+// Repositories without a version are legacy when they hold data, new ones start at the current version.
+func (r *ScanItemRepository) storedVersion() (version int, stored bool, err error) {
+	var format bowFormat
+
+	err = r.conn.Bucket(formatBucket).Get(r.repoName, &format)
+
+	if nil == err {
+		return format.Version, true, nil
+	} else if bow.ErrNotFound != err {
+		return 0, false, err
+	}
+
+	// an item of a repository named "format" has no version, it is not a format record and the data decides below
+	if nil == r.conn.Bucket(legacyFormatBucket).Get(r.repoName, &format) && format.Version > 0 {
+		return format.Version, false, nil
+	}
+
+	iter := r.getBucket().Iter()
+	defer iter.Close()
+
+	var item bowItem
+	if iter.Next(&item) {
+		return dbFormat.LegacyVersion, false, nil
+	}
+
+	if nil != iter.Err() {
+		return 0, false, iter.Err()
+	}
+
+	return dbFormat.CurrentVersion, false, nil
+}
+
+// migrate upgrades the buckets of the repository to the current format version.
+// Records that can not be decoded stop the migration, the repository is not opened rather than seen empty.
+func (r *ScanItemRepository) migrate() error {
+	version, stored, err := r.storedVersion()
+
+	if err != nil {
+		return fmt.Errorf("bowDb: %s format: %s", r.repoName, err.Error())
+	}
+
+	upgrader, err := dbFormat.NewUpgrader(version)
+
+	if err != nil {
+		return fmt.Errorf("bowDb: %s: %s", r.repoName, err.Error())
+	}
+
+	if upgrader.Needed() {
+		if err := r.upgradeItems(upgrader); err != nil {
+			return fmt.Errorf("bowDb: %s items: %s", r.repoName, err.Error())
+		}
+
+		if err := r.upgradeActivities(upgrader); err != nil {
+			return fmt.Errorf("bowDb: %s activities: %s", r.repoName, err.Error())
+		}
+	}
+
+	if stored && !upgrader.Needed() {
+		return nil
+	}
+
+	return r.conn.Bucket(formatBucket).Put(bowFormat{Key: r.repoName, Version: dbFormat.CurrentVersion})
+}
+
+func (r *ScanItemRepository) upgradeItems(upgrader *dbFormat.Upgrader) error {
+	var items []bowItem
+
+	iter := r.getBucket().Iter()
+
+	var item bowItem
+	for iter.Next(&item) {
+		items = append(items, item)
+		item = bowItem{}
+	}
+
+	iter.Close()
+
+	if nil != iter.Err() {
+		return iter.Err()
+	}
+
+	for _, item := range items {
+		scanned := item.toScanItem()
+		upgrader.Item(scanned)
+
+		if err := r.getBucket().Put(bowItem{Key: scanned.Key, Data: scanned.Data, Local: scanned.Local}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ScanItemRepository) upgradeActivities(upgrader *dbFormat.Upgrader) error {
+	var entries []bowActivity
+
+	iter := r.getActivityBucket().Iter()
+
+	var entry bowActivity
+	for iter.Next(&entry) {
+		entries = append(entries, entry)
+		entry = bowActivity{}
+	}
+
+	iter.Close()
+
+	if nil != iter.Err() {
+		return iter.Err()
+	}
+
+	for _, entry := range entries {
+		entry.Data = upgrader.Activities(entry.Data)
+
+		if err := r.getActivityBucket().Put(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
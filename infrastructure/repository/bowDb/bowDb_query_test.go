@@ -0,0 +1,75 @@
+package bowDb_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	. "git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/bowDb"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("*bowDb queries", func() {
+	ctx := context.Background()
+	var folder string
+	var repo scanItem.RepositoryInterfaceV2
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "bowDbQuery")
+		repo, _ = NewBowDbConnection(folder).OpenRepository(ctx, "guests")
+
+		for idx, key := range []string{"c", "a", "e", "b", "d"} {
+			_, _ = repo.NewItem(ctx, key, map[string]string{"odd": map[bool]string{true: "yes", false: "no"}[1 == idx%2]})
+		}
+
+		_, _ = repo.AddItemActivity(ctx, "d", scanItem.NewActivity("checkin", nil))
+	})
+
+	AfterEach(func() {
+		_ = repo.CloseDb(ctx)
+		_ = os.RemoveAll(folder)
+	})
+
+	pages := func(query scanItem.Query) ([]string, []int) {
+		var keys []string
+		var totals []int
+
+		for {
+			result, err := repo.Query(ctx, query)
+			Expect(err).To(BeNil())
+
+			for _, item := range result.Items {
+				keys = append(keys, item.Key)
+			}
+
+			totals = append(totals, result.Total)
+
+			if "" == result.NextCursor {
+				return keys, totals
+			}
+
+			query.Cursor = result.NextCursor
+		}
+	}
+
+	It("pages by key follow the cursor and count every item", func() {
+		keys, totals := pages(scanItem.Query{Limit: 2})
+		Expect(keys).To(Equal([]string{"a", "b", "c", "d", "e"}))
+		Expect(totals).To(Equal([]int{5, 5, 5}))
+
+		keys, _ = pages(scanItem.Query{SortField: scanItem.SortByKey, SortDesc: true, Limit: 2})
+		Expect(keys).To(Equal([]string{"e", "d", "c", "b", "a"}))
+	})
+
+	It("filtered pages count every matching item", func() {
+		keys, totals := pages(scanItem.Query{Fields: []scanItem.FieldFilter{{Field: "odd", Value: "yes"}}, Limit: 1})
+		Expect(keys).To(Equal([]string{"a", "b"}))
+		Expect(totals).To(Equal([]int{2, 2}))
+
+		keys, totals = pages(scanItem.Query{HasActivity: "checkin", Limit: 2})
+		Expect(keys).To(Equal([]string{"d"}))
+		Expect(totals).To(Equal([]int{1}))
+	})
+})
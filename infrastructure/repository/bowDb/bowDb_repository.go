@@ -1,19 +1,32 @@
 package bowDb
 
 import (
+	"context"
 	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
 	"github.com/zippoxer/bow"
-	"log"
+	"sync"
 )
 
 type ScanItemRepository struct {
-	repoName string
-	conn     *bow.DB
+	repoName     string
+	conn         *bow.DB
+	connection   *Connection
+	activityLock *sync.Mutex
+	closeOnce    sync.Once
 }
 
 type bowItem struct {
-	Key  string `bow:"key"`
-	Data map[string]string
+	Key   string `bow:"key"`
+	Data  map[string]string
+	Local map[string]scanItem.LocalField
+}
+
+func (item bowItem) toScanItem() *scanItem.ScanItem {
+	return &scanItem.ScanItem{
+		Key:   item.Key,
+		Data:  item.Data,
+		Local: item.Local,
+	}
 }
 
 type bowActivity struct {
@@ -21,48 +34,67 @@ type bowActivity struct {
 	Data []scanItem.ItemActivity
 }
 
-func (r *ScanItemRepository) NewItem(itemKey string, data map[string]string) (*scanItem.ScanItem, error) {
+func (r *ScanItemRepository) NewItem(ctx context.Context, itemKey string, data map[string]string) (*scanItem.ScanItem, error) {
 	item, err := scanItem.NewScanItem(itemKey, data)
 
 	if nil != err {
 		return nil, err
 	}
 
-	r.SetItem(item)
+	if err := r.SetItem(ctx, item); err != nil {
+		return nil, err
+	}
 
 	return item, nil
 }
 
-func (r *ScanItemRepository) SetItem(item *scanItem.ScanItem) {
-	_ = r.getBucket().Put(bowItem{
-		Key:  item.GetKey(),
-		Data: item.GetData(),
+func (r *ScanItemRepository) SetItem(ctx context.Context, item *scanItem.ScanItem) error {
+	if err := scanItem.CheckContext(ctx, r.repoName, "set item"); err != nil {
+		return err
+	}
+
+	err := r.getBucket().Put(bowItem{
+		Key:   item.GetKey(),
+		Data:  item.GetData(),
+		Local: item.Local,
 	})
+
+	return r.storageError("set item", err)
 }
 
-func (r *ScanItemRepository) GetItem(key string) (*scanItem.ScanItem, bool) {
+func (r *ScanItemRepository) GetItem(ctx context.Context, key string) (*scanItem.ScanItem, bool, error) {
+	if err := scanItem.CheckContext(ctx, r.repoName, "get item"); err != nil {
+		return nil, false, err
+	}
+
 	var item bowItem
 
-	if err := r.getBucket().Get(key, &item); nil == err {
-		return &scanItem.ScanItem{
-			Key:  item.Key,
-			Data: item.Data,
-		}, true
+	if err := r.getBucket().Get(key, &item); bow.ErrNotFound == err {
+		return nil, false, nil
+	} else if nil != err {
+		return nil, false, r.storageError("get item", err)
 	}
 
-	return nil, false
+	return item.toScanItem(), true, nil
 }
 
-func (r *ScanItemRepository) GetItemDetail(itemKey string) (*scanItem.ItemDetail, bool) {
-	if item, found := r.GetItem(itemKey); found {
-		activities := r.GetItemActivities(itemKey)
-		return &scanItem.ItemDetail{
-			ScanItem:   *item,
-			Activities: activities.Activities,
-		}, true
+func (r *ScanItemRepository) GetItemDetail(ctx context.Context, itemKey string) (*scanItem.ItemDetail, bool, error) {
+	item, found, err := r.GetItem(ctx, itemKey)
+
+	if !found || err != nil {
+		return nil, false, err
 	}
 
-	return nil, false
+	activities, err := r.getActivities(itemKey)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &scanItem.ItemDetail{
+		ScanItem:   *item,
+		Activities: activities,
+	}, true, nil
 }
 
 func (r *ScanItemRepository) getBucket() *bow.Bucket {
@@ -73,7 +105,20 @@ func (r *ScanItemRepository) getActivityBucket() *bow.Bucket {
 	return r.conn.Bucket(r.repoName + "_activity")
 }
 
-func (r *ScanItemRepository) Items() []*scanItem.ScanItem {
+// storageError wraps an error of bow, nil stays nil
+func (r *ScanItemRepository) storageError(op string, err error) error {
+	if nil == err {
+		return nil
+	}
+
+	return scanItem.NewStorageError(r.repoName, op, err)
+}
+
+func (r *ScanItemRepository) Items(ctx context.Context) ([]*scanItem.ScanItem, error) {
+	if err := scanItem.CheckContext(ctx, r.repoName, "items"); err != nil {
+		return nil, err
+	}
+
 	var result []*scanItem.ScanItem
 
 	iter := r.getBucket().Iter()
@@ -81,68 +126,225 @@ func (r *ScanItemRepository) Items() []*scanItem.ScanItem {
 
 	var item bowItem
 	for iter.Next(&item) {
-		result = append(result, &scanItem.ScanItem{
-			Key:  item.Key,
-			Data: item.Data,
-		})
+		result = append(result, item.toScanItem())
 
 		item = bowItem{}
+
+		if err := scanItem.CheckContext(ctx, r.repoName, "items"); err != nil {
+			return nil, err
+		}
 	}
 
 	if iter.Err() != nil {
-		log.Fatal(iter.Err())
+		return nil, r.storageError("items", iter.Err())
 	}
 
-	return result
+	return result, nil
 }
 
 
 //////////////////////
 
-func (r *ScanItemRepository) AddItemActivity(itemKey string, activity scanItem.ItemActivity) *scanItem.ItemActivities {
+func (r *ScanItemRepository) AddItemActivity(ctx context.Context, itemKey string, activity scanItem.ItemActivity) (*scanItem.ItemActivities, error) {
+	r.activityLock.Lock()
+	defer r.activityLock.Unlock()
 
-	item := r.GetItemActivities(itemKey)
+	item, err := r.GetItemActivities(ctx, itemKey)
+
+	if nil == item || nil != err {
+		return nil, err
+	}
 
-	if nil != item {
-		item.Activities = append([]scanItem.ItemActivity{activity}, item.Activities...)
+	item.Activities = append([]scanItem.ItemActivity{activity}, item.Activities...)
 
-		_ = r.getActivityBucket().Put(bowActivity{
-			Key:  item.Key,
-			Data: item.Activities,
-		})
+	err = r.getActivityBucket().Put(bowActivity{
+		Key:  item.Key,
+		Data: item.Activities,
+	})
+
+	if nil != err {
+		return nil, r.storageError("add item activity", err)
 	}
 
-	return item
+	return item, nil
 }
 
-func (r *ScanItemRepository) GetItemActivities(itemKey string) *scanItem.ItemActivities {
+func (r *ScanItemRepository) SetActivitySync(ctx context.Context, itemKey string, activityId string, sync scanItem.ActivitySync) (bool, error) {
+	if err := scanItem.CheckContext(ctx, r.repoName, "set activity sync"); err != nil {
+		return false, err
+	}
+
+	r.activityLock.Lock()
+	defer r.activityLock.Unlock()
 
-	if item, found := r.GetItem(itemKey); found {
+	var dbItem bowActivity
 
-		var dbItem bowActivity
+	if err := r.getActivityBucket().Get(itemKey, &dbItem); bow.ErrNotFound == err {
+		return false, nil
+	} else if nil != err {
+		return false, r.storageError("set activity sync", err)
+	}
 
-		itemActivities := &scanItem.ItemActivities{Key:item.Key, Activities: nil}
+	for idx := range dbItem.Data {
+		if dbItem.Data[idx].Id == activityId {
+			dbItem.Data[idx].Sync = sync
 
-		if err := r.getActivityBucket().Get(itemKey, &dbItem); nil == err {
-			itemActivities.Activities = dbItem.Data
+			if err := r.getActivityBucket().Put(dbItem); nil != err {
+				return false, r.storageError("set activity sync", err)
+			}
+
+			return true, nil
 		}
+	}
+
+	return false, nil
+}
+
+func (r *ScanItemRepository) GetItemActivities(ctx context.Context, itemKey string) (*scanItem.ItemActivities, error) {
+	item, found, err := r.GetItem(ctx, itemKey)
+
+	if !found || nil != err {
+		return nil, err
+	}
 
-		return itemActivities
+	activities, err := r.getActivities(itemKey)
+
+	if nil != err {
+		return nil, err
 	}
 
-	return nil
+	return &scanItem.ItemActivities{Key: item.Key, Activities: activities}, nil
+}
+
+// getActivities reads the stored activities of an item, none when it has no activity bucket entry
+func (r *ScanItemRepository) getActivities(itemKey string) ([]scanItem.ItemActivity, error) {
+	var dbItem bowActivity
+
+	if err := r.getActivityBucket().Get(itemKey, &dbItem); bow.ErrNotFound == err {
+		return nil, nil
+	} else if nil != err {
+		return nil, r.storageError("get item activities", err)
+	}
+
+	return dbItem.Data, nil
 }
 
 func (r *ScanItemRepository) GetRepoName() string {
 	return r.repoName
 }
 
-func (r *ScanItemRepository) Len() int {
-	return 0
+// Query streams the items in key order and keeps the page only, when the query sorts by ascending key.
+// Bow can not seek nor iterate backwards: items before the cursor are read and counted, other sorts load every item.
+func (r *ScanItemRepository) Query(ctx context.Context, query scanItem.Query) (scanItem.QueryResult, error) {
+	if !query.KeyOrdered() || query.SortDesc {
+		return scanItem.RunQuery(ctx, r, query)
+	}
+
+	if err := scanItem.CheckContext(ctx, r.repoName, "query"); err != nil {
+		return scanItem.QueryResult{}, err
+	}
+
+	pager, err := scanItem.NewKeyPager(query)
+
+	if err != nil {
+		return scanItem.QueryResult{}, err
+	}
+
+	count, more := 0, true
+
+	iter := r.getBucket().Iter()
+	defer iter.Close()
+
+	var item bowItem
+	for iter.Next(&item) {
+		count += 1
+
+		if more {
+			more, err = pager.Offer(item.toScanItem(), func(itemKey string) (*scanItem.ItemActivities, error) {
+				activities, err := r.getActivities(itemKey)
+				return &scanItem.ItemActivities{Key: itemKey, Activities: activities}, err
+			})
+
+			if err != nil {
+				return scanItem.QueryResult{}, err
+			}
+		}
+
+		item = bowItem{}
+
+		if err := scanItem.CheckContext(ctx, r.repoName, "query"); err != nil {
+			return scanItem.QueryResult{}, err
+		}
+	}
+
+	if iter.Err() != nil {
+		return scanItem.QueryResult{}, r.storageError("query", iter.Err())
+	}
+
+	return pager.Result(count), nil
+}
+
+func (r *ScanItemRepository) Len(ctx context.Context) (int, error) {
+	if err := scanItem.CheckContext(ctx, r.repoName, "len"); err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	iter := r.getBucket().Iter()
+	defer iter.Close()
+
+	var item bowItem
+	for iter.Next(&item) {
+		count += 1
+		item = bowItem{}
+	}
+
+	return count, r.storageError("len", iter.Err())
 }
 
-func (r *ScanItemRepository) CloseDb() {
-	if err := r.conn.Close(); err != nil {
-		log.Fatal(err.Error())
+// Stats reads the activity bucket once instead of looking up the activities of every item
+func (r *ScanItemRepository) Stats(ctx context.Context, groupBy string) (scanItem.Stats, error) {
+	stats := scanItem.NewStats(groupBy)
+	activities := map[string][]scanItem.ItemActivity{}
+
+	if err := scanItem.CheckContext(ctx, r.repoName, "stats"); err != nil {
+		return stats, err
+	}
+
+	activityIter := r.getActivityBucket().Iter()
+	defer activityIter.Close()
+
+	var dbActivity bowActivity
+	for activityIter.Next(&dbActivity) {
+		activities[dbActivity.Key] = dbActivity.Data
+		dbActivity = bowActivity{}
 	}
+
+	if activityIter.Err() != nil {
+		return stats, r.storageError("stats", activityIter.Err())
+	}
+
+	items, err := r.Items(ctx)
+
+	if err != nil {
+		return stats, err
+	}
+
+	for _, item := range items {
+		stats.Add(item, activities[item.Key])
+	}
+
+	return stats, nil
+}
+
+// CloseDb releases the shared database, it is closed with the last repository of the folder
+func (r *ScanItemRepository) CloseDb(ctx context.Context) error {
+	var err error
+
+	r.closeOnce.Do(func() {
+		err = r.storageError("close", r.connection.release())
+	})
+
+	return err
 }
@@ -0,0 +1,54 @@
+package bowDb_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	. "git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/bowDb"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("*bowDb stats", func() {
+	ctx := context.Background()
+	var folder string
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "bowDbStats")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	It("counts items, items scanned per action and per group, and unsynced activities", func() {
+		repo, err := NewBowDbConnection(folder).OpenRepository(ctx, "guests")
+		Expect(err).To(BeNil())
+		defer repo.CloseDb(ctx)
+
+		_, _ = repo.NewItem(ctx, "1a", map[string]string{"gateway": "north"})
+		_, _ = repo.NewItem(ctx, "2b", map[string]string{"gateway": "south"})
+		_, _ = repo.NewItem(ctx, "3c", map[string]string{})
+
+		_, _ = repo.AddItemActivity(ctx, "1a", scanItem.NewActivity("checkin", nil))
+		_, _ = repo.AddItemActivity(ctx, "1a", scanItem.NewActivity("checkin", nil))
+		_, _ = repo.AddItemActivity(ctx, "2b", scanItem.NewActivity("checkin", map[string]string{"gateway": "north"}))
+		sent := scanItem.NewActivity("lunch", nil)
+		_, _ = repo.AddItemActivity(ctx, "2b", sent)
+		_, _ = repo.SetActivitySync(ctx, "2b", sent.Id, scanItem.ActivitySync{Status: scanItem.SyncSent})
+
+		Expect(repo.Len(ctx)).To(Equal(3))
+
+		stats, err := repo.(scanItem.StatsRepository).Stats(ctx, "gateway")
+		Expect(err).To(BeNil())
+		Expect(stats.Items).To(Equal(3))
+		Expect(stats.Actions).To(Equal(map[string]int{"checkin": 2, "lunch": 1}))
+		Expect(stats.Groups).To(Equal(map[string]map[string]int{
+			"north": {"checkin": 2},
+			"south": {"lunch": 1},
+		}))
+		Expect(stats.Sync).To(Equal(scanItem.SyncSummary{Pending: 3, Sent: 1, Unsynced: 3}))
+	})
+})
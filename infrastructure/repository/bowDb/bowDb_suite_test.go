@@ -1,6 +1,8 @@
 package bowDb_test
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -11,3 +13,10 @@ func TestRepository(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "BowDb Repository Suite")
 }
+
+// suiteFolder holds the stores of setupDb, it is removed once every spec ran
+var suiteFolder, _ = ioutil.TempDir("", "bowDbSuite")
+
+var _ = AfterSuite(func() {
+	_ = os.RemoveAll(suiteFolder)
+})
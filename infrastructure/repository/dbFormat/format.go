@@ -0,0 +1,130 @@
+package dbFormat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"io"
+)
+
+// Version of the data written by this build.
+// Version 1 is the data written before versioning existed, it has no header.
+const (
+	LegacyVersion  = 1
+	CurrentVersion = 2
+)
+
+// Migration upgrades data written at Version-1 to Version
+type Migration struct {
+	Version     int
+	Description string
+	Item        func(item *scanItem.ScanItem)
+	Activities  func(activities []scanItem.ItemActivity) []scanItem.ItemActivity
+}
+
+var migrations = []Migration{
+	{
+		Version:     2,
+		Description: "give an id to every activity",
+		Activities: func(activities []scanItem.ItemActivity) []scanItem.ItemActivity {
+			for idx := range activities {
+				activities[idx] = activities[idx].WithId()
+			}
+
+			return activities
+		},
+	},
+}
+
+var magic = []byte("EVHUB")
+
+// ErrNewerVersion is returned when the data was written by a newer hub, it is never downgraded
+var ErrNewerVersion = errors.New("data was written by a newer version of event hub")
+
+// Pending returns the migrations to run on data written at version, oldest first
+func Pending(version int) ([]Migration, error) {
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("%w: format version %d, this build reads up to %d", ErrNewerVersion, version, CurrentVersion)
+	}
+
+	var pending []Migration
+
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Upgrader runs the pending migrations of a repository over its items and activities
+type Upgrader struct {
+	From       int
+	Migrations []Migration
+}
+
+func NewUpgrader(version int) (*Upgrader, error) {
+	pending, err := Pending(version)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Upgrader{From: version, Migrations: pending}, nil
+}
+
+// Needed tells whether any migration has to run
+func (u *Upgrader) Needed() bool {
+	return len(u.Migrations) > 0
+}
+
+func (u *Upgrader) Item(item *scanItem.ScanItem) {
+	for _, migration := range u.Migrations {
+		if nil != migration.Item && nil != item {
+			migration.Item(item)
+		}
+	}
+}
+
+func (u *Upgrader) Activities(activities []scanItem.ItemActivity) []scanItem.ItemActivity {
+	for _, migration := range u.Migrations {
+		if nil != migration.Activities {
+			activities = migration.Activities(activities)
+		}
+	}
+
+	return activities
+}
+
+// WriteHeader writes the format header in front of a persisted file
+func WriteHeader(w io.Writer) error {
+	header := make([]byte, len(magic)+4)
+	copy(header, magic)
+	binary.BigEndian.PutUint32(header[len(magic):], CurrentVersion)
+
+	_, err := w.Write(header)
+
+	return err
+}
+
+// ReadHeader returns the format version of a persisted file, files without header are LegacyVersion.
+// The reader is left at the start of the payload.
+func ReadHeader(r *bufio.Reader) (int, error) {
+	prefix, err := r.Peek(len(magic))
+
+	if err != nil || string(prefix) != string(magic) {
+		// legacy files start straight with their payload, an empty file is left to the decoder
+		return LegacyVersion, nil
+	}
+
+	header := make([]byte, len(magic)+4)
+
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, fmt.Errorf("format header: %s", err.Error())
+	}
+
+	return int(binary.BigEndian.Uint32(header[len(magic):])), nil
+}
@@ -0,0 +1,52 @@
+package dbFormat
+
+import (
+	"bufio"
+	"bytes"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReadHeader__Given__WrittenHeader__Expect__CurrentVersionAndPayload(t *testing.T) {
+	var buffer bytes.Buffer
+	_ = WriteHeader(&buffer)
+	buffer.WriteString("payload")
+
+	reader := bufio.NewReader(&buffer)
+	version, err := ReadHeader(reader)
+
+	assert.Nil(t, err)
+	assert.Equal(t, CurrentVersion, version)
+
+	rest, _ := reader.ReadString(0)
+	assert.Equal(t, "payload", rest)
+}
+
+func TestReadHeader__Given__NoHeader__Expect__LegacyVersionAndUntouchedPayload(t *testing.T) {
+	reader := bufio.NewReader(bytes.NewBufferString("payload"))
+	version, err := ReadHeader(reader)
+
+	assert.Nil(t, err)
+	assert.Equal(t, LegacyVersion, version)
+
+	rest, _ := reader.ReadString(0)
+	assert.Equal(t, "payload", rest)
+}
+
+func TestPending__Given__NewerVersion__Expect__Error(t *testing.T) {
+	_, err := Pending(CurrentVersion + 1)
+
+	assert.Error(t, err)
+}
+
+func TestUpgrader__Given__LegacyActivities__Expect__Ids(t *testing.T) {
+	upgrader, _ := NewUpgrader(LegacyVersion)
+	kept := scanItem.NewActivity("lunch", nil)
+
+	got := upgrader.Activities([]scanItem.ItemActivity{{Action: "checkin"}, kept})
+
+	assert.True(t, upgrader.Needed())
+	assert.NotEmpty(t, got[0].Id)
+	assert.Equal(t, kept.Id, got[1].Id)
+}
@@ -1,6 +1,8 @@
 package memDb_test
 
 import (
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	"io/ioutil"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -11,3 +13,14 @@ func TestRepository(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "MemDb Repository Suite")
 }
+
+// newTestConnection opens a connection on a fresh folder, so specs never share persisted data
+func newTestConnection() *memDb.Connection {
+	folder, err := ioutil.TempDir("", "memDb")
+
+	if err != nil {
+		panic(err)
+	}
+
+	return memDb.NewMemDbConnection(folder)
+}
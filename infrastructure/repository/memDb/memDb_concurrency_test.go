@@ -0,0 +1,55 @@
+package memDb_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	. "git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("*memDb concurrent activity writes", func() {
+	ctx := context.Background()
+	var folder string
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "memDbConcurrency")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	It("keeps every activity added while push acknowledgements are recorded", func() {
+		repo, err := NewMemDbConnection(folder).OpenRepository(ctx, "guests")
+		Expect(err).To(BeNil())
+		defer repo.CloseDb(ctx)
+
+		_, _ = repo.NewItem(ctx, "1a", map[string]string{})
+		first := scanItem.NewActivity("checkin", nil)
+		_, _ = repo.AddItemActivity(ctx, "1a", first)
+
+		var wg sync.WaitGroup
+		for n := 0; n < 50; n++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, _ = repo.AddItemActivity(ctx, "1a", scanItem.NewActivity("lunch", nil))
+			}()
+			go func() {
+				defer wg.Done()
+				_, _ = repo.SetActivitySync(ctx, "1a", first.Id, scanItem.ActivitySync{Status: scanItem.SyncSent})
+			}()
+		}
+		wg.Wait()
+
+		activities, err := repo.GetItemActivities(ctx, "1a")
+		Expect(err).To(BeNil())
+		Expect(activities.Activities).To(HaveLen(51))
+		Expect(activities.Activities[50].Sync.Status).To(Equal(scanItem.SyncSent))
+	})
+})
@@ -2,66 +2,272 @@ package memDb
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/gob"
+	"fmt"
 	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/dbFormat"
 	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
+const DefaultSnapshotInterval = time.Minute
+
+// Options of the "mem" storage adapter
+type Options struct {
+	Folder            string
+	SnapshotInterval  time.Duration
+	EncryptionKeyFile string
+	EncryptionKeyEnv  string
+}
+
 type Connection struct {
-	dbFolder string
-	connections map[string]*cache.Cache
+	dbFolder         string
+	snapshotInterval time.Duration
+	cipher           *atRest.Cipher
+	logger           *zap.Logger
 }
 
 type memDumpStruct struct {
 	 Items map[string]cache.Item
 }
 
+func init() {
+	gob.Register(memDumpStruct{})
+	gob.Register(&scanItem.ScanItem{})
+	gob.Register(scanItem.ItemActivities{})
+	gob.Register([]scanItem.ItemActivity{})
+	gob.Register(scanItem.ItemActivity{})
+}
+
 func NewMemDbConnection(dbFolder string) *Connection {
 	return &Connection{
-		dbFolder: dbFolder,
-		connections: make(map[string]*cache.Cache),
+		dbFolder:         dbFolder,
+		snapshotInterval: DefaultSnapshotInterval,
+		logger:           zap.NewNop(),
+	}
+}
+
+// SetLogger sets where the snapshot failures of the repositories opened afterwards are reported
+func (db *Connection) SetLogger(logger *zap.Logger) *Connection {
+	db.logger = logger
+
+	return db
+}
+
+// SetSnapshotInterval sets how often the write-ahead log is compacted into snapshot files
+func (db *Connection) SetSnapshotInterval(interval time.Duration) *Connection {
+	if interval > 0 {
+		db.snapshotInterval = interval
+	}
+
+	return db
+}
+
+// SetCipher encrypts the snapshots and write-ahead logs of the connection,
+// a store sealed with another key, or with a key while none is given, is refused
+func (db *Connection) SetCipher(cipher *atRest.Cipher) error {
+	if err := atRest.Guard(db.dbFolder, cipher, db.hasData()); err != nil {
+		return fmt.Errorf("memDb: %w", err)
+	}
+
+	db.cipher = cipher
+
+	return nil
+}
+
+// Cipher is the cipher the store is sealed with, nil when it is kept in the clear
+func (db *Connection) Cipher() *atRest.Cipher {
+	return db.cipher
+}
+
+// hasData tells whether the folder holds a snapshot or a write-ahead log
+func (db *Connection) hasData() bool {
+	for _, pattern := range []string{"*.mem", "*.wal"} {
+		if files, _ := filepath.Glob(filepath.Join(db.dbFolder, pattern)); len(files) > 0 {
+			return true
+		}
 	}
+
+	return false
 }
 
 func (db *Connection) InitRepository(name string) (scanItem.RepositoryInterface, error) {
+	return scanItem.LegacyOpen(db.OpenRepository(context.Background(), name))
+}
+
+// OpenRepository loads the latest snapshot of a repository then replays its write-ahead log
+func (db *Connection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	if err := scanItem.CheckContext(ctx, name, "open"); err != nil {
+		return nil, err
+	}
+
 	fName := db.dbFolder + "/" + name
 
-	db.connections[name] = db.fromFile( fName + "_item.mem")
-	db.connections[name+"_activity"] = db.fromFile( fName + "_activity.mem")
+	if err := os.MkdirAll(db.dbFolder, 0755); err != nil {
+		return nil, err
+	}
+
+	itemStorage, itemVersion, err := db.fromFile(fName + "_item.mem")
+
+	if err != nil {
+		return nil, err
+	}
+
+	activityStorage, activityVersion, err := db.fromFile(fName + "_activity.mem")
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = replayWal(fName+".wal", db.cipher, func(record walRecord) {
+		switch record.Op {
+		case walSetItem:
+			itemStorage.Set(record.Key, record.Item, 0)
+		case walSetActivities:
+			activityStorage.Set(record.Key, record.Activities, 0)
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := upgrade(itemStorage, itemVersion); err != nil {
+		return nil, fmt.Errorf("memDb: %s items: %s", name, err.Error())
+	}
+
+	if err := upgrade(activityStorage, activityVersion); err != nil {
+		return nil, fmt.Errorf("memDb: %s activities: %s", name, err.Error())
+	}
+
+	wal, err := openWal(fName+".wal", db.cipher)
+
+	if err != nil {
+		return nil, err
+	}
 
 	repo := &ScanItemRepository{
-		dbName:  name,
-		dbFolder: db.dbFolder,
-		itemStorage:     db.connections[name],
-		activityStorage: db.connections[name+"_activity"],
+		dbName:          name,
+		dbFolder:        db.dbFolder,
+		cipher:          db.cipher,
+		itemStorage:     itemStorage,
+		activityStorage: activityStorage,
+		wal:             wal,
+		stopSnapshot:    make(chan struct{}),
+		logger:          db.logger,
+	}
+
+	// compact what was replayed and migrated, the next crash only replays what came after
+	if err := repo.SaveToFile(); err != nil {
+		return nil, err
 	}
 
+	go repo.snapshotEvery(db.snapshotInterval)
+
 	return repo, nil
 }
 
-func (db *Connection) fromFile(fileName string) *cache.Cache {
+// fromFile loads a snapshot and the format version it was written with.
+// A missing snapshot is an empty repository, a snapshot that can not be decoded is an error:
+// starting empty would silently drop the data on the next snapshot.
+func (db *Connection) fromFile(fileName string) (*cache.Cache, int, error) {
+	fp, err := os.Open(fileName)
+
+	if os.IsNotExist(err) {
+		return cache.New(0, 0), dbFormat.CurrentVersion, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	defer func() { _ = fp.Close() }()
+
+	reader := bufio.NewReader(fp)
+	version, err := dbFormat.ReadHeader(reader)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("memDb: %s: %s", fileName, err.Error())
+	}
 
-	if fp, err := os.Open(fileName); err == nil {
+	payload, err := ioutil.ReadAll(reader)
 
-		defer func() { _ = fp.Close()}()
+	if nil == err {
+		payload, err = db.cipher.Open(payload)
+	}
 
-		var memDump memDumpStruct
+	if err != nil {
+		return nil, 0, fmt.Errorf("memDb: %s: %w", fileName, err)
+	}
 
-		gob.Register(memDumpStruct{})
-		gob.Register(&scanItem.ScanItem{})
-		gob.Register(scanItem.ItemActivities{})
-		gob.Register([]scanItem.ItemActivity{})
-		gob.Register(scanItem.ItemActivity{})
+	var memDump memDumpStruct
 
-		dec := gob.NewDecoder(bufio.NewReader(fp))
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&memDump); err != nil {
+		return nil, 0, fmt.Errorf("memDb: can not decode %s (format version %d): %s", fileName, version, err.Error())
+	}
+
+	if nil == memDump.Items {
+		memDump.Items = make(map[string]cache.Item)
+	}
+
+	return cache.NewFrom(0, 0, memDump.Items), version, nil
+}
+
+// upgrade runs the migrations pending since version over every entry of a storage
+func upgrade(storage *cache.Cache, version int) error {
+	upgrader, err := dbFormat.NewUpgrader(version)
+
+	if err != nil || !upgrader.Needed() {
+		return err
+	}
+
+	for key, entry := range storage.Items() {
+		switch object := entry.Object.(type) {
+		case *scanItem.ScanItem:
+			upgrader.Item(object)
+		case []scanItem.ItemActivity:
+			storage.Set(key, upgrader.Activities(object), 0)
+		}
+	}
+
+	return nil
+}
+
+// Rekey seals every repository of the store in folder with to, no key leaves them in the clear.
+// The store must be sealed with from, or with to when an interrupted rekey is run again.
+func Rekey(folder string, from *atRest.Cipher, to *atRest.Cipher) error {
+	if err := atRest.Check(folder, from); err != nil && atRest.Check(folder, to) != nil {
+		return fmt.Errorf("memDb: %s: %w", folder, err)
+	}
+
+	db := NewMemDbConnection(folder)
+	db.cipher = atRest.Rekeying(from, to)
+
+	snapshots, err := filepath.Glob(filepath.Join(folder, "*_item.mem"))
+
+	if err != nil {
+		return err
+	}
+
+	// opening a repository replays its log and writes a new snapshot, sealed like to
+	for _, snapshot := range snapshots {
+		name := strings.TrimSuffix(filepath.Base(snapshot), "_item.mem")
+		repo, err := db.OpenRepository(context.Background(), name)
+
+		if err != nil {
+			return err
+		}
 
-		if err := dec.Decode(&memDump) ; err == nil {
-			if len(memDump.Items) > 0 {
-				return cache.NewFrom(0,0, memDump.Items)
-			}
+		if err := repo.CloseDb(context.Background()); err != nil {
+			return err
 		}
 	}
 
-	return cache.New(0,0, )
+	return atRest.WriteMarker(folder, to)
 }
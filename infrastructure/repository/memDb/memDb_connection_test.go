@@ -1,6 +1,7 @@
 package memDb_test
 
 import (
+	"context"
 	"git.anphabe.net/event/anphabe-event-hub/domain/service"
 	. "git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
 
@@ -10,8 +11,8 @@ import (
 
 var _ = Describe("MemDbConnection", func() {
 
-	Context("Call to NewMemDbConnection() to create a *memDb.Connection", func() {
-		connection := NewMemDbConnection()
+	Context("Call to newTestConnection() to create a *memDb.Connection", func() {
+		connection := newTestConnection()
 
 		It("connection must be *memDb.Connection", func() {
 			Expect(connection).To(BeAssignableToTypeOf((*Connection)(nil)))
@@ -23,12 +24,12 @@ var _ = Describe("MemDbConnection", func() {
 	})
 
 	Context("given *memDb.Connection created", func() {
-		sut := NewMemDbConnection()
+		sut := newTestConnection()
 		repoName := "testRepo"
 
-		Context("calling to InitRepository("+`"`+repoName+`"`+") to create a *ScanItemRepository", func() {
+		Context("calling to OpenRepository("+`"`+repoName+`"`+") to create a *ScanItemRepository", func() {
 
-			repository, _ := sut.InitRepository(repoName)
+			repository, _ := sut.OpenRepository(context.Background(), repoName)
 
 			It("repository must be *memDb.ScanItemRepository", func() {
 				Expect(repository).To(BeAssignableToTypeOf((*ScanItemRepository)(nil)))
@@ -0,0 +1,136 @@
+package memDb_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	. "git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func newTestCipher() *atRest.Cipher {
+	text, _ := atRest.GenerateKey()
+	key, _ := atRest.ParseKey(text)
+	cipher, _ := atRest.NewCipher(key)
+
+	return cipher
+}
+
+func encryptedConnection(folder string, cipher *atRest.Cipher) (*Connection, error) {
+	connection := NewMemDbConnection(folder)
+
+	return connection, connection.SetCipher(cipher)
+}
+
+var _ = Describe("*memDb at-rest encryption", func() {
+	var folder string
+	var cipher *atRest.Cipher
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "memDbEncryption")
+		cipher = newTestCipher()
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	storeGuest := func(connection *Connection) {
+		repo, err := connection.OpenRepository(context.Background(), "guests")
+		Expect(err).To(BeNil())
+
+		item, _ := scanItem.NewScanItem("1a", map[string]string{"name": "Jane Doe"})
+		Expect(repo.SetItem(context.Background(), item)).To(Succeed())
+		_, err = repo.AddItemActivity(context.Background(), "1a", scanItem.NewActivity("checkin", map[string]string{"gateway": "gate1"}))
+		Expect(err).To(BeNil())
+	}
+
+	expectGuest := func(connection *Connection) {
+		repo, err := connection.OpenRepository(context.Background(), "guests")
+		Expect(err).To(BeNil())
+		defer repo.CloseDb(context.Background())
+
+		detail, found, err := repo.GetItemDetail(context.Background(), "1a")
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(detail.Data["name"]).To(Equal("Jane Doe"))
+		Expect(detail.Activities).To(HaveLen(1))
+	}
+
+	Context(" :GIVEN: a store opened with a key", func() {
+		It("neither the write-ahead log nor the snapshots hold the data in the clear", func() {
+			connection, err := encryptedConnection(folder, cipher)
+			Expect(err).To(BeNil())
+			storeGuest(connection)
+
+			wal, _ := ioutil.ReadFile(filepath.Join(folder, "guests.wal"))
+			Expect(string(wal)).NotTo(BeEmpty())
+			Expect(string(wal)).NotTo(ContainSubstring("Jane Doe"))
+
+			reopened, err := encryptedConnection(folder, cipher)
+			Expect(err).To(BeNil())
+			expectGuest(reopened)
+
+			snapshot, _ := ioutil.ReadFile(filepath.Join(folder, "guests_item.mem"))
+			Expect(string(snapshot)).NotTo(ContainSubstring("Jane Doe"))
+		})
+
+		It("is refused without its key or with another key", func() {
+			connection, err := encryptedConnection(folder, cipher)
+			Expect(err).To(BeNil())
+			storeGuest(connection)
+
+			_, err = encryptedConnection(folder, nil)
+			Expect(err).To(MatchError(ContainSubstring(atRest.ErrKeyRequired.Error())))
+
+			_, err = encryptedConnection(folder, newTestCipher())
+			Expect(err).To(MatchError(ContainSubstring(atRest.ErrWrongKey.Error())))
+		})
+	})
+
+	Context(" :GIVEN: a store holding data in the clear", func() {
+		It("a key is refused until the store is rekeyed", func() {
+			storeGuest(NewMemDbConnection(folder))
+
+			_, err := encryptedConnection(folder, cipher)
+			Expect(err).To(MatchError(ContainSubstring(atRest.ErrPlainStore.Error())))
+
+			Expect(Rekey(folder, nil, cipher)).To(Succeed())
+
+			connection, err := encryptedConnection(folder, cipher)
+			Expect(err).To(BeNil())
+			expectGuest(connection)
+		})
+	})
+
+	Context(" :GIVEN: a key rotation", func() {
+		It("the store opens with the new key only, a second run is harmless", func() {
+			connection, err := encryptedConnection(folder, cipher)
+			Expect(err).To(BeNil())
+			storeGuest(connection)
+
+			rotated := newTestCipher()
+			Expect(Rekey(folder, cipher, rotated)).To(Succeed())
+			Expect(Rekey(folder, cipher, rotated)).To(Succeed())
+
+			_, err = encryptedConnection(folder, cipher)
+			Expect(err).NotTo(BeNil())
+
+			connection, err = encryptedConnection(folder, rotated)
+			Expect(err).To(BeNil())
+			expectGuest(connection)
+
+			By("no key decrypts the store")
+			Expect(Rekey(folder, rotated, nil)).To(Succeed())
+
+			connection, err = encryptedConnection(folder, nil)
+			Expect(err).To(BeNil())
+			expectGuest(connection)
+		})
+	})
+})
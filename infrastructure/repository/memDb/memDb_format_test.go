@@ -0,0 +1,82 @@
+package memDb_test
+
+import (
+	"encoding/gob"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	. "git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	"github.com/patrickmn/go-cache"
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// legacyDump is how snapshots were written before the format header existed
+type legacyDump struct {
+	Items map[string]cache.Item
+}
+
+func writeLegacySnapshot(fileName string, items map[string]cache.Item) {
+	fp, _ := os.Create(fileName)
+	defer func() { _ = fp.Close() }()
+
+	Expect(gob.NewEncoder(fp).Encode(legacyDump{Items: items})).To(Succeed())
+}
+
+var _ = Describe("*memDb format version", func() {
+	var folder string
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "memDbFormat")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	Context(" :GIVEN: snapshots written before versioning", func() {
+		It("activities are migrated and given an id", func() {
+			item := scanItem.CreateTestScanItem("2")
+			created := time.Now().Add(-time.Hour)
+
+			writeLegacySnapshot(folder+"/gate_item.mem", map[string]cache.Item{
+				item.GetKey(): {Object: item},
+			})
+			writeLegacySnapshot(folder+"/gate_activity.mem", map[string]cache.Item{
+				item.GetKey(): {Object: []scanItem.ItemActivity{{Action: "checkin", Created: created}}},
+			})
+
+			repo, err := NewMemDbConnection(folder).InitRepository("gate")
+			Expect(err).To(BeNil())
+
+			got, found := repo.GetItemDetail(item.GetKey())
+			Expect(found).To(BeTrue())
+			Expect(got.Activities).To(HaveLen(1))
+			Expect(got.Activities[0].Id).NotTo(BeEmpty())
+			Expect(got.Activities[0].Action).To(Equal("checkin"))
+
+			migratedId := got.Activities[0].Id
+			repo.CloseDb()
+
+			By("the migrated snapshot is read back as is")
+			reopened, err := NewMemDbConnection(folder).InitRepository("gate")
+			Expect(err).To(BeNil())
+			defer reopened.CloseDb()
+
+			again, _ := reopened.GetItemDetail(item.GetKey())
+			Expect(again.Activities[0].Id).To(Equal(migratedId))
+		})
+	})
+
+	Context(" :GIVEN: a snapshot which can not be decoded", func() {
+		It("InitRepository fails instead of starting empty", func() {
+			Expect(ioutil.WriteFile(folder+"/gate_item.mem", []byte("not a snapshot"), 0644)).To(Succeed())
+
+			_, err := NewMemDbConnection(folder).InitRepository("gate")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("gate_item.mem"))
+		})
+	})
+})
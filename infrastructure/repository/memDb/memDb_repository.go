@@ -3,11 +3,18 @@ package memDb
 
 import (
 	"bufio"
-	"fmt"
+	"bytes"
+	"context"
+	"encoding/gob"
 	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/dbFormat"
 	"github.com/patrickmn/go-cache"
-	"encoding/gob"
+	"go.uber.org/zap"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 )
 
 type ScanItemRepository struct {
@@ -15,119 +22,327 @@ type ScanItemRepository struct {
 	dbFolder string
 	itemStorage     *cache.Cache
 	activityStorage *cache.Cache
+	cipher          *atRest.Cipher
+
+	// activity lists are read, changed and written back, one writer at a time so none is lost
+	activityLock sync.Mutex
+
+	// mutations share the lock, a snapshot holds it exclusively while it writes and truncates the wal
+	snapshotLock sync.RWMutex
+	wal          *writeAheadLog
+	stopSnapshot chan struct{}
+	closeOnce    sync.Once
+	logger       *zap.Logger
 }
 
-func (s *ScanItemRepository) NewItem(itemKey string, data map[string]string) (*scanItem.ScanItem, error) {
+func (s *ScanItemRepository) NewItem(ctx context.Context, itemKey string, data map[string]string) (*scanItem.ScanItem, error) {
 	item, err := scanItem.NewScanItem(itemKey, data)
 
 	if nil != err {
 		return nil, err
 	}
 
-	s.SetItem(item)
+	if err := s.SetItem(ctx, item); err != nil {
+		return nil, err
+	}
 
 	return item, nil
 }
 
-func (s *ScanItemRepository) SetItem(item *scanItem.ScanItem) {
+func (s *ScanItemRepository) SetItem(ctx context.Context, item *scanItem.ScanItem) error {
+	if err := scanItem.CheckContext(ctx, s.dbName, "set item"); err != nil {
+		return err
+	}
+
+	s.snapshotLock.RLock()
+	defer s.snapshotLock.RUnlock()
+
+	if err := s.logMutation(walRecord{Op: walSetItem, Key: item.GetKey(), Item: item}); err != nil {
+		return err
+	}
+
 	s.itemStorage.Set(item.GetKey(), item, 0)
+
+	return nil
 }
 
 func (s *ScanItemRepository) GetRepoName() string {
 	return s.dbName
 }
 
-func (s *ScanItemRepository) GetItem(itemKey string) (*scanItem.ScanItem, bool) {
+func (s *ScanItemRepository) GetItem(ctx context.Context, itemKey string) (*scanItem.ScanItem, bool, error) {
+	if err := scanItem.CheckContext(ctx, s.dbName, "get item"); err != nil {
+		return nil, false, err
+	}
+
 	if item, found := s.itemStorage.Get(itemKey); found {
-		return item.(*scanItem.ScanItem), found
+		return item.(*scanItem.ScanItem), found, nil
 	}
 
-	return nil, false
+	return nil, false, nil
 }
 
-func (s *ScanItemRepository) GetItemDetail(itemKey string) (*scanItem.ItemDetail, bool) {
-	if item, found := s.GetItem(itemKey); found {
-		activities := s.GetItemActivities(itemKey)
-		return &scanItem.ItemDetail{
-			ScanItem:   *item,
-			Activities: activities.Activities,
-		}, true
+func (s *ScanItemRepository) GetItemDetail(ctx context.Context, itemKey string) (*scanItem.ItemDetail, bool, error) {
+	item, found, err := s.GetItem(ctx, itemKey)
+
+	if !found || err != nil {
+		return nil, false, err
 	}
 
-	return nil, false
+	activities, err := s.GetItemActivities(ctx, itemKey)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &scanItem.ItemDetail{
+		ScanItem:   *item,
+		Activities: activities.Activities,
+	}, true, nil
 }
 
-func (s *ScanItemRepository) AddItemActivity(itemKey string, activity scanItem.ItemActivity) *scanItem.ItemActivities {
+func (s *ScanItemRepository) AddItemActivity(ctx context.Context, itemKey string, activity scanItem.ItemActivity) (*scanItem.ItemActivities, error) {
+	s.activityLock.Lock()
+	defer s.activityLock.Unlock()
 
-	item := s.GetItemActivities(itemKey)
+	item, err := s.GetItemActivities(ctx, itemKey)
 
-	if nil != item {
+	if nil != item && nil == err {
 		item.Activities = append([]scanItem.ItemActivity{activity}, item.Activities...)
-		s.activityStorage.Set(itemKey, item.Activities, 0)
+		err = s.setActivities(itemKey, item.Activities)
 	}
 
-	return item
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
 }
 
-func (s *ScanItemRepository) GetItemActivities(itemKey string) *scanItem.ItemActivities {
-	if _, found := s.GetItem(itemKey); found {
-		itemActivities := &scanItem.ItemActivities{Key:itemKey, Activities: nil}
+func (s *ScanItemRepository) SetActivitySync(ctx context.Context, itemKey string, activityId string, sync scanItem.ActivitySync) (bool, error) {
+	if err := scanItem.CheckContext(ctx, s.dbName, "set activity sync"); err != nil {
+		return false, err
+	}
+
+	s.activityLock.Lock()
+	defer s.activityLock.Unlock()
+
+	if data, found := s.activityStorage.Get(itemKey); found {
+		// copy on write, the stored slice may be read by a running snapshot
+		activities := append([]scanItem.ItemActivity(nil), data.([]scanItem.ItemActivity)...)
+
+		for idx := range activities {
+			if activities[idx].Id == activityId {
+				activities[idx].Sync = sync
 
-		if data , found := s.activityStorage.Get(itemKey); found {
-			itemActivities.Activities = data.([]scanItem.ItemActivity)
+				if err := s.setActivities(itemKey, activities); err != nil {
+					return false, err
+				}
+
+				return true, nil
+			}
 		}
+	}
 
-		return itemActivities
+	return false, nil
+}
+
+func (s *ScanItemRepository) setActivities(itemKey string, activities []scanItem.ItemActivity) error {
+	s.snapshotLock.RLock()
+	defer s.snapshotLock.RUnlock()
+
+	if err := s.logMutation(walRecord{Op: walSetActivities, Key: itemKey, Activities: activities}); err != nil {
+		return err
 	}
 
+	s.activityStorage.Set(itemKey, activities, 0)
+
 	return nil
 }
 
-func (s *ScanItemRepository) Items() []*scanItem.ScanItem {
+// logMutation makes a mutation durable before it is applied in memory, a mutation which can not be logged is not applied
+func (s *ScanItemRepository) logMutation(record walRecord) error {
+	if err := s.wal.Append(record); err != nil {
+		return scanItem.NewStorageError(s.dbName, "write ahead log", err)
+	}
+
+	return nil
+}
+
+func (s *ScanItemRepository) GetItemActivities(ctx context.Context, itemKey string) (*scanItem.ItemActivities, error) {
+	if _, found, err := s.GetItem(ctx, itemKey); !found || err != nil {
+		return nil, err
+	}
+
+	itemActivities := &scanItem.ItemActivities{Key: itemKey, Activities: nil}
+
+	if data, found := s.activityStorage.Get(itemKey); found {
+		itemActivities.Activities = data.([]scanItem.ItemActivity)
+	}
+
+	return itemActivities, nil
+}
+
+func (s *ScanItemRepository) Items(ctx context.Context) ([]*scanItem.ScanItem, error) {
+	if err := scanItem.CheckContext(ctx, s.dbName, "items"); err != nil {
+		return nil, err
+	}
+
 	var result []*scanItem.ScanItem
 
 	for _, item := range s.itemStorage.Items() {
 		result = append(result, item.Object.(*scanItem.ScanItem))
 	}
 
-	return result
+	return result, nil
+}
+
+func (s *ScanItemRepository) Query(ctx context.Context, query scanItem.Query) (scanItem.QueryResult, error) {
+	return scanItem.RunQuery(ctx, s, query)
+}
+
+func (s *ScanItemRepository) Len(ctx context.Context) (int, error) {
+	if err := scanItem.CheckContext(ctx, s.dbName, "len"); err != nil {
+		return 0, err
+	}
+
+	return s.itemStorage.ItemCount(), nil
 }
 
-func (s *ScanItemRepository) Len() int {
-	return s.itemStorage.ItemCount()
+func (s *ScanItemRepository) CloseDb(ctx context.Context) error {
+	var err error
+
+	s.closeOnce.Do(func() {
+		close(s.stopSnapshot)
+
+		if saveErr := s.SaveToFile(); saveErr != nil {
+			err = scanItem.NewStorageError(s.dbName, "close", saveErr)
+		}
+
+		_ = s.wal.Close()
+		s.itemStorage.Flush()
+		s.activityStorage.Flush()
+	})
+
+	return err
 }
 
-func (s *ScanItemRepository) CloseDb() {
-	s.SaveToFile()
-	s.itemStorage.Flush()
-	s.activityStorage.Flush()
+func (s *ScanItemRepository) snapshotEvery(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-s.stopSnapshot:
+			return
+
+		case <-tick.C:
+			if err := s.SaveToFile(); err != nil {
+				s.logger.Error("memDb: could not snapshot", zap.String("dbName", s.dbName), zap.Error(err))
+			}
+		}
+	}
 }
 
-func (s *ScanItemRepository) SaveToFile() {
+// SaveToFile writes a compacted snapshot of the repository then empties the write-ahead log
+func (s *ScanItemRepository) SaveToFile() error {
 	fName := s.dbFolder + "/" + s.dbName
 
-	s.saveStruct(s.itemStorage,  fName + "_item.mem")
-	s.saveStruct(s.activityStorage,  fName + "_activity.mem")
+	s.snapshotLock.Lock()
+	defer s.snapshotLock.Unlock()
+
+	if err := s.saveStruct(s.itemStorage,  fName + "_item.mem"); err != nil {
+		return err
+	}
+
+	if err := s.saveStruct(s.activityStorage,  fName + "_activity.mem"); err != nil {
+		return err
+	}
+
+	return s.wal.Truncate()
 }
 
-func (s *ScanItemRepository) saveStruct(mem *cache.Cache, fileName string) {
+// saveStruct writes a temporary file, syncs it then renames it over the previous snapshot,
+// so a crash leaves either the old or the new snapshot but never a partial one
+func (s *ScanItemRepository) saveStruct(mem *cache.Cache, fileName string) error {
 	memDump := memDumpStruct{ mem.Items()}
+	tmpName := fileName + ".tmp"
 
-	if len(memDump.Items) > 0 {
-		if fp, err := os.Create(fileName); err == nil {
-			defer fp.Close()
+	fp, err := os.Create(tmpName)
 
-			gob.Register(memDumpStruct{})
-			gob.Register(&scanItem.ScanItem{})
-			gob.Register(scanItem.ItemActivities{})
-			gob.Register([]scanItem.ItemActivity{})
-			gob.Register(scanItem.ItemActivity{})
+	if err != nil {
+		return err
+	}
 
-			enc := gob.NewEncoder(bufio.NewWriter(fp))
-			if err := enc.Encode(memDump) ; err != nil {
-				fmt.Println(fileName)
-			}
+	var payload bytes.Buffer
+	err = gob.NewEncoder(&payload).Encode(memDump)
+
+	writer := bufio.NewWriter(fp)
+
+	if nil == err {
+		err = dbFormat.WriteHeader(writer)
+	}
+
+	if nil == err {
+		_, err = writer.Write(s.cipher.Seal(payload.Bytes()))
+	}
+
+	if nil == err {
+		err = writer.Flush()
+	}
+
+	if nil == err {
+		err = fp.Sync()
+	}
+
+	if closeErr := fp.Close(); nil == err {
+		err = closeErr
+	}
+
+	if nil != err {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	if err := os.Rename(tmpName, fileName); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(fileName))
+}
+
+func syncDir(dir string) error {
+	fp, err := os.Open(dir)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = fp.Close() }()
+
+	// directories can not be synced on every platform (e.g. windows), the rename is still atomic there
+	_ = fp.Sync()
+
+	return nil
+}
+
+// Stats reads the items and their activities straight from the caches
+func (s *ScanItemRepository) Stats(ctx context.Context, groupBy string) (scanItem.Stats, error) {
+	stats := scanItem.NewStats(groupBy)
+
+	if err := scanItem.CheckContext(ctx, s.dbName, "stats"); err != nil {
+		return stats, err
+	}
+
+	for key, entry := range s.itemStorage.Items() {
+		var activities []scanItem.ItemActivity
+
+		if data, found := s.activityStorage.Get(key); found {
+			activities = data.([]scanItem.ItemActivity)
 		}
+
+		stats.Add(entry.Object.(*scanItem.ScanItem), activities)
 	}
 
-}
\ No newline at end of file
+	return stats, nil
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
-	. "git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"time"
@@ -14,7 +13,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 
 	Describe(":: GetRepoName() to get Repository's name", func() {
 		repoName := "testRepo"
-		repo, _ := NewMemDbConnection().InitRepository(repoName)
+		repo, _ := newTestConnection().InitRepository(repoName)
 		defer repo.CloseDb()
 
 		name := repo.GetRepoName()
@@ -30,7 +29,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 		Context(" :GIVEN: an item was-not-exist", func() {
 
 			repoName := "testRepo"
-			repo, _ := NewMemDbConnection().InitRepository(repoName)
+			repo, _ := newTestConnection().InitRepository(repoName)
 			defer repo.CloseDb()
 
 			Context(" :THEN ⇶ call to GetItem(key-not-exist)", func() {
@@ -49,7 +48,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 
 		Context(" :GIVEN: an item was-exist", func() {
 			repoName := "testRepo"
-			repo, _ := NewMemDbConnection().InitRepository(repoName)
+			repo, _ := newTestConnection().InitRepository(repoName)
 			defer repo.CloseDb()
 
 			item := scanItem.CreateTestScanItem("1a")
@@ -75,7 +74,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 		Context(" :GIVEN: an item was-not-exist", func() {
 
 			repoName := "testRepo"
-			repo, _ := NewMemDbConnection().InitRepository(repoName)
+			repo, _ := newTestConnection().InitRepository(repoName)
 			defer repo.CloseDb()
 
 			Context(" :THEN ⇶ call to SetItem(item)", func() {
@@ -92,7 +91,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 
 		Context(" :GIVEN: an item was-exist", func() {
 			repoName := "testRepo"
-			repo, _ := NewMemDbConnection().InitRepository(repoName)
+			repo, _ := newTestConnection().InitRepository(repoName)
 			defer repo.CloseDb()
 
 			item1 := scanItem.CreateTestScanItem("1a")
@@ -118,7 +117,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 
 	Describe(":: NewItem(Key, Data) to save an Item", func() {
 		repoName := "testRepo"
-		sut, _ := NewMemDbConnection().InitRepository(repoName)
+		sut, _ := newTestConnection().InitRepository(repoName)
 
 		AfterEach(func() {
 			sut.CloseDb()
@@ -140,7 +139,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 
 		Context(" :GIVEN: an item-key valid", func() {
 			repoName := "testRepo"
-			sut, _ := NewMemDbConnection().InitRepository(repoName)
+			sut, _ := newTestConnection().InitRepository(repoName)
 
 			AfterEach(func() {
 				sut.CloseDb()
@@ -172,7 +171,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 		BeforeEach(func() {
 			repoName = "testRepo"
 			itemKey = "testItem"
-			repo, _ = NewMemDbConnection().InitRepository(repoName)
+			repo, _ = newTestConnection().InitRepository(repoName)
 			_, _ = repo.NewItem(itemKey, map[string]string{"field1": "data1", "field2": "data2"})
 
 			fakeActivity1 = scanItem.ItemActivity{
@@ -248,7 +247,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 		BeforeEach(func() {
 			repoName = "testRepo"
 			itemKey = "testItem"
-			repo, _ = NewMemDbConnection().InitRepository(repoName)
+			repo, _ = newTestConnection().InitRepository(repoName)
 			_, _ = repo.NewItem(itemKey, map[string]string{"field1": "data1", "field2": "data2"})
 
 			fakeActivity1 = scanItem.ItemActivity{
@@ -348,7 +347,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 	Describe(":: Len() to get number of items in repository", func() {
 		Context(" :GIVEN: 2 items are put in repository", func() {
 			repoName := "testRepo"
-			sut, _ := NewMemDbConnection().InitRepository(repoName)
+			sut, _ := newTestConnection().InitRepository(repoName)
 			item1 := scanItem.CreateTestScanItem("1a")
 			item2 := scanItem.CreateTestScanItem("2")
 			sut.SetItem(item1)
@@ -373,7 +372,7 @@ var _ = Describe("*memDb.ScanItemRepository", func() {
 	Describe(":: Items() to get all items in repository", func() {
 		Context(" :GIVEN: 2 items are put in repository", func() {
 			repoName := "testRepo"
-			sut, _ := NewMemDbConnection().InitRepository(repoName)
+			sut, _ := newTestConnection().InitRepository(repoName)
 			item1 := scanItem.CreateTestScanItem("1a")
 			item2 := scanItem.CreateTestScanItem("2")
 			sut.SetItem(item1)
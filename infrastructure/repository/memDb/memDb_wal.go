@@ -0,0 +1,137 @@
+package memDb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/atRest"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// Operations recorded in the write-ahead log
+const (
+	walSetItem       = "item"
+	walSetActivities = "activity"
+)
+
+// walRecord holds the full new state of one key, so replaying a record twice is harmless
+type walRecord struct {
+	Op         string
+	Key        string
+	Item       *scanItem.ScanItem
+	Activities []scanItem.ItemActivity
+}
+
+// writeAheadLog is an append-only file of length-prefixed, checksummed gob records.
+// Every record is fsync-ed before the mutation is acknowledged, its payload is sealed when the store is encrypted.
+type writeAheadLog struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	cipher *atRest.Cipher
+}
+
+func openWal(path string, cipher *atRest.Cipher) (*writeAheadLog, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &writeAheadLog{path: path, file: file, cipher: cipher}, nil
+}
+
+func (w *writeAheadLog) Append(record walRecord) error {
+	var encoded bytes.Buffer
+
+	if err := gob.NewEncoder(&encoded).Encode(record); err != nil {
+		return err
+	}
+
+	payload := w.cipher.Seal(encoded.Bytes())
+
+	frame := make([]byte, 8, 8+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	frame = append(frame, payload...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.file.Write(frame); err != nil {
+		return err
+	}
+
+	return w.file.Sync()
+}
+
+// Truncate empties the log once its records are part of a snapshot
+func (w *writeAheadLog) Truncate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+
+	return w.file.Sync()
+}
+
+func (w *writeAheadLog) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.file.Close()
+}
+
+// replayWal applies every complete record of the log in order.
+// A torn or corrupted tail, as left by a power loss in the middle of a write, ends the replay.
+func replayWal(path string, cipher *atRest.Cipher, apply func(record walRecord)) error {
+	fp, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	defer func() { _ = fp.Close() }()
+
+	reader := bufio.NewReader(fp)
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return nil
+		}
+
+		size := binary.LittleEndian.Uint32(header[0:4])
+		payload := make([]byte, size)
+
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return nil
+		}
+
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+			return nil
+		}
+
+		plain, err := cipher.Open(payload)
+
+		if err != nil {
+			return err
+		}
+
+		var record walRecord
+		if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(&record); err != nil {
+			return err
+		}
+
+		apply(record)
+	}
+}
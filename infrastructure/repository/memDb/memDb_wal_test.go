@@ -0,0 +1,67 @@
+package memDb_test
+
+import (
+	"context"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	. "git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/memDb"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("*memDb write-ahead log", func() {
+	var folder string
+
+	BeforeEach(func() {
+		folder, _ = ioutil.TempDir("", "memDbWal")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(folder)
+	})
+
+	Context(" :GIVEN: a repository which was never closed (power loss)", func() {
+		It("items and activities are replayed on InitRepository", func() {
+			repo, err := NewMemDbConnection(folder).InitRepository("gate")
+			Expect(err).To(BeNil())
+
+			item := scanItem.CreateTestScanItem("1a")
+			repo.SetItem(item)
+			activity := scanItem.NewActivity("checkin", map[string]string{"gateway": "gate1"})
+			repo.AddItemActivity(item.GetKey(), activity)
+
+			By("a torn record at the end of the log is ignored")
+			fp, _ := os.OpenFile(folder+"/gate.wal", os.O_APPEND|os.O_WRONLY, 0600)
+			_, _ = fp.Write([]byte{42, 0, 0, 0, 1, 2})
+			_ = fp.Close()
+
+			recovered, err := NewMemDbConnection(folder).InitRepository("gate")
+			Expect(err).To(BeNil())
+			defer recovered.CloseDb()
+
+			got, found := recovered.GetItemDetail(item.GetKey())
+			Expect(found).To(BeTrue())
+			Expect(got.Data).To(Equal(item.Data))
+			Expect(got.Activities).To(HaveLen(1))
+			Expect(got.Activities[0].Id).To(Equal(activity.Id))
+		})
+	})
+
+	Context(" :GIVEN: a repository which was snapshotted", func() {
+		It("the log is compacted and the snapshot is loaded", func() {
+			repo, _ := NewMemDbConnection(folder).OpenRepository(context.Background(), "gate")
+			Expect(repo.SetItem(context.Background(), scanItem.CreateTestScanItem("2"))).To(Succeed())
+			Expect(repo.(*ScanItemRepository).SaveToFile()).To(Succeed())
+
+			info, err := os.Stat(folder + "/gate.wal")
+			Expect(err).To(BeNil())
+			Expect(info.Size()).To(Equal(int64(0)))
+
+			recovered, _ := NewMemDbConnection(folder).InitRepository("gate")
+			defer recovered.CloseDb()
+			Expect(recovered.Len()).To(Equal(1))
+		})
+	})
+})
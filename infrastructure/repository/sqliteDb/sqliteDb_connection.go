@@ -0,0 +1,166 @@
+package sqliteDb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/dbFormat"
+	"os"
+	"path/filepath"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+const FileName = "event-hub.sqlite"
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS items (
+		repo  TEXT NOT NULL,
+		key   TEXT NOT NULL,
+		data  TEXT NOT NULL,
+		local TEXT NOT NULL DEFAULT '{}',
+		PRIMARY KEY (repo, key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS activities (
+		seq          INTEGER PRIMARY KEY AUTOINCREMENT,
+		repo         TEXT NOT NULL,
+		item_key     TEXT NOT NULL,
+		activity_id  TEXT NOT NULL,
+		action       TEXT NOT NULL,
+		data         TEXT NOT NULL,
+		created      TEXT NOT NULL,
+		created_unix INTEGER NOT NULL,
+		sync         TEXT NOT NULL DEFAULT '{}'
+	)`,
+	`CREATE INDEX IF NOT EXISTS activities_item ON activities (repo, item_key, created_unix)`,
+	`CREATE INDEX IF NOT EXISTS activities_action ON activities (repo, action, created_unix)`,
+	`CREATE INDEX IF NOT EXISTS activities_id ON activities (repo, item_key, activity_id)`,
+}
+
+// Options of the "sqlite" storage adapter
+type Options struct {
+	Folder string
+}
+
+// Connection keeps every repository in a single SQLite file, which can be opened with standard tools
+type Connection struct {
+	dbFile string
+	db     *sql.DB
+	mu     sync.Mutex
+	open   int
+}
+
+func NewSqliteDbConnection(dbFolder string) (*Connection, error) {
+	if err := os.MkdirAll(dbFolder, 0755); err != nil {
+		return nil, err
+	}
+
+	dbFile := filepath.Join(dbFolder, FileName)
+	db, err := openDb(dbFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Connection{
+		dbFile: dbFile,
+		db:     db,
+	}, nil
+}
+
+// openDb opens the file and brings its schema up to date
+func openDb(dbFile string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+dbFile+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)")
+
+	if err != nil {
+		return nil, err
+	}
+
+	// a single writer avoids SQLITE_BUSY between the http handlers and the keeper goroutines
+	db.SetMaxOpenConns(1)
+
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	if err := checkFormat(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// checkFormat keeps the format version in the user_version pragma, files written by a newer hub are refused
+func checkFormat(db *sql.DB) error {
+	var version int
+
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	// the schema has not changed since versioning, a new file only needs its stamp
+	if version == dbFormat.CurrentVersion {
+		return nil
+	}
+
+	if _, err := dbFormat.Pending(version); err != nil {
+		return fmt.Errorf("sqliteDb: %s", err.Error())
+	}
+
+	_, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", dbFormat.CurrentVersion))
+
+	return err
+}
+
+func (c *Connection) InitRepository(name string) (scanItem.RepositoryInterface, error) {
+	return scanItem.LegacyOpen(c.OpenRepository(context.Background(), name))
+}
+
+func (c *Connection) OpenRepository(ctx context.Context, name string) (scanItem.RepositoryInterfaceV2, error) {
+	if err := scanItem.CheckContext(ctx, name, "open"); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the file was closed with the last repository, a later open starts it again
+	if nil == c.db {
+		db, err := openDb(c.dbFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.db = db
+	}
+
+	c.open += 1
+
+	return &ScanItemRepository{
+		repoName: name,
+		conn:     c,
+		handle:   c.db,
+	}, nil
+}
+
+// release closes the file once the last repository is closed
+func (c *Connection) release() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.open -= 1; c.open > 0 {
+		return nil
+	}
+
+	db := c.db
+	c.db = nil
+
+	return db.Close()
+}
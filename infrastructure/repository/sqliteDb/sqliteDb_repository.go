@@ -0,0 +1,349 @@
+package sqliteDb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"sync"
+	"time"
+)
+
+type ScanItemRepository struct {
+	repoName  string
+	conn      *Connection
+	handle    *sql.DB
+	closeOnce sync.Once
+}
+
+func (r *ScanItemRepository) db() *sql.DB {
+	return r.handle
+}
+
+func (r *ScanItemRepository) NewItem(ctx context.Context, itemKey string, data map[string]string) (*scanItem.ScanItem, error) {
+	item, err := scanItem.NewScanItem(itemKey, data)
+
+	if nil != err {
+		return nil, err
+	}
+
+	if err := r.SetItem(ctx, item); nil != err {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (r *ScanItemRepository) SetItem(ctx context.Context, item *scanItem.ScanItem) error {
+	data, _ := json.Marshal(item.GetData())
+	local, _ := json.Marshal(item.Local)
+
+	_, err := r.db().ExecContext(ctx,
+		`INSERT INTO items (repo, key, data, local) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (repo, key) DO UPDATE SET data = excluded.data, local = excluded.local`,
+		r.repoName, item.GetKey(), string(data), string(local))
+
+	return r.storageError("SetItem", err)
+}
+
+func (r *ScanItemRepository) GetItem(ctx context.Context, key string) (*scanItem.ScanItem, bool, error) {
+	row := r.db().QueryRowContext(ctx, `SELECT key, data, local FROM items WHERE repo = ? AND key = ?`, r.repoName, key)
+
+	item, err := scanItemFrom(row)
+
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, r.storageError("GetItem", err)
+	}
+
+	return item, true, nil
+}
+
+func (r *ScanItemRepository) GetItemDetail(ctx context.Context, itemKey string) (*scanItem.ItemDetail, bool, error) {
+	item, found, err := r.GetItem(ctx, itemKey)
+
+	if !found || err != nil {
+		return nil, false, err
+	}
+
+	activities, err := r.readActivities(ctx, itemKey)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &scanItem.ItemDetail{
+		ScanItem:   *item,
+		Activities: activities,
+	}, true, nil
+}
+
+func (r *ScanItemRepository) Items(ctx context.Context) ([]*scanItem.ScanItem, error) {
+	var result []*scanItem.ScanItem
+
+	rows, err := r.db().QueryContext(ctx, `SELECT key, data, local FROM items WHERE repo = ? ORDER BY key`, r.repoName)
+
+	if err != nil {
+		return nil, r.storageError("Items", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		item, err := scanItemFrom(rows)
+
+		if err != nil {
+			return nil, r.storageError("Items", err)
+		}
+
+		result = append(result, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, r.storageError("Items", err)
+	}
+
+	return result, nil
+}
+
+// Query reads the items in key order from the cursor and stops once the page is full, when the query sorts by key.
+// Activity filters run in SQL, field filters keep the case folding of scanItem and need every item to count the total.
+// Other sorts load every item.
+func (r *ScanItemRepository) Query(ctx context.Context, query scanItem.Query) (scanItem.QueryResult, error) {
+	if !query.KeyOrdered() {
+		return scanItem.RunQuery(ctx, r, query)
+	}
+
+	// the pager only sees items which passed the activity filters of the statement
+	paged := query
+	paged.HasActivity, paged.From, paged.To = "", time.Time{}, time.Time{}
+
+	pager, err := scanItem.NewKeyPager(paged)
+
+	if err != nil {
+		return scanItem.QueryResult{}, err
+	}
+
+	where, args := r.queryWhere(query)
+	count := 0
+
+	if !paged.Filters() {
+		if err := r.db().QueryRowContext(ctx, `SELECT COUNT(*) FROM items WHERE `+where, args...).Scan(&count); err != nil {
+			return scanItem.QueryResult{}, r.storageError("Query", err)
+		}
+
+		if after, _ := query.SeekKey(); "" != after && query.SortDesc {
+			where, args = where+` AND key < ?`, append(args, after)
+		} else if "" != after {
+			where, args = where+` AND key > ?`, append(args, after)
+		}
+	}
+
+	order := ` ORDER BY key`
+
+	if query.SortDesc {
+		order += ` DESC`
+	}
+
+	rows, err := r.db().QueryContext(ctx, `SELECT key, data, local FROM items WHERE `+where+order, args...)
+
+	if err != nil {
+		return scanItem.QueryResult{}, r.storageError("Query", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		item, err := scanItemFrom(rows)
+
+		if err != nil {
+			return scanItem.QueryResult{}, r.storageError("Query", err)
+		}
+
+		// the statement filtered the activities already, the pager never reads them
+		if more, err := pager.Offer(item, nil); err != nil || !more {
+			break
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return scanItem.QueryResult{}, r.storageError("Query", err)
+	}
+
+	return pager.Result(count), nil
+}
+
+// queryWhere selects the items of the repository which have an activity matching the activity filters of query
+func (r *ScanItemRepository) queryWhere(query scanItem.Query) (string, []interface{}) {
+	where, args := `repo = ?`, []interface{}{r.repoName}
+
+	if !query.FiltersActivities() {
+		return where, args
+	}
+
+	where += ` AND EXISTS (SELECT 1 FROM activities WHERE activities.repo = items.repo AND activities.item_key = items.key`
+
+	if "" != query.HasActivity {
+		where, args = where+` AND action = ?`, append(args, query.HasActivity)
+	}
+
+	if !query.From.IsZero() {
+		where, args = where+` AND created_unix >= ?`, append(args, query.From.UnixNano())
+	}
+
+	if !query.To.IsZero() {
+		where, args = where+` AND created_unix < ?`, append(args, query.To.UnixNano())
+	}
+
+	return where + `)`, args
+}
+
+func (r *ScanItemRepository) Len(ctx context.Context) (int, error) {
+	var count int
+
+	err := r.db().QueryRowContext(ctx, `SELECT COUNT(*) FROM items WHERE repo = ?`, r.repoName).Scan(&count)
+
+	return count, r.storageError("Len", err)
+}
+
+//////////////////////
+
+func (r *ScanItemRepository) AddItemActivity(ctx context.Context, itemKey string, activity scanItem.ItemActivity) (*scanItem.ItemActivities, error) {
+	if _, found, err := r.GetItem(ctx, itemKey); !found || err != nil {
+		return nil, err
+	}
+
+	data, _ := json.Marshal(activity.Data)
+	sync, _ := json.Marshal(activity.Sync)
+
+	_, err := r.db().ExecContext(ctx,
+		`INSERT INTO activities (repo, item_key, activity_id, action, data, created, created_unix, sync)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.repoName, itemKey, activity.Id, activity.Action, string(data),
+		activity.Created.Format(time.RFC3339Nano), activity.Created.UnixNano(), string(sync))
+
+	if err != nil {
+		return nil, r.storageError("AddItemActivity", err)
+	}
+
+	return r.GetItemActivities(ctx, itemKey)
+}
+
+func (r *ScanItemRepository) SetActivitySync(ctx context.Context, itemKey string, activityId string, sync scanItem.ActivitySync) (bool, error) {
+	encoded, _ := json.Marshal(sync)
+
+	result, err := r.db().ExecContext(ctx,
+		`UPDATE activities SET sync = ? WHERE repo = ? AND item_key = ? AND activity_id = ?`,
+		string(encoded), r.repoName, itemKey, activityId)
+
+	if err != nil {
+		return false, r.storageError("SetActivitySync", err)
+	}
+
+	affected, _ := result.RowsAffected()
+
+	return affected > 0, nil
+}
+
+// GetItemActivities returns the latest activity on top
+func (r *ScanItemRepository) GetItemActivities(ctx context.Context, itemKey string) (*scanItem.ItemActivities, error) {
+	if _, found, err := r.GetItem(ctx, itemKey); !found || err != nil {
+		return nil, err
+	}
+
+	activities, err := r.readActivities(ctx, itemKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &scanItem.ItemActivities{Key: itemKey, Activities: activities}, nil
+}
+
+func (r *ScanItemRepository) readActivities(ctx context.Context, itemKey string) ([]scanItem.ItemActivity, error) {
+	var activities []scanItem.ItemActivity
+
+	rows, err := r.db().QueryContext(ctx,
+		`SELECT activity_id, action, data, created, sync FROM activities
+		 WHERE repo = ? AND item_key = ? ORDER BY created_unix DESC, seq DESC`,
+		r.repoName, itemKey)
+
+	if err != nil {
+		return nil, r.storageError("GetItemActivities", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var activity scanItem.ItemActivity
+		var data, created, sync string
+
+		if err := rows.Scan(&activity.Id, &activity.Action, &data, &created, &sync); err != nil {
+			return nil, r.storageError("GetItemActivities", err)
+		}
+
+		_ = json.Unmarshal([]byte(data), &activity.Data)
+		_ = json.Unmarshal([]byte(sync), &activity.Sync)
+		activity.Created, _ = time.Parse(time.RFC3339Nano, created)
+
+		activities = append(activities, activity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, r.storageError("GetItemActivities", err)
+	}
+
+	return activities, nil
+}
+
+func (r *ScanItemRepository) GetRepoName() string {
+	return r.repoName
+}
+
+func (r *ScanItemRepository) CloseDb(ctx context.Context) error {
+	var err error
+
+	r.closeOnce.Do(func() {
+		err = r.storageError("CloseDb", r.conn.release())
+	})
+
+	return err
+}
+
+// storageError wraps an error of the database, nil stays nil
+func (r *ScanItemRepository) storageError(operation string, err error) error {
+	if nil == err {
+		return nil
+	}
+
+	return scanItem.NewStorageError(r.repoName, operation, err)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanItemFrom(row rowScanner) (*scanItem.ScanItem, error) {
+	var key, data, local string
+
+	if err := row.Scan(&key, &data, &local); err != nil {
+		return nil, err
+	}
+
+	item := &scanItem.ScanItem{Key: key}
+
+	if err := json.Unmarshal([]byte(data), &item.Data); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(local), &item.Local); err != nil {
+		return nil, err
+	}
+
+	if nil == item.Data {
+		item.Data = make(map[string]string)
+	}
+
+	return item, nil
+}
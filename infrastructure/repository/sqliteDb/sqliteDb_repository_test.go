@@ -0,0 +1,142 @@
+package sqliteDb_test
+
+import (
+	"git.anphabe.net/event/anphabe-event-hub/domain/model/scanItem"
+	"git.anphabe.net/event/anphabe-event-hub/domain/service"
+	. "git.anphabe.net/event/anphabe-event-hub/infrastructure/repository/sqliteDb"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("*sqliteDb.ScanItemRepository", func() {
+	var folder string
+	var connection *Connection
+	var repo scanItem.RepositoryInterface
+
+	BeforeEach(func() {
+		var err error
+
+		folder, _ = ioutil.TempDir("", "sqliteDb")
+		connection, err = NewSqliteDbConnection(folder)
+		Expect(err).To(BeNil())
+		Expect(connection).To(BeAssignableToTypeOf((service.DbConnectionInterface)(connection)))
+
+		repo, _ = connection.InitRepository("testRepo")
+	})
+
+	AfterEach(func() {
+		repo.CloseDb()
+		_ = os.RemoveAll(folder)
+	})
+
+	Context(" :GIVEN: 2 items are put in repository", func() {
+		It("items, Len() and overwrite behave like the other adapters", func() {
+			item1 := scanItem.CreateTestScanItem("1a")
+			repo.SetItem(item1)
+			repo.SetItem(scanItem.CreateTestScanItem("2"))
+
+			Expect(repo.Len()).To(Equal(2))
+			Expect(repo.Items()).To(ContainElement(item1))
+
+			item1b := scanItem.CreateTestScanItem("1b")
+			repo.SetItem(item1b)
+			got, found := repo.GetItem(item1b.GetKey())
+
+			Expect(found).To(BeTrue())
+			Expect(got).To(Equal(item1b))
+			Expect(repo.Len()).To(Equal(2))
+		})
+	})
+
+	Context(" :GIVEN: an item with 2 activities", func() {
+		It("latest activity is on top and its sync state can be updated", func() {
+			_, _ = repo.NewItem("key", map[string]string{"name": "Hong"})
+			first := scanItem.NewActivity("checkin", map[string]string{"gateway": "gate1"})
+			second := scanItem.NewActivity("lunch", nil)
+			second.Created = first.Created.Add(time.Second)
+
+			repo.AddItemActivity("key", first)
+			got := repo.AddItemActivity("key", second)
+
+			Expect(got.Activities).To(HaveLen(2))
+			Expect(got.Activities[0].Id).To(Equal(second.Id))
+			Expect(got.Activities[1].Data).To(Equal(first.Data))
+
+			Expect(repo.SetActivitySync("key", first.Id, scanItem.ActivitySync{Status: scanItem.SyncSent, Attempts: 1})).To(BeTrue())
+			Expect(repo.GetItemActivities("key").Activities[1].Sync.Status).To(Equal(scanItem.SyncSent))
+		})
+
+		It("activities of unknown items are refused", func() {
+			Expect(repo.AddItemActivity("not exist", scanItem.NewActivity("checkin", nil))).To(BeNil())
+		})
+	})
+
+	Context(" :GIVEN: 5 items queried by key", func() {
+		BeforeEach(func() {
+			for _, key := range []string{"c", "a", "e", "b", "d"} {
+				_, _ = repo.NewItem(key, map[string]string{"half": strconv.Itoa(strings.Index("abcde", key) % 2)})
+			}
+
+			repo.AddItemActivity("d", scanItem.NewActivity("checkin", nil))
+		})
+
+		pages := func(query scanItem.Query) ([]string, []int) {
+			var keys []string
+			var totals []int
+
+			for {
+				result, err := repo.Query(query)
+				Expect(err).To(BeNil())
+
+				for _, item := range result.Items {
+					keys = append(keys, item.Key)
+				}
+
+				totals = append(totals, result.Total)
+
+				if "" == result.NextCursor {
+					return keys, totals
+				}
+
+				query.Cursor = result.NextCursor
+			}
+		}
+
+		It("pages follow the cursor in key order, both ways", func() {
+			keys, totals := pages(scanItem.Query{Limit: 2})
+			Expect(keys).To(Equal([]string{"a", "b", "c", "d", "e"}))
+			Expect(totals).To(Equal([]int{5, 5, 5}))
+
+			keys, _ = pages(scanItem.Query{SortField: scanItem.SortByKey, SortDesc: true, Limit: 2})
+			Expect(keys).To(Equal([]string{"e", "d", "c", "b", "a"}))
+		})
+
+		It("filters count every matching item", func() {
+			keys, totals := pages(scanItem.Query{Fields: []scanItem.FieldFilter{{Field: "half", Value: "0"}}, Limit: 2})
+			Expect(keys).To(Equal([]string{"a", "c", "e"}))
+			Expect(totals).To(Equal([]int{3, 3}))
+
+			keys, totals = pages(scanItem.Query{HasActivity: "checkin", Limit: 2})
+			Expect(keys).To(Equal([]string{"d"}))
+			Expect(totals).To(Equal([]int{1}))
+		})
+	})
+
+	Context(" :GIVEN: the last repository was closed", func() {
+		It("a later open reopens the file and keeps its items", func() {
+			repo.SetItem(scanItem.CreateTestScanItem("1a"))
+			repo.CloseDb()
+
+			repo, _ = connection.InitRepository("testRepo")
+			repo.SetItem(scanItem.CreateTestScanItem("2"))
+
+			Expect(repo.Len()).To(Equal(2))
+		})
+	})
+})
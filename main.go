@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"git.anphabe.net/event/anphabe-event-hub/config"
 	"git.anphabe.net/event/anphabe-event-hub/infrastructure/injection"
+	"github.com/spf13/pflag"
 	"go.uber.org/zap"
 	"net/http"
 	"os"
@@ -62,6 +63,12 @@ func main() {
 		}
 	}()
 
+	// Maintenance commands run instead of the server
+	if handled, err1 := injection.RunCommand(pflag.Args()); handled {
+		err = err1
+		return
+	}
+
 	// Run Importer
 	importRunner := injection.InitSourceKeeper()
 
@@ -76,6 +83,7 @@ func main() {
 	<-quit
 
 	importRunner.Stop()
+	injection.InitWebhookDispatcher(cfg).Stop()
 
 	ctx, cancelFunc := context.WithTimeout(context.Background(), 5 * time.Second)
 	defer cancelFunc()